@@ -0,0 +1,135 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// An APICall records a single HTTP request made to B2.  Each attempt at a call
+// that is retried is recorded separately.
+type APICall struct {
+	Method    string        // The API call, such as "b2_upload_file".
+	Bucket    string        // The bucket's name, for calls made on a bucket.
+	Start     time.Time     // When the request was sent.
+	Duration  time.Duration // How long B2 took to respond.
+	Status    int           // The HTTP status, or 0 if there was no response.
+	Retries   int           // The number of earlier attempts at the same call.
+	RequestID string        // B2's ID for the request, if it sent one.
+	ClientID  string        // The X-Blazer-Request-ID header, which appears in debug logs.
+	Err       error         // The error, if the request could not be made.
+}
+
+// requestIDHeader is the response header in which B2 identifies a request for
+// its support staff.
+const requestIDHeader = "X-Bz-Request-Id"
+
+// AuditLog returns a ClientOption that keeps a record of the last n requests
+// made by the client, so that the request IDs of failed calls can be given to
+// Backblaze support without turning on debug logging.  The record is returned
+// by Client.RecentCalls.
+func AuditLog(n int) ClientOption {
+	return func(c *clientOptions) {
+		c.auditSize = n
+	}
+}
+
+// RecentCalls returns the requests recorded by AuditLog, oldest first.  It
+// returns nil if the client was not created with AuditLog.
+func (c *Client) RecentCalls() []APICall {
+	if c.audit == nil {
+		return nil
+	}
+	return c.audit.calls()
+}
+
+// auditLog is a ring buffer of API calls.
+type auditLog struct {
+	mu   sync.Mutex
+	ring []APICall
+	next int  // where the next call goes
+	full bool // whether the ring has wrapped
+}
+
+func newAuditLog(n int) *auditLog {
+	if n <= 0 {
+		return nil
+	}
+	return &auditLog{ring: make([]APICall, n)}
+}
+
+func (a *auditLog) add(c APICall) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ring[a.next] = c
+	a.next++
+	if a.next == len(a.ring) {
+		a.next = 0
+		a.full = true
+	}
+}
+
+func (a *auditLog) calls() []APICall {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.full {
+		return append([]APICall(nil), a.ring[:a.next]...)
+	}
+	return append(append([]APICall(nil), a.ring[a.next:]...), a.ring[:a.next]...)
+}
+
+// record adds the request r, and its response or error, to the log.
+func (a *auditLog) record(r *http.Request, resp *http.Response, err error, start, end time.Time) {
+	c := APICall{
+		Method:   r.Header.Get("X-Blazer-Method"),
+		Start:    start,
+		Duration: end.Sub(start),
+		ClientID: r.Header.Get("X-Blazer-Request-ID"),
+		Err:      err,
+	}
+	ctx := r.Context()
+	if c.Method != "b2_authorize_account" {
+		// Reauthorization happens in the midst of a bucket's calls.
+		c.Bucket, _ = ctx.Value(bucketKey).(string)
+	}
+	if n, ok := ctx.Value(attemptKey).(int); ok && n > 0 {
+		c.Retries = n - 1
+	}
+	if resp != nil {
+		c.Status = resp.StatusCode
+		c.RequestID = resp.Header.Get(requestIDHeader)
+	}
+	a.add(c)
+}
+
+type auditKey int
+
+const (
+	attemptKey auditKey = iota
+	bucketKey
+)
+
+// withAttempt records in ctx that this is the nth attempt at a call.
+func withAttempt(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, attemptKey, n)
+}
+
+// withBucket records in ctx the bucket on which a call is made.
+func withBucket(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, bucketKey, name)
+}
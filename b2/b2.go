@@ -37,6 +37,9 @@ import (
 	"strconv"
 	"sync"
 	"time"
+
+	"github.com/burner-account/blazer/internal/blog"
+	"github.com/burner-account/blazer/internal/window"
 )
 
 // Client is a Backblaze B2 client.
@@ -44,19 +47,20 @@ type Client struct {
 	backend beRootInterface
 
 	slock    sync.Mutex
-	sWriters map[string]*Writer
-	sReaders map[string]*Reader
+	sWriters map[*Writer]bool
+	sReaders map[*Reader]bool
 	sMethods []methodCounter
 	opts     clientOptions
+	closed   bool
+	done     chan struct{} // closed by Close
+	limits   *requestLimiter
+	audit    *auditLog
 }
 
 // NewClient creates and returns a new Client with valid B2 service account
 // tokens.
 func NewClient(ctx context.Context, account, key string, opts ...ClientOption) (*Client, error) {
 	c := &Client{
-		backend: &beRoot{
-			b2i: &b2Root{},
-		},
 		sMethods: []methodCounter{
 			newMethodCounter(time.Minute, time.Second),
 			newMethodCounter(time.Minute*5, time.Second),
@@ -68,21 +72,113 @@ func NewClient(ctx context.Context, account, key string, opts ...ClientOption) (
 	for _, f := range opts {
 		f(&c.opts)
 	}
+	c.limits = newRequestLimiter(c.opts)
+	c.audit = newAuditLog(c.opts.auditSize)
+	var root b2RootInterface = &b2Root{}
+	if c.opts.dryRun {
+		root = &dryRunRoot{b2RootInterface: root, record: c.opts.dryRunRecord}
+	}
+	c.backend = &beRoot{b2i: root}
 	if err := c.backend.authorizeAccount(ctx, account, key, c.opts); err != nil {
 		return nil, err
 	}
 	return c, nil
 }
 
+// ClockSkew returns how far B2's clock is estimated to be ahead of the local
+// clock, from the Date headers of recent responses.  The client measures the
+// age of its authorization token by B2's clock, and replaces the token shortly
+// before B2 would reject it, so a skewed local clock does not cause a burst of
+// failed calls.
+func (c *Client) ClockSkew() time.Duration {
+	return c.backend.clockSkew()
+}
+
+// Close releases the resources held by the client.  Any outstanding Writers
+// and Readers are canceled, cached upload URLs are discarded, and idle
+// connections held by the client's transports are closed.  Buckets and
+// objects obtained from a closed client should not be used.
+//
+// Clients that are used for the lifetime of the program need not be closed.
+func (c *Client) Close() error {
+	c.slock.Lock()
+	if c.closed {
+		c.slock.Unlock()
+		return nil
+	}
+	c.closed = true
+	if c.done == nil {
+		c.done = make(chan struct{})
+	}
+	close(c.done)
+	var ws []*Writer
+	for w := range c.sWriters {
+		ws = append(ws, w)
+	}
+	var rs []*Reader
+	for r := range c.sReaders {
+		rs = append(rs, r)
+	}
+	c.slock.Unlock()
+
+	for _, w := range ws {
+		w.cancel()
+	}
+	for _, r := range rs {
+		r.cancel()
+	}
+	for _, rt := range []http.RoundTripper{c.opts.transport, c.opts.downloadTransport} {
+		// The default transport is shared with the rest of the program, so only
+		// transports the caller gave us are closed.
+		if ci, ok := rt.(interface{ CloseIdleConnections() }); ok {
+			ci.CloseIdleConnections()
+		}
+	}
+	return nil
+}
+
+// newURLPool returns an upload URL pool that stops handing out and accepting
+// URLs when the client is closed.  The client keeps no reference to the pool,
+// which is collected along with its bucket.
+func (c *Client) newURLPool() *urlPool {
+	c.slock.Lock()
+	defer c.slock.Unlock()
+	if c.done == nil {
+		c.done = make(chan struct{})
+	}
+	return &urlPool{
+		ch:   make(chan beURLInterface, uploadURLPoolSize),
+		done: c.done,
+	}
+}
+
 type clientOptions struct {
-	client          *Client
-	transport       http.RoundTripper
-	failSomeUploads bool
-	expireTokens    bool
-	capExceeded     bool
-	apiBase         string
-	userAgents      []string
-	writerOpts      []WriterOption
+	client            *Client
+	transport         http.RoundTripper
+	downloadTransport http.RoundTripper
+	failSomeUploads   bool
+	expireTokens      bool
+	capExceeded       bool
+	apiBase           string
+	apiURL            string
+	downloadURL       string
+	userAgents        []string
+	writerOpts        []WriterOption
+	attrsTTL          time.Duration
+	dryRun            bool
+	dryRunRecord      func(DryRunOp)
+	maxUploads        int
+	maxDownloads      int
+	maxOther          int
+	rateUploads       int
+	rateDownloads     int
+	rateOther         int
+	ratePer           time.Duration
+	maxRetries        int
+	onRetry           func(RetryEvent)
+	clock             Clock
+	limits            *requestLimiter // overrides client.limits, for isolated buckets
+	auditSize         int
 }
 
 // A ClientOption allows callers to adjust various per-client settings.
@@ -106,6 +202,25 @@ func APIBase(url string) ClientOption {
 	}
 }
 
+// APIURL returns a ClientOption that overrides the API URL that B2 returns on
+// authorization.  Unlike APIBase, which is used only to authorize, this is
+// where all subsequent API calls are sent.
+func APIURL(url string) ClientOption {
+	return func(o *clientOptions) {
+		o.apiURL = url
+	}
+}
+
+// DownloadURL returns a ClientOption that overrides the download URL that B2
+// returns on authorization.  Downloads, and the URLs returned by BaseURL and
+// Object.URL, use this host instead, which allows downloads to be routed
+// through a CDN or proxy while API calls go directly to B2.
+func DownloadURL(url string) ClientOption {
+	return func(o *clientOptions) {
+		o.downloadURL = url
+	}
+}
+
 // Transport sets the underlying HTTP transport mechanism.  If unset,
 // http.DefaultTransport is used.
 func Transport(rt http.RoundTripper) ClientOption {
@@ -114,6 +229,86 @@ func Transport(rt http.RoundTripper) ClientOption {
 	}
 }
 
+// DownloadTransport sets the HTTP transport mechanism used for downloads.  If
+// unset, the transport given to Transport is used.
+func DownloadTransport(rt http.RoundTripper) ClientOption {
+	return func(c *clientOptions) {
+		c.downloadTransport = rt
+	}
+}
+
+// BucketAttrsTTL returns a ClientOption that caches the result of Bucket.Attrs
+// for up to ttl.  The cache is per Bucket value, and is dropped whenever the
+// bucket is updated through that value or InvalidateAttrs is called.  Changes
+// made elsewhere may not be seen until the cached attributes expire.
+func BucketAttrsTTL(ttl time.Duration) ClientOption {
+	return func(c *clientOptions) {
+		c.attrsTTL = ttl
+	}
+}
+
+// MaxConcurrentRequests returns a ClientOption that limits the number of B2
+// requests the client will have in flight at once.  Uploads (of files and of
+// large file parts), downloads, and all other API calls each have their own
+// limit, so that a burst of one kind cannot starve the others.  A limit of 0
+// means no limit.  Requests over the limit wait for an earlier one to finish,
+// or for their context to be canceled.
+//
+// A download holds its slot until its response body is closed, which for
+// Readers is when the chunk has been fetched.
+func MaxConcurrentRequests(uploads, downloads, other int) ClientOption {
+	return func(c *clientOptions) {
+		c.maxUploads = uploads
+		c.maxDownloads = downloads
+		c.maxOther = other
+	}
+}
+
+// MaxRequestRate returns a ClientOption that limits the number of B2 requests
+// the client will begin in any period of the given length.  As with
+// MaxConcurrentRequests, uploads, downloads, and all other API calls are
+// limited separately, and a limit of 0 means no limit.  Requests over the
+// limit wait until enough earlier ones have passed out of the period, or for
+// their context to be canceled.  This can keep a client within a budget of
+// transactions, which B2 charges for by class.
+func MaxRequestRate(uploads, downloads, other int, per time.Duration) ClientOption {
+	return func(c *clientOptions) {
+		c.rateUploads = uploads
+		c.rateDownloads = downloads
+		c.rateOther = other
+		c.ratePer = per
+	}
+}
+
+// A RetryEvent describes a B2 API call that failed with a transient error and
+// is about to be retried.
+type RetryEvent struct {
+	Method  string        // The API call, such as "b2_list_file_names".
+	Attempt int           // The number of the attempt that failed, starting at 1.
+	Delay   time.Duration // How long the client will wait before trying again.
+	Err     error         // The error that caused the retry.
+}
+
+// OnRetry returns a ClientOption that calls f each time an API call is retried
+// because of a transient error, such as a 503 or a 429, before waiting to try
+// again.  f is called synchronously from the goroutine making the call, so it
+// should return quickly; it is intended for logging and metrics, so that rising
+// retry rates can be noticed before they turn into failures.
+func OnRetry(f func(RetryEvent)) ClientOption {
+	return func(c *clientOptions) {
+		c.onRetry = f
+	}
+}
+
+// MaxRetries returns a ClientOption that gives up on an API call after it has
+// been retried n times because of transient errors, and returns the last
+// error.  By default, calls are retried until their context is done.
+func MaxRetries(n int) ClientOption {
+	return func(c *clientOptions) {
+		c.maxRetries = n
+	}
+}
+
 // FailSomeUploads requests intermittent upload failures from the B2 service.
 // This is mostly useful for testing.
 func FailSomeUploads() ClientOption {
@@ -146,6 +341,7 @@ func client(cl *Client) ClientOption {
 
 type clientTransport struct {
 	client *Client
+	limits *requestLimiter // if nil, client.limits is used
 	rt     http.RoundTripper
 }
 
@@ -155,12 +351,29 @@ func (ct *clientTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 	if t == nil {
 		t = http.DefaultTransport
 	}
+	release := func() {}
+	limits := ct.limits
+	if limits == nil && ct.client != nil {
+		limits = ct.client.limits
+	}
+	if limits != nil {
+		rel, err := limits.acquire(r.Context(), m)
+		if err != nil {
+			return nil, err
+		}
+		release = rel
+	}
 	b := time.Now()
 	resp, err := t.RoundTrip(r)
 	e := time.Now()
+	if ct.client != nil && ct.client.audit != nil {
+		ct.client.audit.record(r, resp, err, b, e)
+	}
 	if err != nil {
+		release()
 		return resp, err
 	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
 	if m != "" && ct.client != nil {
 		ct.client.slock.Lock()
 		m := method{
@@ -176,6 +389,95 @@ func (ct *clientTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 	return resp, nil
 }
 
+type semaphore chan struct{}
+
+func newSemaphore(n int) semaphore {
+	if n <= 0 {
+		return nil
+	}
+	return make(semaphore, n)
+}
+
+// newRateLimiter returns a limiter of n requests per period, keeping time with
+// clock, or nil if there is no limit.
+func newRateLimiter(n int, per time.Duration, clock Clock) *window.Limiter {
+	if n <= 0 || per <= 0 {
+		return nil
+	}
+	res := per / 20
+	if res <= 0 {
+		res = per
+	}
+	return window.NewLimiter(n, per, res, clock)
+}
+
+type requestLimiter struct {
+	upload, download, other             semaphore
+	uploadRate, downloadRate, otherRate *window.Limiter
+}
+
+func newRequestLimiter(o clientOptions) *requestLimiter {
+	l := &requestLimiter{
+		upload:       newSemaphore(o.maxUploads),
+		download:     newSemaphore(o.maxDownloads),
+		other:        newSemaphore(o.maxOther),
+		uploadRate:   newRateLimiter(o.rateUploads, o.ratePer, o.clock),
+		downloadRate: newRateLimiter(o.rateDownloads, o.ratePer, o.clock),
+		otherRate:    newRateLimiter(o.rateOther, o.ratePer, o.clock),
+	}
+	if l.upload == nil && l.download == nil && l.other == nil && l.uploadRate == nil && l.downloadRate == nil && l.otherRate == nil {
+		return nil
+	}
+	return l
+}
+
+// acquire waits until the rate limit for the given B2 method allows another
+// request, and then for a free slot, and returns a function that gives the
+// slot back.
+func (l *requestLimiter) acquire(ctx context.Context, method string) (func(), error) {
+	sem, rate := l.other, l.otherRate
+	switch method {
+	case "b2_upload_file", "b2_upload_part":
+		sem, rate = l.upload, l.uploadRate
+	case "b2_download_file_by_name":
+		sem, rate = l.download, l.downloadRate
+	}
+	if rate != nil {
+		if err := rate.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-sem }) }, nil
+}
+
+// releaseBody calls release when the body is closed or fully read.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (rb *releaseBody) Read(p []byte) (int, error) {
+	n, err := rb.ReadCloser.Read(p)
+	if err == io.EOF {
+		rb.release()
+	}
+	return n, err
+}
+
+func (rb *releaseBody) Close() error {
+	rb.release()
+	return rb.ReadCloser.Close()
+}
+
 // Bucket is a reference to a B2 bucket.
 type Bucket struct {
 	b beBucketInterface
@@ -183,6 +485,52 @@ type Bucket struct {
 
 	c       *Client
 	urlPool *urlPool
+
+	amux     sync.Mutex
+	attrs    *BucketAttrs // cached attributes, if BucketAttrsTTL is set
+	attrsExp time.Time
+	defaults *Attrs // default object attributes; guarded by amux
+}
+
+// Info keys that B2 serves as HTTP response headers when the object is
+// downloaded.
+const (
+	InfoCacheControl       = "b2-cache-control"
+	InfoContentDisposition = "b2-content-disposition"
+	InfoContentLanguage    = "b2-content-language"
+	InfoContentEncoding    = "b2-content-encoding"
+	InfoExpires            = "b2-expires"
+)
+
+// SetDefaultAttrs sets attributes to be merged into those of every object
+// subsequently written through this Bucket.  The default ContentType is used
+// when a Writer does not set one, and each default Info entry is added unless
+// the Writer's attrs already have that key or already have the maximum of ten
+// entries.  Other fields of attrs are ignored.  Passing nil removes the
+// defaults.
+//
+// Defaults belong to this *Bucket value, not to the bucket in B2; other Bucket
+// values for the same bucket are unaffected.
+func (b *Bucket) SetDefaultAttrs(attrs *Attrs) {
+	var d *Attrs
+	if attrs != nil {
+		d = &Attrs{
+			ContentType: attrs.ContentType,
+			Info:        make(map[string]string),
+		}
+		for k, v := range attrs.Info {
+			d.Info[k] = v
+		}
+	}
+	b.amux.Lock()
+	defer b.amux.Unlock()
+	b.defaults = d
+}
+
+func (b *Bucket) defaultAttrs() *Attrs {
+	b.amux.Lock()
+	defer b.amux.Unlock()
+	return b.defaults
 }
 
 type BucketType string
@@ -209,6 +557,22 @@ type BucketAttrs struct {
 	// the rules are not modified.  A bucket's rules can be removed by updating
 	// with an empty slice.
 	LifecycleRules []LifecycleRule
+
+	// Reports or sets the bucket's CORS rules, as for LifecycleRules.
+	CORSRules []CORSRule
+
+	// DefaultEncryption reports or sets how new objects in the bucket are
+	// encrypted.  It is UnknownEncryption if the client's key lacks the
+	// readBucketEncryption capability, and if UnknownEncryption during a
+	// bucket.Update, the setting is not changed.
+	DefaultEncryption EncryptionMode
+
+	// Replication reports or sets the bucket's replication configuration.  It
+	// is nil if the client's key lacks the readBucketReplications capability,
+	// and if nil during a bucket.Update, the configuration is not changed.  A
+	// bucket's replication can be removed by updating with an empty
+	// Replication.
+	Replication *Replication
 }
 
 // A LifecycleRule describes an object's life cycle, namely how many days after
@@ -228,6 +592,71 @@ type LifecycleRule struct {
 	// DaysHiddenUntilDeleted specifies the number of days after which a hidden
 	// file is deleted.  0 means "do not automatically delete hidden files".
 	DaysHiddenUntilDeleted int
+
+	// DaysStartedUntilCanceled specifies the number of days after which an
+	// unfinished large file is canceled and its parts deleted.  0 means "do not
+	// automatically cancel unfinished large files".
+	DaysStartedUntilCanceled int
+}
+
+// A CORSRule allows browsers on the given origins to make the given
+// operations on a bucket, such as "b2_download_file_by_name" or "s3_get".
+type CORSRule struct {
+	Name              string
+	AllowedOrigins    []string
+	AllowedOperations []string
+	AllowedHeaders    []string
+	ExposeHeaders     []string
+	MaxAgeSeconds     int
+}
+
+// EncryptionMode is a bucket's default server-side encryption.
+type EncryptionMode string
+
+// B2 bucket default encryption modes.
+const (
+	UnknownEncryption EncryptionMode = ""
+	NoEncryption      EncryptionMode = "none"
+	SSEB2             EncryptionMode = "SSE-B2" // encrypted with keys that B2 manages
+)
+
+// Replication is a bucket's replication configuration.  A bucket that is a
+// replication source has a SourceKeyID, the ID of the key that reads the
+// objects to be replicated, and Rules.  A bucket that is a replication
+// destination has a KeyMapping, from the IDs of source keys to the IDs of
+// the keys that write their objects in this bucket.  A bucket can be both.
+type Replication struct {
+	SourceKeyID string
+	Rules       []ReplicationRule
+	KeyMapping  map[string]string
+}
+
+// A ReplicationRule copies objects beginning with Prefix to another bucket,
+// which is named by ID.  Rules with lower Priority values are applied first.
+type ReplicationRule struct {
+	Name                 string
+	DestinationBucketID  string
+	Prefix               string
+	IncludeExistingFiles bool
+	Enabled              bool
+	Priority             int
+}
+
+func (r *Replication) copy() *Replication {
+	if r == nil {
+		return nil
+	}
+	n := &Replication{SourceKeyID: r.SourceKeyID}
+	if r.Rules != nil {
+		n.Rules = append([]ReplicationRule{}, r.Rules...)
+	}
+	if r.KeyMapping != nil {
+		n.KeyMapping = make(map[string]string, len(r.KeyMapping))
+		for k, v := range r.KeyMapping {
+			n.KeyMapping[k] = v
+		}
+	}
+	return n
 }
 
 type b2err struct {
@@ -253,14 +682,16 @@ func IsNotExist(err error) bool {
 const uploadURLPoolSize = 100
 
 type urlPool struct {
-	ch chan beURLInterface
-}
-
-func newURLPool() *urlPool {
-	return &urlPool{ch: make(chan beURLInterface, uploadURLPoolSize)}
+	ch   chan beURLInterface
+	done <-chan struct{} // the client's; once closed, the pool is empty
 }
 
 func (p *urlPool) get() beURLInterface {
+	select {
+	case <-p.done:
+		return nil
+	default:
+	}
 	select {
 	case ue := <-p.ch:
 		// if the channel has an upload URL available, use that
@@ -272,6 +703,11 @@ func (p *urlPool) get() beURLInterface {
 }
 
 func (p *urlPool) put(u beURLInterface) {
+	select {
+	case <-p.done:
+		return
+	default:
+	}
 	select {
 	case p.ch <- u:
 		// put the URL back if possible
@@ -292,7 +728,7 @@ func (c *Client) Bucket(ctx context.Context, name string) (*Bucket, error) {
 				b:       bucket,
 				r:       c.backend,
 				c:       c,
-				urlPool: newURLPool(),
+				urlPool: c.newURLPool(),
 			}, nil
 		}
 	}
@@ -316,7 +752,7 @@ func (c *Client) NewBucket(ctx context.Context, name string, attrs *BucketAttrs)
 				b:       bucket,
 				r:       c.backend,
 				c:       c,
-				urlPool: newURLPool(),
+				urlPool: c.newURLPool(),
 			}, nil
 		}
 	}
@@ -331,11 +767,12 @@ func (c *Client) NewBucket(ctx context.Context, name string, attrs *BucketAttrs)
 		b:       b,
 		r:       c.backend,
 		c:       c,
-		urlPool: newURLPool(),
+		urlPool: c.newURLPool(),
 	}, err
 }
 
-// ListBuckets returns all the available buckets.
+// ListBuckets returns all the available buckets.  See also Buckets, which
+// returns an iterator.
 func (c *Client) ListBuckets(ctx context.Context) ([]*Bucket, error) {
 	bs, err := c.backend.listBuckets(ctx, "")
 	if err != nil {
@@ -347,7 +784,7 @@ func (c *Client) ListBuckets(ctx context.Context) ([]*Bucket, error) {
 			b:       b,
 			r:       c.backend,
 			c:       c,
-			urlPool: newURLPool(),
+			urlPool: c.newURLPool(),
 		})
 	}
 	return buckets, nil
@@ -367,23 +804,154 @@ func IsUpdateConflict(err error) bool {
 // this method could fail with an update conflict, in which case you should
 // retrieve the latest bucket attributes with Attrs and try again.
 func (b *Bucket) Update(ctx context.Context, attrs *BucketAttrs) error {
+	defer b.InvalidateAttrs()
 	return b.b.updateBucket(ctx, attrs)
 }
 
-// Attrs retrieves and returns the current bucket's attributes.
+// UpdateWith retrieves the bucket's current attributes, passes them to f to be
+// modified, and saves the result.  If the bucket was modified concurrently, the
+// attributes are retrieved again and f is called again, until the update
+// succeeds without conflict.  f should therefore be free of side effects.  If
+// f returns an error, the update is abandoned and that error is returned.
+func (b *Bucket) UpdateWith(ctx context.Context, f func(*BucketAttrs) error) error {
+	for {
+		b.InvalidateAttrs()
+		attrs, err := b.Attrs(ctx)
+		if err != nil {
+			return err
+		}
+		if err := f(attrs); err != nil {
+			return err
+		}
+		err = b.Update(ctx, attrs)
+		if !IsUpdateConflict(err) {
+			return err
+		}
+		blog.V(2).Infof("%s: update conflict; retrying", b.Name())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+}
+
+// Attrs retrieves and returns the current bucket's attributes.  If the client
+// was created with BucketAttrsTTL, recently retrieved attributes may be
+// returned without contacting B2.
 func (b *Bucket) Attrs(ctx context.Context) (*BucketAttrs, error) {
-	bucket, err := b.c.Bucket(ctx, b.Name())
+	if attrs := b.cachedAttrs(); attrs != nil {
+		return attrs, nil
+	}
+	buckets, err := b.r.listBuckets(ctx, b.Name())
 	if err != nil {
 		return nil, err
 	}
-	b.b = bucket.b
-	return b.b.attrs(), nil
+	for _, bucket := range buckets {
+		if bucket.name() == b.Name() {
+			b.b = bucket
+			attrs := b.b.attrs()
+			b.cacheAttrs(attrs)
+			return attrs, nil
+		}
+	}
+	return nil, b2err{
+		err:         fmt.Errorf("%s: bucket not found", b.Name()),
+		notFoundErr: true,
+	}
+}
+
+// InvalidateAttrs drops any cached bucket attributes, so that the next call to
+// Attrs retrieves them from B2.
+func (b *Bucket) InvalidateAttrs() {
+	b.amux.Lock()
+	defer b.amux.Unlock()
+	b.attrs = nil
+}
+
+func (b *Bucket) cachedAttrs() *BucketAttrs {
+	b.amux.Lock()
+	defer b.amux.Unlock()
+	if b.attrs == nil || time.Now().After(b.attrsExp) {
+		return nil
+	}
+	return b.attrs.copy()
+}
+
+func (b *Bucket) cacheAttrs(attrs *BucketAttrs) {
+	if attrs == nil || b.c == nil || b.c.opts.attrsTTL <= 0 {
+		return
+	}
+	b.amux.Lock()
+	defer b.amux.Unlock()
+	b.attrs = attrs.copy()
+	b.attrsExp = time.Now().Add(b.c.opts.attrsTTL)
+}
+
+func (ba *BucketAttrs) copy() *BucketAttrs {
+	n := &BucketAttrs{
+		Type:              ba.Type,
+		DefaultEncryption: ba.DefaultEncryption,
+		Replication:       ba.Replication.copy(),
+	}
+	if ba.Info != nil {
+		n.Info = make(map[string]string, len(ba.Info))
+		for k, v := range ba.Info {
+			n.Info[k] = v
+		}
+	}
+	if ba.LifecycleRules != nil {
+		n.LifecycleRules = append([]LifecycleRule{}, ba.LifecycleRules...)
+	}
+	if ba.CORSRules != nil {
+		n.CORSRules = append([]CORSRule{}, ba.CORSRules...)
+	}
+	return n
+}
+
+// sendable returns a copy of ba without the default encryption or replication
+// if they are the same as in cur, the bucket's attributes as last listed.
+// These need capabilities of their own to set, and Attrs reports them, so a
+// caller that only changes, say, the bucket info would otherwise resend them.
+func (ba *BucketAttrs) sendable(cur *BucketAttrs) *BucketAttrs {
+	n := ba.copy()
+	if cur == nil {
+		return n
+	}
+	if n.DefaultEncryption == cur.DefaultEncryption {
+		n.DefaultEncryption = UnknownEncryption
+	}
+	if n.Replication.equal(cur.Replication) {
+		n.Replication = nil
+	}
+	return n
+}
+
+func (r *Replication) equal(o *Replication) bool {
+	if r == nil || o == nil {
+		return r == o
+	}
+	if r.SourceKeyID != o.SourceKeyID || len(r.Rules) != len(o.Rules) || len(r.KeyMapping) != len(o.KeyMapping) {
+		return false
+	}
+	for i := range r.Rules {
+		if r.Rules[i] != o.Rules[i] {
+			return false
+		}
+	}
+	for k, v := range r.KeyMapping {
+		if ov, ok := o.KeyMapping[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
 }
 
 var bNotExist = regexp.MustCompile("Bucket.*does not exist")
 
 // Delete removes a bucket.  The bucket must be empty.
 func (b *Bucket) Delete(ctx context.Context) error {
+	defer b.InvalidateAttrs()
 	err := b.b.deleteBucket(ctx)
 	if err == nil {
 		return err
@@ -413,12 +981,18 @@ func (b *Bucket) Name() string {
 	return b.b.name()
 }
 
+// ID returns the bucket's ID.
+func (b *Bucket) ID() string {
+	return b.b.id()
+}
+
 // Object represents a B2 object.
 type Object struct {
 	attrs *Attrs
 	name  string
 	f     beFileInterface
 	b     *Bucket
+	hdr   http.Header
 }
 
 // Attrs holds an object's metadata.
@@ -431,6 +1005,42 @@ type Attrs struct {
 	SHA1            string            // Can be "none" for large files.  If set on upload, will be used for large files.
 	LastModified    time.Time         // If present, and there are fewer than 10 keys in the Info field, this is saved on upload.
 	Info            map[string]string // Save arbitrary metadata on upload, but limited to 10 keys.
+	Header          http.Header       // Not used on upload.  Raw download headers, if the object was looked up by name.
+}
+
+// A Part describes one piece of a large file.
+type Part struct {
+	Number int    // Parts are numbered from 1.
+	Size   int64  // The size of the part, in bytes.
+	SHA1   string // The hex SHA-1 of the part's contents.
+}
+
+// Parts lists the parts that have been uploaded for an unfinished large file,
+// such as those returned by List with ListUnfinished.  B2 does not list the
+// parts of finished files; to audit those, use Writer.Parts when uploading.
+func (o *Object) Parts(ctx context.Context) ([]Part, error) {
+	if err := o.ensure(ctx); err != nil {
+		return nil, err
+	}
+	var parts []Part
+	next := 1
+	for {
+		ps, n, err := o.f.listParts(ctx, next, 100)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ps {
+			parts = append(parts, Part{
+				Number: p.number(),
+				Size:   p.size(),
+				SHA1:   p.sha1(),
+			})
+		}
+		if len(ps) == 0 || n == 0 {
+			return parts, nil
+		}
+		next = n
+	}
 }
 
 // Name returns an object's name
@@ -443,6 +1053,16 @@ func (o *Object) ID() string {
 	return o.f.id()
 }
 
+// ListedSize returns the object's size as reported by the listing that
+// returned it, without a call to B2.  It returns false for objects that did
+// not come from a listing and whose attributes have not been retrieved.
+func (o *Object) ListedSize() (int64, bool) {
+	if o.f == nil {
+		return 0, false
+	}
+	return o.f.size(), true
+}
+
 // Attrs returns an object's attributes.
 func (o *Object) Attrs(ctx context.Context) (*Attrs, error) {
 	if err := o.ensure(ctx); err != nil {
@@ -465,15 +1085,15 @@ func (o *Object) Attrs(ctx context.Context) (*Attrs, error) {
 		state = Folder
 	}
 	var mtime time.Time
-	if v, ok := info["src_last_modified_millis"]; ok {
+	if v, ok := info[infoLastModified]; ok {
 		ms, err := strconv.ParseInt(v, 10, 64)
 		if err != nil {
 			return nil, err
 		}
 		mtime = time.Unix(ms/1e3, (ms%1e3)*1e6)
-		delete(info, "src_last_modified_millis")
+		delete(info, infoLastModified)
 	}
-	if v, ok := info["large_file_sha1"]; ok {
+	if v, ok := info[infoLargeFileSHA1]; ok {
 		sha = v
 	}
 	return &Attrs{
@@ -485,6 +1105,7 @@ func (o *Object) Attrs(ctx context.Context) (*Attrs, error) {
 		Info:            info,
 		Status:          state,
 		LastModified:    mtime,
+		Header:          o.hdr,
 	}, nil
 }
 
@@ -540,6 +1161,14 @@ func (o *Object) NewWriter(ctx context.Context, opts ...WriterOption) *Writer {
 	for _, f := range opts {
 		f(w)
 	}
+	if w.cacheControl != "" {
+		if _, ok := w.info[InfoCacheControl]; ok {
+			w.info[InfoCacheControl] = w.cacheControl
+		} else {
+			w.info = withInfo(w.info, InfoCacheControl, w.cacheControl)
+		}
+	}
+	w.withDefaults(o.b.defaultAttrs())
 	return w
 }
 
@@ -554,6 +1183,7 @@ func (o *Object) NewRangeReader(ctx context.Context, offset, length int64) *Read
 		name:   o.name,
 		chunks: make(map[int]*rchunk),
 		length: length,
+		whole:  offset == 0 && length < 0,
 		offset: offset,
 	}
 }
@@ -570,6 +1200,7 @@ func (o *Object) ensure(ctx context.Context) error {
 			return err
 		}
 		o.f = f.f
+		o.hdr = f.hdr
 	}
 	return nil
 }
@@ -582,6 +1213,78 @@ func (o *Object) Delete(ctx context.Context) error {
 	return o.f.deleteFileVersion(ctx)
 }
 
+// CopyTo makes a copy of the object as dst, which may be in another bucket
+// belonging to the same account.  The copy is made by B2, so no data passes
+// through the client, and it keeps the object's content type and info.  B2
+// will not copy objects larger than 5GB this way.
+func (o *Object) CopyTo(ctx context.Context, dst *Object) error {
+	if err := o.ensure(ctx); err != nil {
+		return err
+	}
+	f, err := o.f.copyFile(ctx, dst.b.b, dst.name, "", nil)
+	if err != nil {
+		return err
+	}
+	dst.f = f
+	dst.attrs = nil
+	dst.hdr = nil
+	return nil
+}
+
+// A DeleteOption alters the behavior of DeleteAllVersions.
+type DeleteOption func(*deleteOptions)
+
+type deleteOptions struct {
+	hide bool
+}
+
+// HideInstead causes DeleteAllVersions to hide the object rather than delete
+// its versions.  Nothing is removed, and the object can be restored with
+// Bucket.Reveal; lifecycle rules can then be used to delete the versions
+// later.
+func HideInstead() DeleteOption {
+	return func(o *deleteOptions) {
+		o.hide = true
+	}
+}
+
+// DeleteAllVersions removes every version of the object, including any that
+// are hidden, so that nothing of that name remains in the bucket.  Versions
+// of other objects that share the name as a prefix are not affected.
+func (o *Object) DeleteAllVersions(ctx context.Context, opts ...DeleteOption) error {
+	var do deleteOptions
+	for _, f := range opts {
+		f(&do)
+	}
+	if do.hide {
+		return o.Hide(ctx)
+	}
+	var versions []*Object
+	iter := o.b.List(ctx, ListPrefix(o.name), ListHidden())
+	for iter.Next() {
+		obj := iter.Object()
+		if obj.Name() > o.name {
+			break
+		}
+		if obj.Name() == o.name {
+			versions = append(versions, obj)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return b2err{err: fmt.Errorf("%s: not found", o.name), notFoundErr: true}
+	}
+	for _, v := range versions {
+		if err := v.f.deleteFileVersion(ctx); err != nil {
+			return err
+		}
+	}
+	o.f = nil
+	return nil
+}
+
 // Hide hides the object from name-based listing.
 func (o *Object) Hide(ctx context.Context) error {
 	if err := o.ensure(ctx); err != nil {
@@ -591,6 +1294,38 @@ func (o *Object) Hide(ctx context.Context) error {
 	return err
 }
 
+// PurgeUnfinishedLargeFiles cancels every unfinished large file in the bucket
+// that was started more than olderThan ago, deleting any parts that were
+// uploaded, and returns the number of files canceled.  Uploads that are still
+// in progress will fail if they are purged, so olderThan should be well beyond
+// the longest expected upload.
+//
+// To have B2 do this automatically, set DaysStartedUntilCanceled in the
+// bucket's lifecycle rules.
+func (b *Bucket) PurgeUnfinishedLargeFiles(ctx context.Context, olderThan time.Duration) (int, error) {
+	cutoff := time.Now().Add(-olderThan)
+	var stale []*Object
+	iter := b.List(ctx, ListUnfinished())
+	for iter.Next() {
+		obj := iter.Object()
+		if obj.f.timestamp().Before(cutoff) {
+			stale = append(stale, obj)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return 0, err
+	}
+	var n int
+	for _, obj := range stale {
+		if err := obj.f.compileParts(0, nil).cancel(ctx); err != nil {
+			return n, err
+		}
+		blog.V(2).Infof("purged unfinished large file %s (%s)", obj.name, obj.f.id())
+		n++
+	}
+	return n, nil
+}
+
 // Reveal unhides (if hidden) the named object.  If there are multiple objects
 // of a given name, it will reveal the most recent.
 func (b *Bucket) Reveal(ctx context.Context, name string) error {
@@ -629,6 +1364,7 @@ func (b *Bucket) getObject(ctx context.Context, name string) (*Object, error) {
 		name: name,
 		f:    b.b.file(fr.id(), name),
 		b:    b,
+		hdr:  fr.header(),
 	}, nil
 }
 
@@ -16,12 +16,19 @@ package b2
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/md5"
 	"crypto/sha1"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
 	"sync"
@@ -68,14 +75,26 @@ func (e *errCont) getError(name string) error {
 type testRoot struct {
 	errs      *errCont
 	auths     int
+	lists     int
 	bucketMap map[string]map[string]string
+	expiring  bool                    // whether the token is about to expire
+	caps      []string                // the key's capabilities, if known
+	attrsMap  map[string]*BucketAttrs // bucket attributes, if they are to be kept
+	notifyMap map[string][]NotificationRule
 }
 
 func (t *testRoot) authorizeAccount(context.Context, string, string, clientOptions) error {
 	t.auths++
+	t.expiring = false
 	return nil
 }
 
+func (t *testRoot) clone() b2RootInterface { return t }
+
+func (t *testRoot) tokenExpiring(time.Duration) bool { return t.expiring }
+func (t *testRoot) clockSkew() time.Duration         { return 0 }
+func (t *testRoot) capabilities() []string           { return t.caps }
+
 func (t *testRoot) backoff(err error) time.Duration {
 	e, ok := err.(testError)
 	if !ok {
@@ -100,6 +119,13 @@ func (t *testRoot) reupload(err error) bool {
 	return e.reupload
 }
 
+func (t *testRoot) method(err error) string {
+	if _, ok := err.(testError); ok {
+		return "test_method"
+	}
+	return ""
+}
+
 func (t *testRoot) transient(err error) bool {
 	e, ok := err.(testError)
 	if !ok {
@@ -125,36 +151,90 @@ func (t *testRoot) createBucket(_ context.Context, name, _ string, _ map[string]
 	m := make(map[string]string)
 	t.bucketMap[name] = m
 	return &testBucket{
-		n:     name,
-		errs:  t.errs,
-		files: m,
+		n:         name,
+		errs:      t.errs,
+		files:     m,
+		attrsMap:  t.attrsMap,
+		notifyMap: t.notifyMap,
 	}, nil
 }
 
 func (t *testRoot) listBuckets(context.Context, string) ([]b2BucketInterface, error) {
+	t.lists++
 	var b []b2BucketInterface
 	for k, v := range t.bucketMap {
 		b = append(b, &testBucket{
-			n:     k,
-			errs:  t.errs,
-			files: v,
+			n:         k,
+			errs:      t.errs,
+			files:     v,
+			attrsMap:  t.attrsMap,
+			notifyMap: t.notifyMap,
 		})
 	}
 	return b, nil
 }
 
 type testBucket struct {
-	n     string
-	errs  *errCont
-	files map[string]string
+	n         string
+	errs      *errCont
+	files     map[string]string
+	attrsMap  map[string]*BucketAttrs
+	notifyMap map[string][]NotificationRule
+}
+
+func (t *testBucket) name() string                       { return t.n }
+func (t *testBucket) btype() string                      { return "allPrivate" }
+func (t *testBucket) deleteBucket(context.Context) error { return nil }
+func (t *testBucket) id() string                         { return "" }
+
+func (t *testBucket) updateBucket(_ context.Context, attrs *BucketAttrs) error {
+	if err := t.errs.getError("updateBucket"); err != nil {
+		return err
+	}
+	if t.attrsMap == nil {
+		return nil
+	}
+	// Like B2, leave alone what attrs does not set.
+	n := t.attrs()
+	if attrs.Type != UnknownType {
+		n.Type = attrs.Type
+	}
+	if attrs.Info != nil {
+		n.Info = attrs.Info
+	}
+	if attrs.LifecycleRules != nil {
+		n.LifecycleRules = attrs.LifecycleRules
+	}
+	if attrs.CORSRules != nil {
+		n.CORSRules = attrs.CORSRules
+	}
+	if attrs.DefaultEncryption != UnknownEncryption {
+		n.DefaultEncryption = attrs.DefaultEncryption
+	}
+	if attrs.Replication != nil {
+		n.Replication = attrs.Replication
+	}
+	t.attrsMap[t.n] = n.copy()
+	return nil
+}
+
+func (t *testBucket) attrs() *BucketAttrs {
+	if attrs, ok := t.attrsMap[t.n]; ok {
+		return attrs.copy()
+	}
+	return &BucketAttrs{Type: Private, Info: map[string]string{"name": t.n}}
 }
 
-func (t *testBucket) name() string                                     { return t.n }
-func (t *testBucket) btype() string                                    { return "allPrivate" }
-func (t *testBucket) attrs() *BucketAttrs                              { return nil }
-func (t *testBucket) deleteBucket(context.Context) error               { return nil }
-func (t *testBucket) updateBucket(context.Context, *BucketAttrs) error { return nil }
-func (t *testBucket) id() string                                       { return "" }
+func (t *testBucket) getNotificationRules(context.Context) ([]NotificationRule, error) {
+	return append([]NotificationRule(nil), t.notifyMap[t.n]...), nil
+}
+
+func (t *testBucket) setNotificationRules(_ context.Context, rules []NotificationRule) error {
+	if t.notifyMap != nil {
+		t.notifyMap[t.n] = append([]NotificationRule{}, rules...)
+	}
+	return nil
+}
 
 func (t *testBucket) getUploadURL(context.Context) (b2URLInterface, error) {
 	if err := t.errs.getError("getUploadURL"); err != nil {
@@ -174,14 +254,36 @@ func (t *testBucket) startLargeFile(_ context.Context, name, _ string, _ map[str
 	}, nil
 }
 
-func (t *testBucket) listFileNames(ctx context.Context, count int, cont, pfx, del string) ([]b2FileInterface, string, error) {
+func (t *testBucket) listFileNames(ctx context.Context, count int, cont, pfx, del string, fn func(b2FileInterface)) (string, error) {
+	fs, next, err := t.listFiles(count, cont, pfx)
+	if err != nil {
+		return "", err
+	}
+	for i, f := range fs {
+		fn(f)
+		if i == 0 {
+			// The connection can drop partway through a page.
+			if err := t.errs.getError("listFileNames"); err != nil {
+				return "", err
+			}
+		}
+	}
+	return next, nil
+}
+
+func (t *testBucket) listFiles(count int, cont, pfx string) ([]b2FileInterface, string, error) {
 	var f []string
 	gmux.Lock()
 	defer gmux.Unlock()
 	for name := range t.files {
-		f = append(f, name)
+		if strings.HasPrefix(name, pfx) {
+			f = append(f, name)
+		}
 	}
 	sort.Strings(f)
+	if count == 0 {
+		count = 100 // B2's default
+	}
 	idx := sort.SearchStrings(f, cont)
 	var b []b2FileInterface
 	var next string
@@ -189,6 +291,7 @@ func (t *testBucket) listFileNames(ctx context.Context, count int, cont, pfx, de
 		b = append(b, &testFile{
 			n:     f[i],
 			s:     int64(len(t.files[f[i]])),
+			a:     "upload",
 			files: t.files,
 		})
 		if i+1 < len(f) {
@@ -202,7 +305,7 @@ func (t *testBucket) listFileNames(ctx context.Context, count int, cont, pfx, de
 }
 
 func (t *testBucket) listFileVersions(ctx context.Context, count int, a, b, c, d string) ([]b2FileInterface, string, string, error) {
-	x, y, z := t.listFileNames(ctx, count, a, c, d)
+	x, y, z := t.listFiles(count, a, c)
 	return x, y, "", z
 }
 
@@ -221,34 +324,67 @@ func (t *testBucket) downloadFileByName(_ context.Context, name string, offset,
 	if int(offset) >= len(f) {
 		return nil, errNoMoreContent
 	}
-	return &testFileReader{
-		b: ioutil.NopCloser(bytes.NewBufferString(f[offset:end])),
+	var r io.Reader = strings.NewReader(f[offset:end])
+	if t.errs != nil {
+		if err := t.errs.getError("downloadFileByName.read"); err != nil {
+			// Drop the connection halfway through.
+			mid := int(offset) + (end-int(offset))/2
+			r = io.MultiReader(strings.NewReader(f[offset:mid]), errReader{err})
+		}
+	}
+	fr := &testFileReader{
+		b: ioutil.NopCloser(r),
 		s: end - int(offset),
 		n: name,
-	}, nil
+	}
+	if strings.HasSuffix(name, ".gz") {
+		// Stand in for objects uploaded with b2-content-encoding set.
+		fr.h = http.Header{"Content-Encoding": {"gzip"}}
+	}
+	return fr, nil
 }
 
 func (t *testBucket) hideFile(context.Context, string) (b2FileInterface, error) { return nil, nil }
 func (t *testBucket) getDownloadAuthorization(context.Context, string, time.Duration, string) (string, error) {
 	return "", nil
 }
-func (t *testBucket) baseURL() string                      { return "" }
-func (t *testBucket) file(id, name string) b2FileInterface { return nil }
+func (t *testBucket) baseURL() string { return "" }
+
+func (t *testBucket) file(id, name string) b2FileInterface {
+	if name == "" {
+		name = id // test file IDs are their names
+	}
+	return &testFile{n: name, files: t.files}
+}
 
 type testURL struct {
 	files map[string]string
 }
 
 func (t *testURL) reload(context.Context) error { return nil }
+func (t *testURL) endpoint() (string, string)   { return "", "" }
 
-func (t *testURL) uploadFile(_ context.Context, r io.Reader, _ int, name, _, _ string, _ map[string]string) (b2FileInterface, error) {
+func (t *testURL) uploadFile(ctx context.Context, r io.Reader, size int, name, _, hash string, _ map[string]string) (b2FileInterface, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	buf := &bytes.Buffer{}
 	if _, err := io.Copy(buf, r); err != nil {
 		return nil, err
 	}
+	if buf.Len() != size {
+		return nil, fmt.Errorf("uploadFile(%q): got %d bytes, want %d", name, buf.Len(), size)
+	}
+	data := buf.String()
+	if hash == sha1AtEnd && len(data) >= 40 {
+		data, hash = data[:len(data)-40], data[len(data)-40:]
+	}
+	if got := fmt.Sprintf("%x", sha1.Sum([]byte(data))); hash != sha1Unverified && hash != got {
+		return nil, fmt.Errorf("uploadFile(%q): bad sha1: got %s, want %s", name, got, hash)
+	}
 	gmux.Lock()
 	defer gmux.Unlock()
-	t.files[name] = buf.String()
+	t.files[name] = data
 	return &testFile{
 		n:     name,
 		s:     int64(len(t.files[name])),
@@ -330,13 +466,35 @@ func (t *testFile) compileParts(int64, map[int]string) b2LargeFileInterface {
 }
 
 func (t *testFile) getFileInfo(context.Context) (b2FileInfoInterface, error) {
-	return nil, nil
+	gmux.Lock()
+	defer gmux.Unlock()
+	return &testFileInfo{f: t, size: int64(len(t.files[t.n]))}, nil
+}
+
+type testFileInfo struct {
+	f    *testFile
+	size int64
+}
+
+func (t *testFileInfo) stats() (string, string, int64, string, map[string]string, string, time.Time) {
+	return t.f.n, "", t.size, "", map[string]string{}, t.f.a, t.f.t
 }
 
 func (t *testFile) listParts(context.Context, int, int) ([]b2FilePartInterface, int, error) {
 	return nil, 0, nil
 }
 
+func (t *testFile) copyFile(_ context.Context, _, name, _ string, _ map[string]string) (b2FileInterface, error) {
+	gmux.Lock()
+	defer gmux.Unlock()
+	data, ok := t.files[t.n]
+	if !ok {
+		return nil, fmt.Errorf("copyFile: %s: no such file", t.n)
+	}
+	t.files[name] = data
+	return &testFile{n: name, s: t.s, t: time.Now(), files: t.files}, nil
+}
+
 func (t *testFile) deleteFileVersion(context.Context) error {
 	gmux.Lock()
 	defer gmux.Unlock()
@@ -348,12 +506,18 @@ type testFileReader struct {
 	b io.ReadCloser
 	s int
 	n string
+	h http.Header
 }
 
 func (t *testFileReader) Read(p []byte) (int, error)                      { return t.b.Read(p) }
 func (t *testFileReader) Close() error                                    { return nil }
 func (t *testFileReader) stats() (int, string, string, map[string]string) { return t.s, "", "", nil }
 func (t *testFileReader) id() string                                      { return t.n }
+func (t *testFileReader) header() http.Header                             { return t.h }
+
+type errReader struct{ err error }
+
+func (e errReader) Read([]byte) (int, error) { return 0, e.err }
 
 type zReader struct{}
 
@@ -876,6 +1040,34 @@ func TestNonBuffer(t *testing.T) {
 	}
 }
 
+func TestDataSum(t *testing.T) {
+	const str = "a string"
+	want := fmt.Sprintf("%x", sha1.Sum([]byte("tring")))
+
+	mb := newMemoryBuffer()
+	if _, err := io.WriteString(mb, "tring"); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []struct {
+		desc string
+		buf  writeBuffer
+	}{
+		{desc: "nonBuffer", buf: newNonBuffer(strings.NewReader(str), 3, 5)},
+		{desc: "unverified nonBuffer", buf: unverifiedBuffer{newNonBuffer(strings.NewReader(str), 3, 5)}},
+		{desc: "memoryBuffer", buf: mb},
+		{desc: "trailerBuffer", buf: newTrailerBuffer(mb)},
+	} {
+		size, got, err := dataSum(e.buf)
+		if err != nil {
+			t.Errorf("%s: %v", e.desc, err)
+			continue
+		}
+		if size != 5 || got != want {
+			t.Errorf("%s: got %d bytes with SHA-1 %s, want 5 with %s", e.desc, size, got, want)
+		}
+	}
+}
+
 func writeFile(ctx context.Context, bucket *Bucket, name string, size int64, csize int) (*Object, string, error) {
 	r := io.LimitReader(zReader{}, size)
 	o := bucket.Object(name)
@@ -914,3 +1106,2443 @@ func readFile(ctx context.Context, obj *Object, sha string, chunk, concur int) e
 	}
 	return nil
 }
+
+func TestListStart(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: make(map[string]map[string]string),
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.NewBucket(ctx, bucketName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, _, err := writeFile(ctx, bucket, name, 10, 1e8); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	iter := bucket.List(ctx, ListStart("b", ""), ListPageSize(10))
+	for iter.Next() {
+		got = append(got, iter.Object().Name())
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"b", "c"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("List(ListStart(%q)): got %v, want %v", "b", got, want)
+	}
+}
+
+func TestWriterFlush(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	files := make(map[string]string)
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: files},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func() string {
+		gmux.Lock()
+		defer gmux.Unlock()
+		return files["log"]
+	}
+
+	w := bucket.Object("log").NewWriter(ctx)
+	if err := w.Flush(ctx); err != nil {
+		t.Fatalf("Flush before Write: %v", err)
+	}
+	if _, err := io.WriteString(w, "first "); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != "first " {
+		t.Errorf("after first Flush: got %q, want %q", got, "first ")
+	}
+	if _, err := io.WriteString(w, "second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != "first second" {
+		t.Errorf("after second Flush: got %q, want %q", got, "first second")
+	}
+	// A Flush whose own context is canceled fails without harming the writer.
+	if _, err := io.WriteString(w, " third"); err != nil {
+		t.Fatal(err)
+	}
+	fctx, fcancel := context.WithCancel(ctx)
+	fcancel()
+	if err := w.Flush(fctx); err != context.Canceled {
+		t.Errorf("Flush with a canceled context: got %v, want %v", err, context.Canceled)
+	}
+	if got := read(); got != "first second" {
+		t.Errorf("after canceled Flush: got %q, want %q", got, "first second")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != "first second third" {
+		t.Errorf("after Close: got %q, want %q", got, "first second third")
+	}
+
+	// Large files: full parts are sent and waited for.
+	w = bucket.Object("big").NewWriter(ctx)
+	w.ChunkSize = minPartSize
+	want := int(2*minPartSize + minPartSize/2)
+	if _, err := io.Copy(w, io.LimitReader(zReader{}, int64(want))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gmux.Lock()
+	size := len(files["big"])
+	gmux.Unlock()
+	if size != want {
+		t.Errorf("large file: got %d bytes, want %d", size, want)
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: {}},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bucket.Object("foo").NewWriter(ctx)
+	if _, err := io.WriteString(w, "data"); err != nil {
+		t.Fatal(err)
+	}
+	// A second writer to the same name is tracked separately.
+	w2 := bucket.Object("foo").NewWriter(ctx)
+	if _, err := io.WriteString(w2, "data"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := writeFile(ctx, bucket, "bar", 10, 1e8); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	for _, w := range []*Writer{w, w2} {
+		if w.ctx.Err() == nil {
+			t.Error("outstanding Writer not canceled by Client.Close")
+		}
+	}
+	if u := bucket.urlPool.get(); u != nil {
+		t.Error("upload URL pool not drained")
+	}
+	bucket.urlPool.put(&beURL{})
+	if u := bucket.urlPool.get(); u != nil {
+		t.Error("upload URL pool accepted a URL after Close")
+	}
+}
+
+func TestBucketAttrsCache(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	table := []struct {
+		ttl   time.Duration
+		lists int
+	}{
+		{ttl: 0, lists: 4},
+		{ttl: time.Hour, lists: 2},
+	}
+
+	for _, e := range table {
+		root := &testRoot{
+			bucketMap: map[string]map[string]string{bucketName: {}},
+			errs:      &errCont{},
+		}
+		client := &Client{
+			backend: &beRoot{b2i: root},
+			opts:    clientOptions{attrsTTL: e.ttl},
+		}
+		bucket, err := client.Bucket(ctx, bucketName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root.lists = 0
+		for i := 0; i < 3; i++ {
+			attrs, err := bucket.Attrs(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if attrs.Info["name"] != bucketName {
+				t.Errorf("ttl %v: Attrs().Info = %v", e.ttl, attrs.Info)
+			}
+			// Callers may modify the returned attributes.
+			attrs.Info["name"] = "clobbered"
+		}
+		if err := bucket.Update(ctx, &BucketAttrs{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := bucket.Attrs(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if root.lists != e.lists {
+			t.Errorf("ttl %v: got %d bucket lists, want %d", e.ttl, root.lists, e.lists)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	files := map[string]string{"keep": "data"}
+	var ops []DryRunOp
+	client := &Client{
+		backend: &beRoot{
+			b2i: &dryRunRoot{
+				b2RootInterface: &testRoot{
+					bucketMap: map[string]map[string]string{bucketName: files},
+					errs:      &errCont{},
+				},
+				record: func(op DryRunOp) { ops = append(ops, op) },
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := writeFile(ctx, bucket, "small", 1e3, 1e4); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := writeFile(ctx, bucket, "large", 3e4, 1e4); err != nil {
+		t.Fatal(err)
+	}
+	if err := bucket.Object("keep").Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := bucket.Update(ctx, &BucketAttrs{Type: Public}); err != nil {
+		t.Fatal(err)
+	}
+	r := bucket.Object("keep").NewReader(ctx)
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if string(got) != "data" {
+		t.Errorf("read %q, want %q", got, "data")
+	}
+
+	want := []DryRunOp{
+		{Method: "b2_upload_file", Bucket: bucketName, Name: "small", Size: 1e3},
+		{Method: "b2_start_large_file", Bucket: bucketName, Name: "large"},
+		{Method: "b2_finish_large_file", Bucket: bucketName, Name: "large", Size: 3e4},
+		{Method: "b2_delete_file_version", Bucket: bucketName, Name: "keep"},
+		{Method: "b2_update_bucket", Bucket: bucketName},
+	}
+	if !reflect.DeepEqual(ops, want) {
+		t.Errorf("dry run ops: got %v, want %v", ops, want)
+	}
+	gmux.Lock()
+	defer gmux.Unlock()
+	if len(files) != 1 || files["keep"] != "data" {
+		t.Errorf("bucket modified in dry-run mode: %v", files)
+	}
+}
+
+func TestUpdateWith(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	conflict := b2err{err: errors.New("conflict"), isUpdateConflict: true}
+	root := &testRoot{
+		bucketMap: map[string]map[string]string{bucketName: {}},
+		errs: &errCont{
+			errMap: map[string]map[int]error{
+				"updateBucket": {0: conflict, 1: conflict},
+			},
+		},
+	}
+	client := &Client{
+		backend: &beRoot{b2i: root},
+		opts:    clientOptions{attrsTTL: time.Hour},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bucket.Attrs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	root.lists = 0
+
+	var calls int
+	if err := bucket.UpdateWith(ctx, func(attrs *BucketAttrs) error {
+		calls++
+		attrs.Type = Public
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("UpdateWith: mutator called %d times, want 3", calls)
+	}
+	// Every attempt should fetch fresh attributes, even with a warm cache.
+	if root.lists != 3 {
+		t.Errorf("UpdateWith: got %d bucket lists, want 3", root.lists)
+	}
+
+	abort := errors.New("abort")
+	if err := bucket.UpdateWith(ctx, func(*BucketAttrs) error { return abort }); err != abort {
+		t.Errorf("UpdateWith: got %v, want %v", err, abort)
+	}
+}
+
+func TestReaderMaxBufferedBytes(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: {}},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, wsha, err := writeFile(ctx, bucket, smallFileName, 1e4+42, 1e8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := []struct {
+		chunk, concur, max int
+		csize, threads     int
+	}{
+		{chunk: 100, concur: 10, max: 0, csize: 100, threads: 10},
+		{chunk: 100, concur: 10, max: 350, csize: 100, threads: 3},
+		{chunk: 100, concur: 10, max: 1e6, csize: 100, threads: 10},
+		{chunk: 100, concur: 10, max: 50, csize: 50, threads: 1},
+	}
+	for _, e := range table {
+		r := obj.NewReader(ctx)
+		r.ChunkSize = e.chunk
+		r.ConcurrentDownloads = e.concur
+		r.MaxBufferedBytes = e.max
+		h := sha1.New()
+		if _, err := io.Copy(h, r); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprintf("%x", h.Sum(nil)); got != wsha {
+			t.Errorf("%+v: bad hash: got %s, want %s", e, got, wsha)
+		}
+		if r.csize != e.csize || cap(r.chbuf) != e.threads {
+			t.Errorf("%+v: got chunk size %d and %d downloads, want %d and %d", e, r.csize, cap(r.chbuf), e.csize, e.threads)
+		}
+	}
+}
+
+func TestWriterS3ETag(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: {}},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 25e3)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	sum := func(p []byte) []byte { s := md5.Sum(p); return s[:] }
+	whole := fmt.Sprintf("%x", sum(data))
+	h := md5.New()
+	h.Write(sum(data[:1e4]))
+	h.Write(sum(data[1e4:2e4]))
+	h.Write(sum(data[2e4:]))
+	multi := fmt.Sprintf("%x-3", h.Sum(nil))
+
+	table := []struct {
+		csize int
+		seek  bool
+		want  string
+	}{
+		{csize: 1e5, want: whole},
+		{csize: 1e5, seek: true, want: whole},
+		{csize: 1e4, want: multi},
+		{csize: 1e4, seek: true, want: multi},
+	}
+	for _, e := range table {
+		w := bucket.Object("etag").NewWriter(ctx, WithS3ETag())
+		w.ChunkSize = e.csize
+		var r io.Reader = bytes.NewReader(data)
+		if !e.seek {
+			r = io.LimitReader(r, int64(len(data)))
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.S3ETag(); got != e.want {
+			t.Errorf("chunk size %d, seekable %v: got ETag %q, want %q", e.csize, e.seek, got, e.want)
+		}
+	}
+}
+
+func TestWriterManifest(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: {}},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 25e3)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	table := []struct {
+		csize int
+		seek  bool
+		parts int
+	}{
+		{csize: 1e5, parts: 1},
+		{csize: 1e5, seek: true, parts: 1},
+		{csize: 1e4, parts: 3},
+		{csize: 1e4, seek: true, parts: 3},
+	}
+	for _, e := range table {
+		w := bucket.Object("manifest").NewWriter(ctx, WithManifest())
+		w.ChunkSize = e.csize
+		var r io.Reader = bytes.NewReader(data)
+		if !e.seek {
+			r = io.LimitReader(r, int64(len(data)))
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		m := w.Manifest()
+		if m == nil {
+			t.Fatalf("chunk size %d, seekable %v: no manifest", e.csize, e.seek)
+		}
+		if len(m.Parts) != e.parts || m.Size != int64(len(data)) {
+			t.Errorf("chunk size %d, seekable %v: got %d parts, %d bytes; want %d parts, %d bytes", e.csize, e.seek, len(m.Parts), m.Size, e.parts, len(data))
+		}
+		if err := verifyManifest(ctx, bytes.NewReader(data), m.encode(), int64(e.csize)); err != nil {
+			t.Errorf("chunk size %d, seekable %v: %v", e.csize, e.seek, err)
+		}
+		bad := append([]byte(nil), data...)
+		bad[len(bad)-1]++
+		if err := verifyManifest(ctx, bytes.NewReader(bad), m.encode(), int64(e.csize)); err == nil {
+			t.Errorf("chunk size %d, seekable %v: corrupt data passed verification", e.csize, e.seek)
+		}
+	}
+}
+
+func TestReaderDecompress(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	want := strings.Repeat("compress me ", 1e4)
+	zbuf := &bytes.Buffer{}
+	zw := gzip.NewWriter(zbuf)
+	io.WriteString(zw, want)
+	zw.Close()
+	stored := zbuf.String()
+
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{
+					bucketName: {"plain": want, "data.gz": stored},
+				},
+				errs: &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := []struct {
+		name       string
+		decompress bool
+		stored     string
+		want       string
+		decoding   bool
+		length     int64 // if positive, read a range of this length
+	}{
+		{name: "plain", decompress: true, stored: want, want: want},
+		{name: "data.gz", stored: stored, want: stored},
+		{name: "data.gz", decompress: true, stored: stored, want: want, decoding: true},
+		// Ranges are never decompressed, even if they cover the whole object.
+		{name: "data.gz", decompress: true, stored: stored, want: stored, length: int64(len(stored))},
+	}
+	for _, e := range table {
+		r := bucket.Object(e.name).NewReader(ctx)
+		if e.length > 0 {
+			r = bucket.Object(e.name).NewRangeReader(ctx, 0, e.length)
+		}
+		r.ChunkSize = 100
+		r.ConcurrentDownloads = 3
+		r.Decompress = e.decompress
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s (decompress %v): %v", e.name, e.decompress, err)
+		}
+		if string(got) != e.want {
+			t.Errorf("%s (decompress %v): got %d bytes, want %d", e.name, e.decompress, len(got), len(e.want))
+		}
+		if r.Decompressing() != e.decoding {
+			t.Errorf("%s (decompress %v): Decompressing() = %v, want %v", e.name, e.decompress, r.Decompressing(), e.decoding)
+		}
+		if raw, decoded := r.Sizes(); raw != int64(len(e.stored)) || decoded != int64(len(e.want)) {
+			t.Errorf("%s (decompress %v): Sizes() = %d, %d; want %d, %d", e.name, e.decompress, raw, decoded, len(e.stored), len(e.want))
+		}
+		r.Close()
+	}
+}
+
+func TestWriterParts(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: {}},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 25e3)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	part := func(n int, p []byte) Part {
+		return Part{Number: n, Size: int64(len(p)), SHA1: fmt.Sprintf("%x", sha1.Sum(p))}
+	}
+	want := []Part{
+		part(1, data[:1e4]),
+		part(2, data[1e4:2e4]),
+		part(3, data[2e4:]),
+	}
+
+	for _, seek := range []bool{false, true} {
+		w := bucket.Object("parts").NewWriter(ctx)
+		w.ChunkSize = 1e4
+		w.ConcurrentUploads = 3
+		var r io.Reader = bytes.NewReader(data)
+		if !seek {
+			r = io.LimitReader(r, int64(len(data)))
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.Parts(); !reflect.DeepEqual(got, want) {
+			t.Errorf("seekable %v: got parts %v, want %v", seek, got, want)
+		}
+	}
+
+	w := bucket.Object("small").NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Parts(); got != nil {
+		t.Errorf("small file: got parts %v, want none", got)
+	}
+}
+
+func TestFailFastOnDeadline(t *testing.T) {
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: {}},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(context.Background(), bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := []struct {
+		deadline time.Duration
+		failFast bool
+		unread   int64
+		wantErr  bool
+	}{
+		{deadline: time.Minute, failFast: true},
+		{deadline: 5 * time.Second, failFast: true, wantErr: true},
+		{deadline: 5 * time.Second},
+		{deadline: 30 * time.Second, failFast: true, unread: 1e5, wantErr: true},
+	}
+	for _, e := range table {
+		ctx, cancel := context.WithTimeout(context.Background(), e.deadline)
+		var opts []WriterOption
+		if e.failFast {
+			opts = append(opts, FailFastOnDeadline())
+		}
+		w := bucket.Object("deadline").NewWriter(ctx, opts...)
+		w.init()
+		// 1000B/s so far, with 1e4 bytes buffered: about ten seconds left.
+		w.upStart = time.Now().Add(-time.Second)
+		w.sentBytes = 1e3
+		w.doneBytes = 1e3
+		if _, err := w.w.Write(make([]byte, 1e4)); err != nil {
+			t.Fatal(err)
+		}
+		if e.unread > 0 {
+			w.unread = func() int64 { return e.unread }
+		}
+		err := w.checkDeadline()
+		if _, ok := err.(*UploadDeadlineError); ok != e.wantErr {
+			t.Errorf("%+v: got %v, want error: %v", e, err, e.wantErr)
+		}
+		cancel()
+	}
+}
+
+func TestDeleteAllVersions(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	files := map[string]string{
+		"foo":     "a",
+		"foo/bar": "b",
+		"foobar":  "c",
+	}
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: files},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bucket.Object("foo").DeleteAllVersions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	gmux.Lock()
+	want := map[string]string{"foo/bar": "b", "foobar": "c"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("after DeleteAllVersions: got %v, want %v", files, want)
+	}
+	gmux.Unlock()
+	if err := bucket.Object("foo").DeleteAllVersions(ctx); !IsNotExist(err) {
+		t.Errorf("DeleteAllVersions of missing object: got %v, want not-exist error", err)
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	files := map[string]string{"foo": "a"}
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: files},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bucket.Object("foo").CopyTo(ctx, bucket.Object("bar")); err != nil {
+		t.Fatal(err)
+	}
+	gmux.Lock()
+	want := map[string]string{"foo": "a", "bar": "a"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("after CopyTo: got %v, want %v", files, want)
+	}
+	gmux.Unlock()
+}
+
+func TestDownloadTo(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	const data = "0123456789abcdefghijklmnopqrstuvwxyz"
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: {"foo": data}},
+				errs: &errCont{errMap: map[string]map[int]error{
+					"downloadFileByName.read": {0: io.ErrUnexpectedEOF},
+				}},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cpath := filepath.Join(dir, "foo.checkpoint")
+	header := fmt.Sprintf("blazer-checkpoint v1 foo %d 10\n", len(data))
+
+	for _, e := range []struct {
+		desc       string
+		checkpoint string // the contents of the checkpoint before the download
+		want       string
+	}{
+		{
+			desc: "no checkpoint",
+			want: data,
+		},
+		{
+			desc:       "parts 0 and 2 done",
+			checkpoint: header + "0\n2\n",
+			want:       "XXXXXXXXXXabcdefghijXXXXXXXXXXuvwxyz",
+		},
+		{
+			desc:       "a different part size",
+			checkpoint: "blazer-checkpoint v1 foo 36 5\n0\n1\n",
+			want:       data,
+		},
+		{
+			desc:       "a torn write",
+			checkpoint: header + "1\n3",
+			want:       "0123456789XXXXXXXXXXklmnopqrstuvwxyz",
+		},
+	} {
+		// Fill the destination with junk, so that parts that are not
+		// downloaded show.
+		dst, err := os.Create(filepath.Join(dir, "foo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dst.WriteString(strings.Repeat("X", len(data))); err != nil {
+			t.Fatal(err)
+		}
+		if e.checkpoint != "" {
+			if err := ioutil.WriteFile(cpath, []byte(e.checkpoint), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := bucket.Object("foo").DownloadTo(ctx, dst, DownloadPartSize(10), DownloadWorkers(2), WithCheckpoint(cpath)); err != nil {
+			t.Errorf("%s: DownloadTo: %v", e.desc, err)
+		}
+		dst.Close()
+		got, err := ioutil.ReadFile(dst.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != e.want {
+			t.Errorf("%s: got %q, want %q", e.desc, got, e.want)
+		}
+		if _, err := os.Stat(cpath); !os.IsNotExist(err) {
+			t.Errorf("%s: checkpoint not removed: %v", e.desc, err)
+		}
+	}
+}
+
+func TestCreateKeyCapabilities(t *testing.T) {
+	ctx := context.Background()
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: make(map[string]map[string]string),
+				errs:      &errCont{},
+			},
+		},
+	}
+	table := []struct {
+		opts    []KeyOption
+		wantErr bool
+	}{
+		{opts: []KeyOption{Grant(CapListBuckets, CapReadFiles)}},
+		{opts: []KeyOption{Capabilities("listBuckets", "writeFiles")}},
+		// Strings are passed through, for capabilities B2 adds later.
+		{opts: []KeyOption{Capabilities("someNewCap")}},
+		{opts: []KeyOption{Grant(CapReadFiles), Capabilities("readFile")}},
+		{opts: []KeyOption{Grant(Capability("writeFile"))}, wantErr: true},
+	}
+	for i, e := range table {
+		_, err := client.CreateKey(ctx, "key", e.opts...)
+		if (err != nil) != e.wantErr {
+			t.Errorf("%d: CreateKey: got error %v, want error: %v", i, err, e.wantErr)
+		}
+	}
+	for _, c := range AllCapabilities() {
+		if err := ValidateCapabilities(string(c)); err != nil {
+			t.Errorf("ValidateCapabilities(%q): %v", c, err)
+		}
+	}
+}
+
+func TestWriterDedup(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	sum := func(s string) string { return fmt.Sprintf("%x", sha1.Sum([]byte(s))) }
+	files := map[string]string{"a": "alpha"}
+	errs := &errCont{errMap: map[string]map[int]error{}}
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: files},
+				errs:      errs,
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryDedupStore()
+	store.Put(sum("alpha"), "a")
+	store.Put(sum("gamma"), "gone")
+
+	table := []struct {
+		name, data string
+		seek       bool
+		urls       int    // calls to getUploadURL so far; URLs are reused
+		id         string // the version the store holds afterwards
+	}{
+		{name: "b", data: "alpha", urls: 0, id: "a"},
+		{name: "c", data: "alpha", seek: true, urls: 0, id: "a"},
+		{name: "d", data: "beta", urls: 1, id: "d"},
+		{name: "e", data: "beta", seek: true, urls: 1, id: "d"},
+		{name: "f", data: "gamma", urls: 1, id: "f"}, // the stored version is gone
+	}
+	for _, e := range table {
+		w := bucket.Object(e.name).NewWriter(ctx, WithDedup(store))
+		var r io.Reader = strings.NewReader(e.data)
+		if !e.seek {
+			r = io.LimitReader(r, int64(len(e.data)))
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		gmux.Lock()
+		got := files[e.name]
+		gmux.Unlock()
+		if got != e.data {
+			t.Errorf("%s: got %q, want %q", e.name, got, e.data)
+		}
+		if n := errs.opMap["getUploadURL"]; n != e.urls {
+			t.Errorf("%s: got %d upload URLs, want %d", e.name, n, e.urls)
+		}
+		if id, _ := store.Get(sum(e.data)); id != e.id {
+			t.Errorf("%s: store has %q for %q, want %q", e.name, id, e.data, e.id)
+		}
+	}
+}
+
+func TestFileDedupStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup")
+	s, err := OpenDedupFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put("aaaa", "1")
+	s.Put("bbbb", "2")
+	s.Put("aaaa", "3")
+	s.Delete("bbbb")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = OpenDedupFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if id, ok := s.Get("aaaa"); !ok || id != "3" {
+		t.Errorf("Get(aaaa): got %q, %v; want 3, true", id, ok)
+	}
+	if id, ok := s.Get("bbbb"); ok {
+		t.Errorf("Get(bbbb): got %q, want nothing", id)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	files := map[string]string{
+		"logs/2018/a": "aaaa",
+		"logs/2018/b": "bb",
+		"logs/2019/c": "c",
+		"logs/index":  "idx",
+		"other":       "ignored",
+	}
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: files},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := []struct {
+		prefix string
+		opts   []UsageOption
+		want   *Usage
+	}{
+		{
+			want: &Usage{Objects: 5, Bytes: 17},
+		},
+		{
+			prefix: "logs/",
+			want:   &Usage{Objects: 4, Bytes: 10},
+		},
+		{
+			prefix: "logs/",
+			opts:   []UsageOption{UsageByPrefix("/")},
+			want: &Usage{
+				Objects: 4,
+				Bytes:   10,
+				Prefixes: map[string]*Usage{
+					"logs/2018/": {Objects: 2, Bytes: 6},
+					"logs/2019/": {Objects: 1, Bytes: 1},
+					"logs/index": {Objects: 1, Bytes: 3},
+				},
+			},
+		},
+	}
+	for _, e := range table {
+		got, err := bucket.Usage(ctx, e.prefix, e.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, e.want) {
+			t.Errorf("Usage(%q): got %+v, want %+v", e.prefix, got, e.want)
+		}
+	}
+}
+
+type countingTransport struct {
+	mu       sync.Mutex
+	cur, max int
+}
+
+func (ct *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ct.mu.Lock()
+	ct.cur++
+	if ct.cur > ct.max {
+		ct.max = ct.cur
+	}
+	ct.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	ct.mu.Lock()
+	ct.cur--
+	ct.mu.Unlock()
+	return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	table := []struct {
+		method string
+		max    int
+	}{
+		{method: "b2_upload_part", max: 2},
+		{method: "b2_download_file_by_name", max: 3},
+		{method: "b2_list_file_names", max: 1},
+	}
+
+	for _, e := range table {
+		rt := &countingTransport{}
+		c := &Client{}
+		MaxConcurrentRequests(2, 3, 1)(&c.opts)
+		c.limits = newRequestLimiter(c.opts)
+		ct := &clientTransport{client: c, rt: rt}
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req, err := http.NewRequest("POST", "http://b2.example/", nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				req.Header.Set("X-Blazer-Method", e.method)
+				resp, err := ct.RoundTrip(req.WithContext(ctx))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+		if rt.max > e.max {
+			t.Errorf("%s: got %d concurrent requests, want at most %d", e.method, rt.max, e.max)
+		}
+	}
+
+	c := &Client{limits: &requestLimiter{other: newSemaphore(1)}}
+	ct := &clientTransport{client: c, rt: &countingTransport{}}
+	release, err := c.limits.acquire(ctx, "b2_list_buckets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	cctx, ccancel := context.WithCancel(ctx)
+	ccancel()
+	req, _ := http.NewRequest("POST", "http://b2.example/", nil)
+	if _, err := ct.RoundTrip(req.WithContext(cctx)); err != context.Canceled {
+		t.Errorf("RoundTrip with canceled context: got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestReadOnlyKey(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	files := map[string]string{"foo": "a"}
+	root := &testRoot{
+		bucketMap: map[string]map[string]string{bucketName: files},
+		errs:      &errCont{},
+		caps:      []string{"listBuckets", "listFiles", "readFiles"},
+	}
+	client := &Client{backend: &beRoot{b2i: root}}
+	if !client.ReadOnly() {
+		t.Error("ReadOnly: got false, want true")
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := bucket.Object("bar").NewWriter(ctx)
+	if _, err := io.WriteString(w, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); !errors.Is(err, ErrReadOnlyKey) {
+		t.Errorf("Writer.Close: got %v, want %v", err, ErrReadOnlyKey)
+	}
+	if err := bucket.Object("foo").Delete(ctx); !errors.Is(err, ErrReadOnlyKey) {
+		t.Errorf("Delete: got %v, want %v", err, ErrReadOnlyKey)
+	}
+	gmux.Lock()
+	if want := map[string]string{"foo": "a"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("after failed writes: got %v, want %v", files, want)
+	}
+	gmux.Unlock()
+
+	// A key that can write but not delete is not read-only, but still may not
+	// delete.
+	root.caps = append(root.caps, "writeFiles")
+	if client.ReadOnly() {
+		t.Error("ReadOnly with writeFiles: got true, want false")
+	}
+	err = bucket.Object("foo").Delete(ctx)
+	var roe *ReadOnlyKeyError
+	if !errors.As(err, &roe) || roe.Capability != CapDeleteFiles {
+		t.Errorf("Delete: got %v, want a ReadOnlyKeyError for %s", err, CapDeleteFiles)
+	}
+
+	root.caps = nil
+	if client.ReadOnly() {
+		t.Error("ReadOnly with unknown capabilities: got true, want false")
+	}
+}
+
+type requestIDTransport struct {
+	n      int
+	status int
+}
+
+func (rt *requestIDTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.n++
+	return &http.Response{
+		StatusCode: rt.status,
+		Header:     http.Header{"X-Bz-Request-Id": {fmt.Sprintf("req-%d", rt.n)}},
+		Body:       ioutil.NopCloser(strings.NewReader("{}")),
+	}, nil
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	c := &Client{}
+	AuditLog(2)(&c.opts)
+	c.audit = newAuditLog(c.opts.auditSize)
+	rt := &requestIDTransport{status: 503}
+	ct := &clientTransport{client: c, rt: rt}
+	ri := &beRoot{b2i: &testRoot{}, options: clientOptions{clock: &fakeClock{}}}
+
+	// Three attempts at one call, of which the log keeps the last two.
+	var attempts int
+	err := withBackoff(withBucket(ctx, bucketName), ri, func(ctx context.Context) error {
+		req, err := http.NewRequest("POST", "http://b2.example/", nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("X-Blazer-Method", "b2_list_file_names")
+		resp, err := ct.RoundTrip(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		attempts++
+		if attempts < 3 {
+			return testError{retry: true}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := c.RecentCalls()
+	if len(got) != 2 {
+		t.Fatalf("RecentCalls: got %d calls, want 2", len(got))
+	}
+	for i, call := range got {
+		want := APICall{
+			Method:    "b2_list_file_names",
+			Bucket:    bucketName,
+			Status:    503,
+			Retries:   i + 1,
+			RequestID: fmt.Sprintf("req-%d", i+2),
+		}
+		call.Start, call.Duration = time.Time{}, 0
+		if !reflect.DeepEqual(call, want) {
+			t.Errorf("RecentCalls()[%d]: got %+v, want %+v", i, call, want)
+		}
+	}
+
+	if calls := (&Client{}).RecentCalls(); calls != nil {
+		t.Errorf("RecentCalls without AuditLog: got %v, want nil", calls)
+	}
+}
+
+// bucketFile records the bucket that each call on it is attributed to.
+type bucketFile struct {
+	b2FileInterface
+	buckets []string
+}
+
+func (f *bucketFile) record(ctx context.Context) {
+	name, _ := ctx.Value(bucketKey).(string)
+	f.buckets = append(f.buckets, name)
+}
+
+func (f *bucketFile) deleteFileVersion(ctx context.Context) error {
+	f.record(ctx)
+	return nil
+}
+
+func (f *bucketFile) copyFile(ctx context.Context, _, _, _ string, _ map[string]string) (b2FileInterface, error) {
+	f.record(ctx)
+	return f, nil
+}
+
+func TestFileCallBucket(t *testing.T) {
+	ctx := context.Background()
+	ri := &beRoot{b2i: &testRoot{}}
+	bf := &bucketFile{}
+	src := &beFile{b2file: bf, ri: ri, bucket: "src"}
+	dst := &beBucket{b2bucket: &testBucket{n: "dst"}, ri: ri}
+
+	cp, err := src.copyFile(ctx, dst, "copy", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.deleteFileVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.deleteFileVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"src", "src", "dst"}
+	if !reflect.DeepEqual(bf.buckets, want) {
+		t.Errorf("call buckets: got %q, want %q", bf.buckets, want)
+	}
+}
+
+func TestMaxRequestRate(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	c := &Client{}
+	MaxRequestRate(0, 0, 2, time.Hour)(&c.opts)
+	c.limits = newRequestLimiter(c.opts)
+	ct := &clientTransport{client: c, rt: &countingTransport{}}
+	call := func(ctx context.Context, method string) error {
+		req, err := http.NewRequest("POST", "http://b2.example/", nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("X-Blazer-Method", method)
+		resp, err := ct.RoundTrip(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	for i := 0; i < 2; i++ {
+		if err := call(ctx, "b2_list_file_names"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Uploads have no limit of their own.
+	for i := 0; i < 5; i++ {
+		if err := call(ctx, "b2_upload_part"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sctx, scancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer scancel()
+	if err := call(sctx, "b2_list_buckets"); err != context.DeadlineExceeded {
+		t.Errorf("third call in an hour: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestMaxRequestRateClock(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	clock := &fakeClock{now: time.Unix(1500000000, 0)}
+	c := &Client{}
+	MaxRequestRate(0, 0, 1, time.Hour)(&c.opts)
+	WithClock(clock)(&c.opts)
+	c.limits = newRequestLimiter(c.opts)
+	ct := &clientTransport{client: c, rt: &countingTransport{}}
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("POST", "http://b2.example/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Blazer-Method", "b2_list_buckets")
+		resp, err := ct.RoundTrip(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	var waited time.Duration
+	for _, d := range clock.waits {
+		waited += d
+	}
+	if waited < time.Hour-3*time.Minute {
+		t.Errorf("second call in an hour waited %v on the clock, want about an hour", waited)
+	}
+}
+
+func TestReaderResume(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	dropped := errors.New("connection reset by peer")
+	table := []struct {
+		resumes int
+		drops   int
+		wantErr bool
+	}{
+		{drops: 0},
+		{drops: 3},
+		{resumes: 2, drops: 2},
+		{resumes: 2, drops: 3, wantErr: true},
+		{resumes: -1, drops: 1, wantErr: true},
+	}
+	for _, e := range table {
+		drops := make(map[int]error)
+		for i := 0; i < e.drops; i++ {
+			drops[i] = dropped
+		}
+		client := &Client{
+			backend: &beRoot{
+				b2i: &testRoot{
+					bucketMap: map[string]map[string]string{bucketName: {}},
+					errs: &errCont{
+						errMap: map[string]map[int]error{"downloadFileByName.read": drops},
+					},
+				},
+			},
+		}
+		bucket, err := client.Bucket(ctx, bucketName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj, wsha, err := writeFile(ctx, bucket, smallFileName, 1e4, 1e8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := obj.NewReader(ctx)
+		r.ResumeAttempts = e.resumes
+		h := sha1.New()
+		_, err = io.Copy(h, r)
+		r.Close()
+		if (err != nil) != e.wantErr {
+			t.Errorf("resumes %d, drops %d: got error %v, want error: %v", e.resumes, e.drops, err, e.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := fmt.Sprintf("%x", h.Sum(nil)); got != wsha {
+			t.Errorf("resumes %d, drops %d: got sha1 %s, want %s", e.resumes, e.drops, got, wsha)
+		}
+	}
+}
+
+// onlyReader hides any methods but Read, so that io.Copy can't use ReadFrom's
+// unbuffered path.
+type onlyReader struct{ io.Reader }
+
+func TestWriterSHA1Modes(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: {}},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := strings.Repeat("a stream of unknown length ", 100)
+
+	table := []struct {
+		opt      WriterOption
+		readFrom bool
+	}{
+		{opt: WithTrailingSHA1()},
+		{opt: WithTrailingSHA1(), readFrom: true},
+		{opt: WithoutSHA1()},
+		{opt: WithoutSHA1(), readFrom: true},
+	}
+	for i, e := range table {
+		obj := bucket.Object(fmt.Sprintf("%s-%d", smallFileName, i))
+		w := obj.NewWriter(ctx, e.opt)
+		if e.readFrom {
+			_, err = io.Copy(w, strings.NewReader(data))
+		} else {
+			_, err = io.Copy(w, onlyReader{strings.NewReader(data)})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("%d: Close: %v", i, err)
+			continue
+		}
+		if got := obj.f.size(); got != int64(len(data)) {
+			t.Errorf("%d: got size %d, want %d", i, got, len(data))
+		}
+		r := obj.NewReader(ctx)
+		got, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != data {
+			t.Errorf("%d: got %d bytes back, want the %d written", i, len(got), len(data))
+		}
+	}
+}
+
+func TestCoalesceRanges(t *testing.T) {
+	table := []struct {
+		in   []ByteRange
+		gap  int64
+		want []ByteRange
+	}{
+		{},
+		{
+			in:   []ByteRange{{Offset: 100, Length: 10}, {Offset: 0, Length: 10}},
+			gap:  0,
+			want: []ByteRange{{Offset: 0, Length: 10}, {Offset: 100, Length: 10}},
+		},
+		{
+			in:   []ByteRange{{Offset: 100, Length: 10}, {Offset: 0, Length: 10}},
+			gap:  90,
+			want: []ByteRange{{Offset: 0, Length: 110}},
+		},
+		{
+			in:   []ByteRange{{Offset: 0, Length: 50}, {Offset: 10, Length: 10}, {Offset: 50, Length: 5}, {Offset: 60, Length: 0}},
+			gap:  0,
+			want: []ByteRange{{Offset: 0, Length: 55}},
+		},
+	}
+	for _, e := range table {
+		got := coalesce(e.in, e.gap)
+		if !reflect.DeepEqual(got, e.want) {
+			t.Errorf("coalesce(%v, %d): got %v, want %v", e.in, e.gap, got, e.want)
+		}
+	}
+}
+
+func TestRangesReader(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: {}},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 1e5)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	obj := bucket.Object(smallFileName)
+	w := obj.NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := obj.NewRangesReader(ctx, []ByteRange{
+		{Offset: int64(len(data)) - 100, Length: 200},
+		{Offset: 10, Length: 100},
+		{Offset: 50000, Length: 1000},
+		{Offset: 200, Length: 10},
+	})
+	r.MaxGap = 1000
+	r.ConcurrentDownloads = 2
+	if got := len(r.Requests()); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+
+	table := []struct {
+		off, n  int64
+		want    int
+		wantErr error
+		bad     bool
+	}{
+		{off: 10, n: 100, want: 100},
+		{off: 150, n: 10, want: 10}, // in the gap, but fetched anyway
+		{off: 50500, n: 500, want: 500},
+		{off: int64(len(data)) - 50, n: 100, want: 50, wantErr: io.EOF},
+		{off: 40000, n: 10, bad: true},
+		{off: 50900, n: 200, bad: true},
+	}
+	for _, e := range table {
+		p := make([]byte, e.n)
+		n, err := r.ReadAt(p, e.off)
+		if e.bad {
+			if err == nil {
+				t.Errorf("ReadAt(%d, %d): got no error for unrequested bytes", e.off, e.n)
+			}
+			continue
+		}
+		if n != e.want || err != e.wantErr {
+			t.Errorf("ReadAt(%d, %d): got %d, %v; want %d, %v", e.off, e.n, n, err, e.want, e.wantErr)
+			continue
+		}
+		if !bytes.Equal(p[:n], data[e.off:e.off+int64(n)]) {
+			t.Errorf("ReadAt(%d, %d): wrong data", e.off, e.n)
+		}
+	}
+	r.Close()
+	if _, err := r.ReadAt(make([]byte, 1), 10); err == nil {
+		t.Error("ReadAt after Close: got no error")
+	}
+}
+
+func TestBucketDefaultAttrs(t *testing.T) {
+	ctx := context.Background()
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: {}},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket.SetDefaultAttrs(&Attrs{
+		ContentType: "text/plain",
+		Info: map[string]string{
+			InfoCacheControl: "max-age=3600",
+			"team":           "storage",
+		},
+	})
+
+	full := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		full[fmt.Sprintf("k%d", i)] = "v"
+	}
+	table := []struct {
+		attrs *Attrs
+		ctype string
+		info  map[string]string
+	}{
+		{
+			ctype: "text/plain",
+			info:  map[string]string{InfoCacheControl: "max-age=3600", "team": "storage"},
+		},
+		{
+			attrs: &Attrs{ContentType: "image/png", Info: map[string]string{InfoCacheControl: "no-store"}},
+			ctype: "image/png",
+			info:  map[string]string{InfoCacheControl: "no-store", "team": "storage"},
+		},
+		{
+			attrs: &Attrs{Info: full},
+			ctype: "text/plain",
+			info:  full,
+		},
+	}
+	for i, e := range table {
+		var opts []WriterOption
+		if e.attrs != nil {
+			opts = append(opts, WithAttrsOption(e.attrs))
+		}
+		w := bucket.Object(smallFileName).NewWriter(ctx, opts...)
+		if w.contentType != e.ctype {
+			t.Errorf("%d: got content type %q, want %q", i, w.contentType, e.ctype)
+		}
+		if !reflect.DeepEqual(w.info, e.info) {
+			t.Errorf("%d: got info %v, want %v", i, w.info, e.info)
+		}
+		w.cancel()
+	}
+
+	bucket.SetDefaultAttrs(nil)
+	if w := bucket.Object(smallFileName).NewWriter(ctx); w.contentType != "" || w.info != nil {
+		t.Errorf("after clearing defaults: got %q, %v", w.contentType, w.info)
+	}
+}
+
+func TestOnRetry(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	ch := make(chan time.Time)
+	close(ch)
+	oldAfter := after
+	after = func(time.Duration) <-chan time.Time { return ch }
+	defer func() { after = oldAfter }()
+
+	root := &testRoot{
+		bucketMap: make(map[string]map[string]string),
+		errs: &errCont{
+			errMap: map[string]map[int]error{
+				"createBucket": {
+					0: testError{backoff: time.Second},
+					1: testError{retry: true},
+				},
+			},
+		},
+	}
+	var events []RetryEvent
+	client := &Client{
+		backend: &beRoot{
+			b2i:     root,
+			options: clientOptions{onRetry: func(e RetryEvent) { events = append(events, e) }},
+		},
+	}
+	if _, err := client.NewBucket(ctx, "fun", &BucketAttrs{Type: Private}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d retry events, want 2", len(events))
+	}
+	for i, e := range events {
+		if e.Attempt != i+1 || e.Method != "test_method" || e.Err == nil {
+			t.Errorf("event %d: got %+v", i, e)
+		}
+	}
+	if events[0].Delay != time.Second {
+		t.Errorf("first retry: got delay %v, want %v", events[0].Delay, time.Second)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	files := map[string]string{
+		"dir/a": "a", "dir/b": "bb", "dir/c": "ccc", "dir/d": "dddd", "other": "o",
+	}
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: files},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var size int64
+	var got []string
+	if err := bucket.Walk(ctx, "dir/", func(o *Object, a *Attrs) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, o.Name())
+		size += a.Size
+		return nil
+	}, WalkWorkers(3)); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	if want := []string{"dir/a", "dir/b", "dir/c", "dir/d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk: visited %v, want %v", got, want)
+	}
+	if size != 10 {
+		t.Errorf("Walk: got total size %d, want 10", size)
+	}
+
+	bad := errors.New("bad object")
+	fail := func(o *Object, _ *Attrs) error {
+		if o.Name() == "dir/b" || o.Name() == "dir/c" {
+			return bad
+		}
+		return nil
+	}
+	if err := bucket.Walk(ctx, "dir/", fail); err != bad {
+		t.Errorf("Walk: got %v, want %v", err, bad)
+	}
+	var n int
+	err = bucket.Walk(ctx, "dir/", func(o *Object, a *Attrs) error {
+		mu.Lock()
+		n++
+		mu.Unlock()
+		return fail(o, a)
+	}, WalkWorkers(2), WalkContinueOnError())
+	if !errors.Is(err, bad) {
+		t.Errorf("Walk(WalkContinueOnError()): got %v, want %v", err, bad)
+	}
+	if n != 4 {
+		t.Errorf("Walk(WalkContinueOnError()): visited %d objects, want 4", n)
+	}
+}
+
+func TestCacheControlPrecedence(t *testing.T) {
+	ctx := context.Background()
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: {}},
+				errs:      &errCont{},
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket.SetDefaultAttrs(&Attrs{Info: map[string]string{InfoCacheControl: "max-age=60"}})
+
+	table := []struct {
+		opts []WriterOption
+		want string
+	}{
+		{want: "max-age=60"},
+		{
+			opts: []WriterOption{WithAttrsOption(&Attrs{Info: map[string]string{InfoCacheControl: "no-store"}})},
+			want: "no-store",
+		},
+		{
+			opts: []WriterOption{WithCacheControl("max-age=3600"), WithAttrsOption(&Attrs{Info: map[string]string{InfoCacheControl: "no-store"}})},
+			want: "max-age=3600",
+		},
+	}
+	for i, e := range table {
+		w := bucket.Object("obj").NewWriter(ctx, e.opts...)
+		if got := w.info[InfoCacheControl]; got != e.want {
+			t.Errorf("%d: got Cache-Control %q, want %q", i, got, e.want)
+		}
+		w.cancel()
+	}
+
+	bucketInfo := map[string]string{BucketCacheControl: "public, max-age=86400"}
+	if got := effectiveCacheControl(nil, bucketInfo); got != "public, max-age=86400" {
+		t.Errorf("without a file Cache-Control: got %q, want the bucket's", got)
+	}
+	if got := effectiveCacheControl(map[string]string{InfoCacheControl: "no-cache"}, bucketInfo); got != "no-cache" {
+		t.Errorf("with a file Cache-Control: got %q, want %q", got, "no-cache")
+	}
+}
+
+func TestEarlyReauth(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	root := &testRoot{
+		bucketMap: map[string]map[string]string{bucketName: {"a": "alpha"}},
+		errs:      &errCont{},
+	}
+	client := &Client{backend: &beRoot{b2i: root}}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bucket.Object("a").Attrs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if root.auths != 0 {
+		t.Fatalf("got %d authorizations for a fresh token, want 0", root.auths)
+	}
+	root.expiring = true
+	for i := 0; i < 3; i++ {
+		if _, err := bucket.Object("a").Attrs(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if root.auths != 1 {
+		t.Errorf("got %d authorizations for an expiring token, want 1", root.auths)
+	}
+}
+
+func TestIsolatedBucket(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	ch := make(chan time.Time)
+	close(ch)
+	oldAfter := after
+	after = func(time.Duration) <-chan time.Time { return ch }
+	defer func() { after = oldAfter }()
+
+	errs := &errCont{
+		errMap: map[string]map[int]error{
+			"updateBucket": {
+				0: testError{retry: true},
+				1: testError{retry: true},
+			},
+		},
+	}
+	root := &testRoot{
+		bucketMap: map[string]map[string]string{bucketName: {}},
+		errs:      errs,
+	}
+	client := &Client{backend: &beRoot{b2i: root}}
+	shared, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isolated, err := client.IsolatedBucket(ctx, bucketName, MaxRetries(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.auths != 1 {
+		t.Errorf("IsolatedBucket: got %d authorizations, want 1", root.auths)
+	}
+
+	if err := shared.Update(ctx, &BucketAttrs{}); err != nil {
+		t.Errorf("Update on the client's bucket: %v", err)
+	}
+	errs.opMap = nil
+	if err := isolated.Update(ctx, &BucketAttrs{}); err == nil {
+		t.Error("Update on the isolated bucket: got no error after one retry")
+	}
+}
+
+func TestIsolatedBucketDryRun(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	files := map[string]string{"keep": "data"}
+	root := &testRoot{
+		bucketMap: map[string]map[string]string{bucketName: files},
+		errs:      &errCont{},
+	}
+	client := &Client{backend: &beRoot{b2i: root}}
+	var ops []DryRunOp
+	isolated, err := client.IsolatedBucket(ctx, bucketName, DryRun(func(op DryRunOp) { ops = append(ops, op) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := isolated.Object("keep").Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := files["keep"]; !ok {
+		t.Error("isolated dry-run bucket deleted an object")
+	}
+	if len(ops) != 1 {
+		t.Errorf("got %d recorded operations, want 1: %v", len(ops), ops)
+	}
+
+	shared, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shared.Object("keep").Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := files["keep"]; ok {
+		t.Error("client's bucket did not delete an object after an isolated dry run")
+	}
+}
+
+func TestInfo(t *testing.T) {
+	raw := map[string]string{"owner": "bob"}
+	info := Info(raw)
+
+	when := time.Unix(1500000000, 123e6)
+	if err := info.SetLastModified(when); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := info.LastModified(); !ok || !got.Equal(when) {
+		t.Errorf("LastModified: got %v, %v; want %v", got, ok, when)
+	}
+	if raw[infoLastModified] != "1500000000123" {
+		t.Errorf("raw map: got %q, want %q", raw[infoLastModified], "1500000000123")
+	}
+
+	for _, k := range []string{s3ETagKey, manifestKey, infoLargeFileSHA1, "blazer-meta-key-no-touchie"} {
+		if err := info.Set(k, "x"); err == nil {
+			t.Errorf("Set(%q): got no error for a reserved key", k)
+		}
+	}
+	if err := info.Delete(infoLastModified); err == nil {
+		t.Errorf("Delete(%q): got no error for a reserved key", infoLastModified)
+	}
+
+	for i := len(info); i < maxInfoKeys; i++ {
+		if err := info.Set(fmt.Sprintf("k%d", i), "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := info.Set("one-too-many", "v"); err == nil {
+		t.Error("Set: got no error for an eleventh key")
+	}
+	if err := info.Set("owner", "alice"); err != nil {
+		t.Errorf("Set: replacing a key in a full map: %v", err)
+	}
+}
+
+func TestBucketIterator(t *testing.T) {
+	ctx := context.Background()
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{
+					"alpha": {}, "alphabet": {}, "beta": {},
+				},
+				errs: &errCont{},
+			},
+		},
+	}
+	list := func(opts ...BucketListOption) []string {
+		var names []string
+		iter := client.Buckets(ctx, opts...)
+		for iter.Next() {
+			if iter.Attrs() == nil {
+				t.Errorf("%s: no attrs", iter.Bucket().Name())
+			}
+			names = append(names, iter.Bucket().Name())
+		}
+		if err := iter.Err(); err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(names)
+		return names
+	}
+	if got, want := list(), []string{"alpha", "alphabet", "beta"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Buckets(): got %v, want %v", got, want)
+	}
+	if got, want := list(BucketPrefix("alpha")), []string{"alpha", "alphabet"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Buckets(BucketPrefix): got %v, want %v", got, want)
+	}
+	if got, want := list(BucketPrefix("alpha"), BucketTypes(Private)), []string{"alpha", "alphabet"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Buckets(BucketTypes(Private)): got %v, want %v", got, want)
+	}
+	if got := list(BucketTypes(Public, Snapshot)); got != nil {
+		t.Errorf("Buckets(BucketTypes(Public, Snapshot)): got %v, want none", got)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	good := []string{"a", "dir/file.txt", "ünïcode/名前", strings.Repeat("x", 250) + "/" + strings.Repeat("y", 250)}
+	for _, name := range good {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q): %v", name, err)
+		}
+	}
+	bad := []string{"", "/lead", "trail/", "a//b", `back\slash`, "nul\x00", "del\x7f", "\xff", strings.Repeat("x", 251), strings.Repeat("x/", 513)}
+	for _, name := range bad {
+		err := ValidateName(name)
+		if _, ok := err.(*InvalidNameError); !ok {
+			t.Errorf("ValidateName(%q): got %v, want an *InvalidNameError", name, err)
+		}
+	}
+
+	got, err := NormalizeName(`/dir\\sub//file/`)
+	if err != nil || got != "dir/sub/file" {
+		t.Errorf("NormalizeName: got %q, %v; want %q", got, err, "dir/sub/file")
+	}
+}
+
+func TestWriterValidates(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	files := make(map[string]string)
+	errs := &errCont{errMap: map[string]map[int]error{}}
+	client := &Client{
+		backend: &beRoot{
+			b2i: &testRoot{
+				bucketMap: map[string]map[string]string{bucketName: files},
+				errs:      errs,
+			},
+		},
+	}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := bucket.Object("bad//name").NewWriter(ctx)
+	if _, err := io.WriteString(w, "data"); err == nil {
+		t.Error("Write: got no error for an invalid name")
+	}
+	if _, ok := w.Close().(*InvalidNameError); !ok {
+		t.Errorf("Close: got %v, want an *InvalidNameError", w.Close())
+	}
+
+	w = bucket.Object("ok").NewWriter(ctx, WithAttrsOption(&Attrs{Info: map[string]string{"bad key": "v"}}))
+	if _, ok := w.Close().(*InvalidInfoError); !ok {
+		t.Errorf("Close: got %v, want an *InvalidInfoError", w.Close())
+	}
+	if err := ValidateInfo("ok", map[string]string{"big": strings.Repeat("v", 7000)}); err == nil {
+		t.Error("ValidateInfo: got no error for oversized info")
+	}
+
+	if len(files) != 0 || errs.opMap["getUploadURL"] != 0 {
+		t.Errorf("invalid writers uploaded: %d files, %d upload URLs", len(files), errs.opMap["getUploadURL"])
+	}
+}
+
+// fakeClock never sleeps, and records how long it was asked to wait.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.waits = append(f.waits, d)
+	f.now = f.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- f.now
+	return ch
+}
+
+func TestWithClock(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	oldAfter := after
+	after = func(d time.Duration) <-chan time.Time {
+		t.Errorf("waited %v on the system clock", d)
+		return oldAfter(0)
+	}
+	defer func() { after = oldAfter }()
+
+	clock := &fakeClock{now: time.Unix(1500000000, 0)}
+	var opts clientOptions
+	WithClock(clock)(&opts)
+	root := &testRoot{
+		bucketMap: make(map[string]map[string]string),
+		errs: &errCont{
+			errMap: map[string]map[int]error{
+				"createBucket": {
+					0: testError{backoff: time.Minute},
+					1: testError{backoff: time.Hour},
+				},
+			},
+		},
+	}
+	client := &Client{
+		backend: &beRoot{b2i: root, options: opts},
+		opts:    opts,
+	}
+	start := time.Now()
+	if _, err := client.NewBucket(ctx, bucketName, nil); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("NewBucket took %v", d)
+	}
+	if want := []time.Duration{time.Minute, time.Hour}; !reflect.DeepEqual(clock.waits, want) {
+		t.Errorf("waits: got %v, want %v", clock.waits, want)
+	}
+}
+
+func TestBucketConfig(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	cors := []CORSRule{{
+		Name:              "downloads",
+		AllowedOrigins:    []string{"https://example.com"},
+		AllowedOperations: []string{"b2_download_file_by_name"},
+		MaxAgeSeconds:     3600,
+	}}
+	repl := &Replication{
+		SourceKeyID: "key",
+		Rules:       []ReplicationRule{{Name: "all", DestinationBucketID: "dest", Enabled: true, Priority: 1}},
+	}
+	notes := []NotificationRule{{
+		Name:       "uploads",
+		EventTypes: []string{"b2:ObjectCreated:*"},
+		Enabled:    true,
+		URL:        "https://example.com/hook",
+	}}
+	root := &testRoot{
+		bucketMap: map[string]map[string]string{bucketName: {}, "other": {}},
+		errs:      &errCont{},
+		attrsMap: map[string]*BucketAttrs{
+			bucketName: {
+				Type: Public,
+				Info: map[string]string{"owner": "ops", "blazer-table": "source"},
+				LifecycleRules: []LifecycleRule{
+					{Prefix: "tmp/", DaysHiddenUntilDeleted: 1},
+				},
+				CORSRules:         cors,
+				DefaultEncryption: SSEB2,
+				Replication:       repl,
+			},
+			"other": {
+				Type: Private,
+				Info: map[string]string{"blazer-table": "other"},
+			},
+		},
+		notifyMap: map[string][]NotificationRule{bucketName: notes},
+	}
+	client := &Client{backend: &beRoot{b2i: root}}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := bucket.ExportConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &BucketConfig{
+		Name:              bucketName,
+		Type:              Public,
+		Info:              map[string]string{"owner": "ops"},
+		LifecycleRules:    []LifecycleRule{{Prefix: "tmp/", DaysHiddenUntilDeleted: 1}},
+		CORSRules:         cors,
+		DefaultEncryption: SSEB2,
+		Replication:       repl,
+		NotificationRules: notes,
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("ExportConfig: got %+v, want %+v", cfg, want)
+	}
+
+	// A saved configuration applies to another bucket.
+	buf, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := &BucketConfig{}
+	if err := json.Unmarshal(buf, saved); err != nil {
+		t.Fatal(err)
+	}
+	other, err := client.Bucket(ctx, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.ApplyConfig(ctx, saved); err != nil {
+		t.Fatal(err)
+	}
+	got, err := other.ExportConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Name = "other"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("after ApplyConfig: got %+v, want %+v", got, want)
+	}
+
+	// Attributes missing from the configuration are cleared, except those a
+	// key might not have been able to read.
+	if err := other.ApplyConfig(ctx, &BucketConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = other.ExportConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = &BucketConfig{
+		Name:              "other",
+		Type:              Public,
+		Info:              map[string]string{},
+		LifecycleRules:    []LifecycleRule{},
+		CORSRules:         []CORSRule{},
+		DefaultEncryption: SSEB2,
+		Replication:       repl,
+		NotificationRules: notes,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("after clearing: got %+v, want %+v", got, want)
+	}
+
+	// Those are removed when the configuration says so.
+	if err := other.ApplyConfig(ctx, &BucketConfig{
+		DefaultEncryption: NoEncryption,
+		Replication:       &Replication{},
+		NotificationRules: []NotificationRule{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = other.ExportConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.DefaultEncryption = NoEncryption
+	want.Replication = &Replication{}
+	want.NotificationRules = []NotificationRule{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("after removing: got %+v, want %+v", got, want)
+	}
+
+	// Reserved keys are neither applied nor cleared.
+	if err := other.ApplyConfig(ctx, &BucketConfig{Info: map[string]string{"blazer-table": "applied"}}); err != nil {
+		t.Fatal(err)
+	}
+	attrs, err := other.Attrs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wantInfo := map[string]string{"blazer-table": "other"}; !reflect.DeepEqual(attrs.Info, wantInfo) {
+		t.Errorf("reserved info after ApplyConfig: got %v, want %v", attrs.Info, wantInfo)
+	}
+
+	// Settings the key may not read are left out.
+	root.caps = []string{string(CapListBuckets), string(CapWriteBuckets)}
+	got, err = bucket.ExportConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.NotificationRules != nil {
+		t.Errorf("NotificationRules without readBucketNotifications: got %v, want nil", got.NotificationRules)
+	}
+}
+
+func TestUpdateWithUnchangedSettings(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	repl := &Replication{
+		SourceKeyID: "key",
+		Rules:       []ReplicationRule{{Name: "all", DestinationBucketID: "dest", Enabled: true, Priority: 1}},
+	}
+	root := &testRoot{
+		bucketMap: map[string]map[string]string{bucketName: {}},
+		errs:      &errCont{},
+		attrsMap: map[string]*BucketAttrs{
+			bucketName: {
+				Type:              Private,
+				Info:              map[string]string{},
+				DefaultEncryption: SSEB2,
+				Replication:       repl,
+			},
+		},
+		// The key can read default encryption and replication, but not
+		// change them.
+		caps: []string{
+			string(CapListBuckets),
+			string(CapWriteBuckets),
+			string(CapReadBucketEncryption),
+			string(CapReadBucketReplications),
+		},
+	}
+	client := &Client{backend: &beRoot{b2i: root}}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bucket.UpdateWith(ctx, func(attrs *BucketAttrs) error {
+		attrs.Info["owner"] = "ops"
+		return nil
+	}); err != nil {
+		t.Fatalf("info-only UpdateWith: %v", err)
+	}
+	attrs, err := bucket.Attrs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.Info["owner"] != "ops" || attrs.DefaultEncryption != SSEB2 || !reflect.DeepEqual(attrs.Replication, repl) {
+		t.Errorf("after info-only UpdateWith: got %+v", attrs)
+	}
+
+	err = bucket.UpdateWith(ctx, func(attrs *BucketAttrs) error {
+		attrs.DefaultEncryption = NoEncryption
+		return nil
+	})
+	if !errors.Is(err, ErrReadOnlyKey) {
+		t.Errorf("changing default encryption: got %v, want %v", err, ErrReadOnlyKey)
+	}
+}
+
+func TestListedSize(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	files := map[string]string{"a": "1", "b": "22", "c": "333"}
+	root := &testRoot{
+		bucketMap: map[string]map[string]string{bucketName: files},
+		errs:      &errCont{},
+	}
+	client := &Client{backend: &beRoot{b2i: root}}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := bucket.List(ctx)
+	for iter.Next() {
+		obj := iter.Object()
+		size, ok := obj.ListedSize()
+		if want := int64(len(files[obj.Name()])); !ok || size != want {
+			t.Errorf("ListedSize(%s): got %d, %v; want %d, true", obj.Name(), size, ok, want)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bucket.Object("a").ListedSize(); ok {
+		t.Error("ListedSize of an unlisted object: got true, want false")
+	}
+}
+
+func TestListInterruptedPage(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	files := map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}
+	root := &testRoot{
+		bucketMap: map[string]map[string]string{bucketName: files},
+		errs: &errCont{
+			errMap: map[string]map[int]error{
+				"listFileNames": {0: testError{reauth: true}, 2: testError{reauth: true}},
+			},
+		},
+	}
+	client := &Client{backend: &beRoot{b2i: root}}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	iter := bucket.List(ctx, ListPageSize(3))
+	for iter.Next() {
+		got = append(got, iter.Object().Name())
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "b", "c", "d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List: got %v, want %v", got, want)
+	}
+	if root.auths != 2 {
+		t.Errorf("List: got %d reauthorizations, want 2", root.auths)
+	}
+}
+
+func TestUploadTicketLifetime(t *testing.T) {
+	ctx := context.Background()
+	root := &testRoot{
+		bucketMap: map[string]map[string]string{bucketName: {}},
+		errs:      &errCont{},
+	}
+	client := &Client{backend: &beRoot{b2i: root}}
+	bucket, err := client.Bucket(ctx, bucketName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []time.Duration{0, -time.Hour} {
+		if _, err := bucket.NewUploadTicket(ctx, "foo", d); err == nil {
+			t.Errorf("NewUploadTicket(%v): got no error", d)
+		}
+	}
+	if err := (&UploadTicket{}).Revoke(ctx); err == nil {
+		t.Error("Revoke: got no error for a ticket without a key")
+	}
+}
+
+func TestEndpointOptions(t *testing.T) {
+	clock := &fakeClock{}
+	var parent clientOptions
+	for _, f := range []ClientOption{
+		Transport(badTransport{}),
+		APIURL("https://api.example.com"),
+		DownloadURL("https://cdn.example.com"),
+		WithClock(clock),
+		UserAgent("a/1"),
+		UserAgent("b/2"),
+		MaxConcurrentRequests(3, 3, 3),
+		MaxRequestRate(10, 10, 10, time.Second),
+		DryRun(nil),
+		OnRetry(func(RetryEvent) {}),
+	} {
+		f(&parent)
+	}
+	var o clientOptions
+	for _, f := range endpointOptions(parent) {
+		f(&o)
+	}
+	if o.transport != parent.transport || o.apiURL != parent.apiURL || o.downloadURL != parent.downloadURL || o.clock != parent.clock {
+		t.Errorf("endpointOptions: endpoints not carried over: got %+v", o)
+	}
+	if !reflect.DeepEqual(o.userAgents, parent.userAgents) {
+		t.Errorf("endpointOptions: user agents: got %v, want %v", o.userAgents, parent.userAgents)
+	}
+	if o.maxUploads != 0 || o.rateOther != 0 || o.dryRun || o.onRetry != nil {
+		t.Errorf("endpointOptions: limits or callbacks carried over: got %+v", o)
+	}
+}
@@ -18,6 +18,8 @@ import (
 	"context"
 	"io"
 	"math/rand"
+	"net/http"
+	"sync"
 	"time"
 )
 
@@ -28,8 +30,14 @@ type beRootInterface interface {
 	reauth(error) bool
 	transient(error) bool
 	reupload(error) bool
+	retried(err error, attempt int, delay time.Duration)
+	retryLimit() int
+	after(time.Duration) <-chan time.Time
 	authorizeAccount(context.Context, string, string, clientOptions) error
 	reauthorizeAccount(context.Context) error
+	refreshAccount(context.Context) error
+	clockSkew() time.Duration
+	capabilities() []string
 	createBucket(ctx context.Context, name, btype string, info map[string]string, rules []LifecycleRule) (beBucketInterface, error)
 	listBuckets(context.Context, string) ([]beBucketInterface, error)
 	createKey(context.Context, string, []string, time.Duration, string, string) (beKeyInterface, error)
@@ -40,8 +48,14 @@ type beRoot struct {
 	account, key string
 	b2i          b2RootInterface
 	options      clientOptions
+
+	rmu sync.Mutex // serializes early reauthorization
 }
 
+// tokenRefreshMargin is how long before the account authorization token
+// expires, by B2's clock, that it is replaced.
+const tokenRefreshMargin = 10 * time.Minute
+
 type beBucketInterface interface {
 	name() string
 	btype() BucketType
@@ -49,9 +63,11 @@ type beBucketInterface interface {
 	id() string
 	updateBucket(context.Context, *BucketAttrs) error
 	deleteBucket(context.Context) error
+	getNotificationRules(context.Context) ([]NotificationRule, error)
+	setNotificationRules(context.Context, []NotificationRule) error
 	getUploadURL(context.Context) (beURLInterface, error)
 	startLargeFile(ctx context.Context, name, contentType string, info map[string]string) (beLargeFileInterface, error)
-	listFileNames(context.Context, int, string, string, string) ([]beFileInterface, string, error)
+	listFileNames(context.Context, int, string, string, string, func(beFileInterface)) (string, error)
 	listFileVersions(context.Context, int, string, string, string, string) ([]beFileInterface, string, string, error)
 	listUnfinishedLargeFiles(context.Context, int, string) ([]beFileInterface, string, error)
 	downloadFileByName(context.Context, string, int64, int64, bool) (beFileReaderInterface, error)
@@ -68,11 +84,13 @@ type beBucket struct {
 
 type beURLInterface interface {
 	uploadFile(context.Context, readResetter, int, string, string, string, map[string]string) (beFileInterface, error)
+	endpoint() (string, string)
 }
 
 type beURL struct {
-	b2url b2URLInterface
-	ri    beRootInterface
+	b2url  b2URLInterface
+	ri     beRootInterface
+	bucket string // the bucket's name, for the audit log
 }
 
 type beFileInterface interface {
@@ -82,6 +100,7 @@ type beFileInterface interface {
 	timestamp() time.Time
 	status() string
 	deleteFileVersion(context.Context) error
+	copyFile(context.Context, beBucketInterface, string, string, map[string]string) (beFileInterface, error)
 	getFileInfo(context.Context) (beFileInfoInterface, error)
 	listParts(context.Context, int, int) ([]beFilePartInterface, int, error)
 	compileParts(int64, map[int]string) beLargeFileInterface
@@ -91,6 +110,7 @@ type beFile struct {
 	b2file b2FileInterface
 	url    beURLInterface
 	ri     beRootInterface
+	bucket string // the bucket's name, for the audit log
 }
 
 type beLargeFileInterface interface {
@@ -102,6 +122,7 @@ type beLargeFileInterface interface {
 type beLargeFile struct {
 	b2largeFile b2LargeFileInterface
 	ri          beRootInterface
+	bucket      string // the bucket's name, for the audit log
 }
 
 type beFileChunkInterface interface {
@@ -112,12 +133,14 @@ type beFileChunkInterface interface {
 type beFileChunk struct {
 	b2fileChunk b2FileChunkInterface
 	ri          beRootInterface
+	bucket      string // the bucket's name, for the audit log
 }
 
 type beFileReaderInterface interface {
 	io.ReadCloser
 	stats() (int, string, string, map[string]string)
 	id() string
+	header() http.Header
 }
 
 type beFileReader struct {
@@ -169,8 +192,23 @@ func (r *beRoot) reauth(err error) bool           { return r.b2i.reauth(err) }
 func (r *beRoot) reupload(err error) bool         { return r.b2i.reupload(err) }
 func (r *beRoot) transient(err error) bool        { return r.b2i.transient(err) }
 
+func (r *beRoot) retryLimit() int                        { return r.options.maxRetries }
+func (r *beRoot) after(d time.Duration) <-chan time.Time { return r.options.after(d) }
+
+func (r *beRoot) retried(err error, attempt int, delay time.Duration) {
+	if r.options.onRetry == nil {
+		return
+	}
+	r.options.onRetry(RetryEvent{
+		Method:  r.b2i.method(err),
+		Attempt: attempt,
+		Delay:   delay,
+		Err:     err,
+	})
+}
+
 func (r *beRoot) authorizeAccount(ctx context.Context, account, key string, c clientOptions) error {
-	f := func() error {
+	f := func(ctx context.Context) error {
 		if err := r.b2i.authorizeAccount(ctx, account, key, c); err != nil {
 			return err
 		}
@@ -186,9 +224,29 @@ func (r *beRoot) reauthorizeAccount(ctx context.Context) error {
 	return r.authorizeAccount(ctx, r.account, r.key, r.options)
 }
 
+// refreshAccount reauthorizes if the token is about to expire.  Waiting for
+// B2 to reject it would instead fail every call in flight at once.
+func (r *beRoot) refreshAccount(ctx context.Context) error {
+	if !r.b2i.tokenExpiring(tokenRefreshMargin) {
+		return nil
+	}
+	r.rmu.Lock()
+	defer r.rmu.Unlock()
+	if !r.b2i.tokenExpiring(tokenRefreshMargin) {
+		return nil // another call got here first
+	}
+	return r.reauthorizeAccount(ctx)
+}
+
+func (r *beRoot) clockSkew() time.Duration { return r.b2i.clockSkew() }
+func (r *beRoot) capabilities() []string   { return r.b2i.capabilities() }
+
 func (r *beRoot) createBucket(ctx context.Context, name, btype string, info map[string]string, rules []LifecycleRule) (beBucketInterface, error) {
+	if err := requireCapability(r, CapWriteBuckets); err != nil {
+		return nil, err
+	}
 	var bi beBucketInterface
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			bucket, err := r.b2i.createBucket(ctx, name, btype, info, rules)
 			if err != nil {
@@ -210,7 +268,7 @@ func (r *beRoot) createBucket(ctx context.Context, name, btype string, info map[
 
 func (r *beRoot) listBuckets(ctx context.Context, name string) ([]beBucketInterface, error) {
 	var buckets []beBucketInterface
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			bs, err := r.b2i.listBuckets(ctx, name)
 			if err != nil {
@@ -233,8 +291,11 @@ func (r *beRoot) listBuckets(ctx context.Context, name string) ([]beBucketInterf
 }
 
 func (r *beRoot) createKey(ctx context.Context, name string, caps []string, valid time.Duration, bucketID string, prefix string) (beKeyInterface, error) {
+	if err := requireCapability(r, CapWriteKeys); err != nil {
+		return nil, err
+	}
 	var k *beKey
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			got, err := r.b2i.createKey(ctx, name, caps, valid, bucketID, prefix)
 			if err != nil {
@@ -257,7 +318,7 @@ func (r *beRoot) createKey(ctx context.Context, name string, caps []string, vali
 func (r *beRoot) listKeys(ctx context.Context, max int, next string) ([]beKeyInterface, string, error) {
 	var keys []beKeyInterface
 	var cur string
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			got, n, err := r.b2i.listKeys(ctx, max, next)
 			if err != nil {
@@ -286,50 +347,111 @@ func (b *beBucket) attrs() *BucketAttrs { return b.b2bucket.attrs() }
 func (b *beBucket) id() string          { return b.b2bucket.id() }
 
 func (b *beBucket) updateBucket(ctx context.Context, attrs *BucketAttrs) error {
-	f := func() error {
+	if err := requireCapability(b.ri, CapWriteBuckets); err != nil {
+		return err
+	}
+	if attrs != nil {
+		attrs = attrs.sendable(b.b2bucket.attrs())
+	}
+	if attrs != nil && attrs.DefaultEncryption != UnknownEncryption {
+		if err := requireCapability(b.ri, CapWriteBucketEncryption); err != nil {
+			return err
+		}
+	}
+	if attrs != nil && attrs.Replication != nil {
+		if err := requireCapability(b.ri, CapWriteBucketReplications); err != nil {
+			return err
+		}
+	}
+	f := func(ctx context.Context) error {
 		g := func() error {
 			return b.b2bucket.updateBucket(ctx, attrs)
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	return withBackoff(ctx, b.ri, f)
+	return withBackoff(withBucket(ctx, b.name()), b.ri, f)
 }
 
 func (b *beBucket) deleteBucket(ctx context.Context) error {
-	f := func() error {
+	if err := requireCapability(b.ri, CapDeleteBuckets); err != nil {
+		return err
+	}
+	f := func(ctx context.Context) error {
 		g := func() error {
 			return b.b2bucket.deleteBucket(ctx)
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	return withBackoff(ctx, b.ri, f)
+	return withBackoff(withBucket(ctx, b.name()), b.ri, f)
+}
+
+func (b *beBucket) getNotificationRules(ctx context.Context) ([]NotificationRule, error) {
+	if err := requireCapability(b.ri, CapReadBucketNotifications); err != nil {
+		return nil, err
+	}
+	var rules []NotificationRule
+	f := func(ctx context.Context) error {
+		g := func() error {
+			r, err := b.b2bucket.getNotificationRules(ctx)
+			if err != nil {
+				return err
+			}
+			rules = r
+			return nil
+		}
+		return withReauth(ctx, b.ri, g)
+	}
+	if err := withBackoff(withBucket(ctx, b.name()), b.ri, f); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (b *beBucket) setNotificationRules(ctx context.Context, rules []NotificationRule) error {
+	if err := requireCapability(b.ri, CapWriteBucketNotifications); err != nil {
+		return err
+	}
+	f := func(ctx context.Context) error {
+		g := func() error {
+			return b.b2bucket.setNotificationRules(ctx, rules)
+		}
+		return withReauth(ctx, b.ri, g)
+	}
+	return withBackoff(withBucket(ctx, b.name()), b.ri, f)
 }
 
 func (b *beBucket) getUploadURL(ctx context.Context) (beURLInterface, error) {
+	if err := requireCapability(b.ri, CapWriteFiles); err != nil {
+		return nil, err
+	}
 	var url beURLInterface
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			u, err := b.b2bucket.getUploadURL(ctx)
 			if err != nil {
 				return err
 			}
 			url = &beURL{
-				b2url: u,
-				ri:    b.ri,
+				b2url:  u,
+				ri:     b.ri,
+				bucket: b.name(),
 			}
 			return nil
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.name()), b.ri, f); err != nil {
 		return nil, err
 	}
 	return url, nil
 }
 
 func (b *beBucket) startLargeFile(ctx context.Context, name, ct string, info map[string]string) (beLargeFileInterface, error) {
+	if err := requireCapability(b.ri, CapWriteFiles); err != nil {
+		return nil, err
+	}
 	var file beLargeFileInterface
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			f, err := b.b2bucket.startLargeFile(ctx, name, ct, info)
 			if err != nil {
@@ -338,47 +460,54 @@ func (b *beBucket) startLargeFile(ctx context.Context, name, ct string, info map
 			file = &beLargeFile{
 				b2largeFile: f,
 				ri:          b.ri,
+				bucket:      b.name(),
 			}
 			return nil
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.name()), b.ri, f); err != nil {
 		return nil, err
 	}
 	return file, nil
 }
 
-func (b *beBucket) listFileNames(ctx context.Context, count int, continuation, prefix, delimiter string) ([]beFileInterface, string, error) {
+func (b *beBucket) listFileNames(ctx context.Context, count int, continuation, prefix, delimiter string, fn func(beFileInterface)) (string, error) {
 	var cont string
-	var files []beFileInterface
-	f := func() error {
+	// Files arrive in order; a retried request skips those already passed on.
+	var last string
+	var seen bool
+	f := func(ctx context.Context) error {
 		g := func() error {
-			fs, c, err := b.b2bucket.listFileNames(ctx, count, continuation, prefix, delimiter)
+			c, err := b.b2bucket.listFileNames(ctx, count, continuation, prefix, delimiter, func(file b2FileInterface) {
+				if seen && file.name() <= last {
+					return
+				}
+				last, seen = file.name(), true
+				fn(&beFile{
+					b2file: file,
+					ri:     b.ri,
+					bucket: b.name(),
+				})
+			})
 			if err != nil {
 				return err
 			}
 			cont = c
-			for _, f := range fs {
-				files = append(files, &beFile{
-					b2file: f,
-					ri:     b.ri,
-				})
-			}
 			return nil
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
-		return nil, "", err
+	if err := withBackoff(withBucket(ctx, b.name()), b.ri, f); err != nil {
+		return "", err
 	}
-	return files, cont, nil
+	return cont, nil
 }
 
 func (b *beBucket) listFileVersions(ctx context.Context, count int, nextName, nextID, prefix, delimiter string) ([]beFileInterface, string, string, error) {
 	var name, id string
 	var files []beFileInterface
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			fs, n, d, err := b.b2bucket.listFileVersions(ctx, count, nextName, nextID, prefix, delimiter)
 			if err != nil {
@@ -390,13 +519,14 @@ func (b *beBucket) listFileVersions(ctx context.Context, count int, nextName, ne
 				files = append(files, &beFile{
 					b2file: f,
 					ri:     b.ri,
+					bucket: b.name(),
 				})
 			}
 			return nil
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.name()), b.ri, f); err != nil {
 		return nil, "", "", err
 	}
 	return files, name, id, nil
@@ -405,7 +535,7 @@ func (b *beBucket) listFileVersions(ctx context.Context, count int, nextName, ne
 func (b *beBucket) listUnfinishedLargeFiles(ctx context.Context, count int, continuation string) ([]beFileInterface, string, error) {
 	var cont string
 	var files []beFileInterface
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			fs, c, err := b.b2bucket.listUnfinishedLargeFiles(ctx, count, continuation)
 			if err != nil {
@@ -416,13 +546,14 @@ func (b *beBucket) listUnfinishedLargeFiles(ctx context.Context, count int, cont
 				files = append(files, &beFile{
 					b2file: f,
 					ri:     b.ri,
+					bucket: b.name(),
 				})
 			}
 			return nil
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.name()), b.ri, f); err != nil {
 		return nil, "", err
 	}
 	return files, cont, nil
@@ -430,7 +561,7 @@ func (b *beBucket) listUnfinishedLargeFiles(ctx context.Context, count int, cont
 
 func (b *beBucket) downloadFileByName(ctx context.Context, name string, offset, size int64, header bool) (beFileReaderInterface, error) {
 	var reader beFileReaderInterface
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			fr, err := b.b2bucket.downloadFileByName(ctx, name, offset, size, header)
 			if err != nil {
@@ -444,15 +575,18 @@ func (b *beBucket) downloadFileByName(ctx context.Context, name string, offset,
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.name()), b.ri, f); err != nil {
 		return nil, err
 	}
 	return reader, nil
 }
 
 func (b *beBucket) hideFile(ctx context.Context, name string) (beFileInterface, error) {
+	if err := requireCapability(b.ri, CapWriteFiles); err != nil {
+		return nil, err
+	}
 	var file beFileInterface
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			f, err := b.b2bucket.hideFile(ctx, name)
 			if err != nil {
@@ -461,12 +595,13 @@ func (b *beBucket) hideFile(ctx context.Context, name string) (beFileInterface,
 			file = &beFile{
 				b2file: f,
 				ri:     b.ri,
+				bucket: b.name(),
 			}
 			return nil
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.name()), b.ri, f); err != nil {
 		return nil, err
 	}
 	return file, nil
@@ -474,7 +609,7 @@ func (b *beBucket) hideFile(ctx context.Context, name string) (beFileInterface,
 
 func (b *beBucket) getDownloadAuthorization(ctx context.Context, p string, v time.Duration, s string) (string, error) {
 	var tok string
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			t, err := b.b2bucket.getDownloadAuthorization(ctx, p, v, s)
 			if err != nil {
@@ -485,7 +620,7 @@ func (b *beBucket) getDownloadAuthorization(ctx context.Context, p string, v tim
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.name()), b.ri, f); err != nil {
 		return "", err
 	}
 	return tok, nil
@@ -499,12 +634,17 @@ func (b *beBucket) file(id, name string) beFileInterface {
 	return &beFile{
 		b2file: b.b2bucket.file(id, name),
 		ri:     b.ri,
+		bucket: b.name(),
 	}
 }
 
+func (b *beURL) endpoint() (string, string) {
+	return b.b2url.endpoint()
+}
+
 func (b *beURL) uploadFile(ctx context.Context, r readResetter, size int, name, ct, sha1 string, info map[string]string) (beFileInterface, error) {
 	var file beFileInterface
-	f := func() error {
+	f := func(ctx context.Context) error {
 		if err := r.Reset(); err != nil {
 			return err
 		}
@@ -516,23 +656,54 @@ func (b *beURL) uploadFile(ctx context.Context, r readResetter, size int, name,
 			b2file: f,
 			url:    b,
 			ri:     b.ri,
+			bucket: b.bucket,
 		}
 		return nil
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.bucket), b.ri, f); err != nil {
 		return nil, err
 	}
 	return file, nil
 }
 
 func (b *beFile) deleteFileVersion(ctx context.Context) error {
-	f := func() error {
+	if err := requireCapability(b.ri, CapDeleteFiles); err != nil {
+		return err
+	}
+	f := func(ctx context.Context) error {
 		g := func() error {
 			return b.b2file.deleteFileVersion(ctx)
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	return withBackoff(ctx, b.ri, f)
+	return withBackoff(withBucket(ctx, b.bucket), b.ri, f)
+}
+
+func (b *beFile) copyFile(ctx context.Context, dst beBucketInterface, name, ctype string, info map[string]string) (beFileInterface, error) {
+	if err := requireCapability(b.ri, CapWriteFiles); err != nil {
+		return nil, err
+	}
+	var file beFileInterface
+	f := func(ctx context.Context) error {
+		g := func() error {
+			f, err := b.b2file.copyFile(ctx, dst.id(), name, ctype, info)
+			if err != nil {
+				return err
+			}
+			file = &beFile{
+				b2file: f,
+				url:    b.url,
+				ri:     b.ri,
+				bucket: dst.name(),
+			}
+			return nil
+		}
+		return withReauth(ctx, b.ri, g)
+	}
+	if err := withBackoff(withBucket(ctx, b.bucket), b.ri, f); err != nil {
+		return nil, err
+	}
+	return file, nil
 }
 
 func (b *beFile) size() int64 {
@@ -557,7 +728,7 @@ func (b *beFile) status() string {
 
 func (b *beFile) getFileInfo(ctx context.Context) (beFileInfoInterface, error) {
 	var fileInfo beFileInfoInterface
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			fi, err := b.b2file.getFileInfo(ctx)
 			if err != nil {
@@ -577,7 +748,7 @@ func (b *beFile) getFileInfo(ctx context.Context) (beFileInfoInterface, error) {
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.bucket), b.ri, f); err != nil {
 		return nil, err
 	}
 	return fileInfo, nil
@@ -586,7 +757,7 @@ func (b *beFile) getFileInfo(ctx context.Context) (beFileInfoInterface, error) {
 func (b *beFile) listParts(ctx context.Context, next, count int) ([]beFilePartInterface, int, error) {
 	var fpi []beFilePartInterface
 	var rnxt int
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			ps, n, err := b.b2file.listParts(ctx, next, count)
 			if err != nil {
@@ -603,7 +774,7 @@ func (b *beFile) listParts(ctx context.Context, next, count int) ([]beFilePartIn
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.bucket), b.ri, f); err != nil {
 		return nil, 0, err
 	}
 	return fpi, rnxt, nil
@@ -613,12 +784,13 @@ func (b *beFile) compileParts(size int64, seen map[int]string) beLargeFileInterf
 	return &beLargeFile{
 		b2largeFile: b.b2file.compileParts(size, seen),
 		ri:          b.ri,
+		bucket:      b.bucket,
 	}
 }
 
 func (b *beLargeFile) getUploadPartURL(ctx context.Context) (beFileChunkInterface, error) {
 	var chunk beFileChunkInterface
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			fc, err := b.b2largeFile.getUploadPartURL(ctx)
 			if err != nil {
@@ -627,12 +799,13 @@ func (b *beLargeFile) getUploadPartURL(ctx context.Context) (beFileChunkInterfac
 			chunk = &beFileChunk{
 				b2fileChunk: fc,
 				ri:          b.ri,
+				bucket:      b.bucket,
 			}
 			return nil
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.bucket), b.ri, f); err != nil {
 		return nil, err
 	}
 	return chunk, nil
@@ -640,7 +813,7 @@ func (b *beLargeFile) getUploadPartURL(ctx context.Context) (beFileChunkInterfac
 
 func (b *beLargeFile) finishLargeFile(ctx context.Context) (beFileInterface, error) {
 	var file beFileInterface
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			f, err := b.b2largeFile.finishLargeFile(ctx)
 			if err != nil {
@@ -649,42 +822,43 @@ func (b *beLargeFile) finishLargeFile(ctx context.Context) (beFileInterface, err
 			file = &beFile{
 				b2file: f,
 				ri:     b.ri,
+				bucket: b.bucket,
 			}
 			return nil
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.bucket), b.ri, f); err != nil {
 		return nil, err
 	}
 	return file, nil
 }
 
 func (b *beLargeFile) cancel(ctx context.Context) error {
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			return b.b2largeFile.cancel(ctx)
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	return withBackoff(ctx, b.ri, f)
+	return withBackoff(withBucket(ctx, b.bucket), b.ri, f)
 }
 
 func (b *beFileChunk) reload(ctx context.Context) error {
-	f := func() error {
+	f := func(ctx context.Context) error {
 		g := func() error {
 			return b.b2fileChunk.reload(ctx)
 		}
 		return withReauth(ctx, b.ri, g)
 	}
-	return withBackoff(ctx, b.ri, f)
+	return withBackoff(withBucket(ctx, b.bucket), b.ri, f)
 }
 
 func (b *beFileChunk) uploadPart(ctx context.Context, r readResetter, sha1 string, size, index int) (int, error) {
 	// no re-auth; pass it back up to the caller so they can get an new upload URI and token
 	// TODO: we should handle that here probably
 	var i int
-	f := func() error {
+	f := func(ctx context.Context) error {
 		if err := r.Reset(); err != nil {
 			return err
 		}
@@ -695,7 +869,7 @@ func (b *beFileChunk) uploadPart(ctx context.Context, r readResetter, sha1 strin
 		i = j
 		return nil
 	}
-	if err := withBackoff(ctx, b.ri, f); err != nil {
+	if err := withBackoff(withBucket(ctx, b.bucket), b.ri, f); err != nil {
 		return 0, err
 	}
 	return i, nil
@@ -715,6 +889,8 @@ func (b *beFileReader) stats() (int, string, string, map[string]string) {
 
 func (b *beFileReader) id() string { return b.b2fileReader.id() }
 
+func (b *beFileReader) header() http.Header { return b.b2fileReader.header() }
+
 func (b *beFileInfo) stats() (string, string, int64, string, map[string]string, string, time.Time) {
 	return b.name, b.sha, b.size, b.ct, b.info, b.status, b.stamp
 }
@@ -723,12 +899,18 @@ func (b *beFilePart) number() int  { return b.b2filePart.number() }
 func (b *beFilePart) sha1() string { return b.b2filePart.sha1() }
 func (b *beFilePart) size() int64  { return b.b2filePart.size() }
 
-func (b *beKey) del(ctx context.Context) error { return b.k.del(ctx) }
-func (b *beKey) caps() []string                { return b.k.caps() }
-func (b *beKey) name() string                  { return b.k.name() }
-func (b *beKey) expires() time.Time            { return b.k.expires() }
-func (b *beKey) secret() string                { return b.k.secret() }
-func (b *beKey) id() string                    { return b.k.id() }
+func (b *beKey) caps() []string     { return b.k.caps() }
+func (b *beKey) name() string       { return b.k.name() }
+func (b *beKey) expires() time.Time { return b.k.expires() }
+func (b *beKey) secret() string     { return b.k.secret() }
+func (b *beKey) id() string         { return b.k.id() }
+
+func (b *beKey) del(ctx context.Context) error {
+	if err := requireCapability(b.b2i, CapDeleteKeys); err != nil {
+		return err
+	}
+	return b.k.del(ctx)
+}
 
 func jitter(d time.Duration) time.Duration {
 	f := float64(d)
@@ -746,28 +928,37 @@ func getBackoff(d time.Duration) time.Duration {
 
 var after = time.After
 
-func withBackoff(ctx context.Context, ri beRootInterface, f func() error) error {
+// withBackoff calls f until it succeeds or fails with an error that is not
+// transient.  The context given to f records the attempt, for the audit log.
+func withBackoff(ctx context.Context, ri beRootInterface, f func(context.Context) error) error {
 	backoff := 500 * time.Millisecond
-	for {
-		err := f()
+	for attempt := 1; ; attempt++ {
+		err := f(withAttempt(ctx, attempt))
 		if !ri.transient(err) {
 			return err
 		}
+		if n := ri.retryLimit(); n > 0 && attempt > n {
+			return err
+		}
 		bo := ri.backoff(err)
 		if bo > 0 {
 			backoff = bo
 		} else {
 			backoff = getBackoff(backoff)
 		}
+		ri.retried(err, attempt, backoff)
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-after(backoff):
+		case <-ri.after(backoff):
 		}
 	}
 }
 
 func withReauth(ctx context.Context, ri beRootInterface, f func() error) error {
+	if err := ri.refreshAccount(ctx); err != nil {
+		return err
+	}
 	err := f()
 	if ri.reauth(err) {
 		if err := ri.reauthorizeAccount(ctx); err != nil {
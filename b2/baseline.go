@@ -32,6 +32,11 @@ type b2RootInterface interface {
 	backoff(error) time.Duration
 	reauth(error) bool
 	reupload(error) bool
+	method(error) string
+	clone() b2RootInterface
+	tokenExpiring(time.Duration) bool
+	clockSkew() time.Duration
+	capabilities() []string
 	createBucket(context.Context, string, string, map[string]string, []LifecycleRule) (b2BucketInterface, error)
 	listBuckets(context.Context, string) ([]b2BucketInterface, error)
 	createKey(context.Context, string, []string, time.Duration, string, string) (b2KeyInterface, error)
@@ -45,9 +50,11 @@ type b2BucketInterface interface {
 	id() string
 	updateBucket(context.Context, *BucketAttrs) error
 	deleteBucket(context.Context) error
+	getNotificationRules(context.Context) ([]NotificationRule, error)
+	setNotificationRules(context.Context, []NotificationRule) error
 	getUploadURL(context.Context) (b2URLInterface, error)
 	startLargeFile(ctx context.Context, name, contentType string, info map[string]string) (b2LargeFileInterface, error)
-	listFileNames(context.Context, int, string, string, string) ([]b2FileInterface, string, error)
+	listFileNames(context.Context, int, string, string, string, func(b2FileInterface)) (string, error)
 	listFileVersions(context.Context, int, string, string, string, string) ([]b2FileInterface, string, string, error)
 	listUnfinishedLargeFiles(context.Context, int, string) ([]b2FileInterface, string, error)
 	downloadFileByName(context.Context, string, int64, int64, bool) (b2FileReaderInterface, error)
@@ -60,6 +67,7 @@ type b2BucketInterface interface {
 type b2URLInterface interface {
 	reload(context.Context) error
 	uploadFile(context.Context, io.Reader, int, string, string, string, map[string]string) (b2FileInterface, error)
+	endpoint() (string, string)
 }
 
 type b2FileInterface interface {
@@ -69,6 +77,7 @@ type b2FileInterface interface {
 	timestamp() time.Time
 	status() string
 	deleteFileVersion(context.Context) error
+	copyFile(context.Context, string, string, string, map[string]string) (b2FileInterface, error)
 	getFileInfo(context.Context) (b2FileInfoInterface, error)
 	listParts(context.Context, int, int) ([]b2FilePartInterface, int, error)
 	compileParts(int64, map[int]string) b2LargeFileInterface
@@ -89,6 +98,7 @@ type b2FileReaderInterface interface {
 	io.ReadCloser
 	stats() (int, string, string, map[string]string)
 	id() string
+	header() http.Header
 }
 
 type b2FileInfoInterface interface {
@@ -152,11 +162,14 @@ type b2Key struct {
 
 func (b *b2Root) authorizeAccount(ctx context.Context, account, key string, c clientOptions) error {
 	var aopts []base.AuthOption
-	ct := &clientTransport{client: c.client}
+	ct := &clientTransport{client: c.client, limits: c.limits}
 	if c.transport != nil {
 		ct.rt = c.transport
 	}
 	aopts = append(aopts, base.Transport(ct))
+	if c.downloadTransport != nil {
+		aopts = append(aopts, base.DownloadTransport(&clientTransport{client: c.client, limits: c.limits, rt: c.downloadTransport}))
+	}
 	if c.failSomeUploads {
 		aopts = append(aopts, base.FailSomeUploads())
 	}
@@ -169,9 +182,18 @@ func (b *b2Root) authorizeAccount(ctx context.Context, account, key string, c cl
 	if c.apiBase != "" {
 		aopts = append(aopts, base.SetAPIBase(c.apiBase))
 	}
+	if c.apiURL != "" {
+		aopts = append(aopts, base.SetAPIURL(c.apiURL))
+	}
+	if c.downloadURL != "" {
+		aopts = append(aopts, base.SetDownloadURL(c.downloadURL))
+	}
 	for _, agent := range c.userAgents {
 		aopts = append(aopts, base.UserAgent(agent))
 	}
+	if c.clock != nil {
+		aopts = append(aopts, base.LocalClock(c.clock.Now))
+	}
 	nb, err := base.AuthorizeAccount(ctx, account, key, aopts...)
 	if err != nil {
 		return err
@@ -203,13 +225,34 @@ func (*b2Root) transient(err error) bool {
 	return base.Action(err) == base.Retry
 }
 
+func (*b2Root) method(err error) string {
+	return base.Method(err)
+}
+
+func (*b2Root) clone() b2RootInterface {
+	return &b2Root{}
+}
+
+func (b *b2Root) tokenExpiring(d time.Duration) bool {
+	return b.b.TokenExpiresWithin(d)
+}
+
+func (b *b2Root) clockSkew() time.Duration {
+	return b.b.ClockSkew()
+}
+
+func (b *b2Root) capabilities() []string {
+	return b.b.Capabilities()
+}
+
 func (b *b2Root) createBucket(ctx context.Context, name, btype string, info map[string]string, rules []LifecycleRule) (b2BucketInterface, error) {
 	var baseRules []base.LifecycleRule
 	for _, rule := range rules {
 		baseRules = append(baseRules, base.LifecycleRule{
-			DaysNewUntilHidden:     rule.DaysNewUntilHidden,
-			DaysHiddenUntilDeleted: rule.DaysHiddenUntilDeleted,
-			Prefix:                 rule.Prefix,
+			DaysNewUntilHidden:       rule.DaysNewUntilHidden,
+			DaysHiddenUntilDeleted:   rule.DaysHiddenUntilDeleted,
+			DaysStartedUntilCanceled: rule.DaysStartedUntilCanceled,
+			Prefix:                   rule.Prefix,
 		})
 	}
 	bucket, err := b.b.CreateBucket(ctx, name, btype, info, baseRules)
@@ -235,24 +278,46 @@ func (b *b2Bucket) updateBucket(ctx context.Context, attrs *BucketAttrs) error {
 	if attrs == nil {
 		return nil
 	}
+	// Default encryption and replication need capabilities of their own to
+	// set, so they are sent only if attrs gives them; beBucket leaves them out
+	// of attrs when they are unchanged.  Everything else starts as listed.
+	nb := *b.b
+	nb.DefaultEncryption = string(attrs.DefaultEncryption)
+	nb.Replication = nil
 	if attrs.Type != UnknownType {
-		b.b.Type = string(attrs.Type)
+		nb.Type = string(attrs.Type)
 	}
 	if attrs.Info != nil {
-		b.b.Info = attrs.Info
+		nb.Info = attrs.Info
 	}
 	if attrs.LifecycleRules != nil {
 		rules := []base.LifecycleRule{}
 		for _, rule := range attrs.LifecycleRules {
 			rules = append(rules, base.LifecycleRule{
-				DaysNewUntilHidden:     rule.DaysNewUntilHidden,
-				DaysHiddenUntilDeleted: rule.DaysHiddenUntilDeleted,
-				Prefix:                 rule.Prefix,
+				DaysNewUntilHidden:       rule.DaysNewUntilHidden,
+				DaysHiddenUntilDeleted:   rule.DaysHiddenUntilDeleted,
+				DaysStartedUntilCanceled: rule.DaysStartedUntilCanceled,
+				Prefix:                   rule.Prefix,
 			})
 		}
-		b.b.LifecycleRules = rules
+		nb.LifecycleRules = rules
 	}
-	newBucket, err := b.b.Update(ctx)
+	if attrs.CORSRules != nil {
+		nb.CORSRules = []base.CORSRule{}
+		for _, rule := range attrs.CORSRules {
+			nb.CORSRules = append(nb.CORSRules, base.CORSRule(rule))
+		}
+	}
+	if r := attrs.Replication; r != nil {
+		nb.Replication = &base.Replication{
+			SourceKeyID: r.SourceKeyID,
+			KeyMapping:  r.KeyMapping,
+		}
+		for _, rule := range r.Rules {
+			nb.Replication.Rules = append(nb.Replication.Rules, base.ReplicationRule(rule))
+		}
+	}
+	newBucket, err := nb.Update(ctx)
 	if err == nil {
 		b.b = newBucket
 	}
@@ -266,6 +331,31 @@ func (b *b2Bucket) updateBucket(ctx context.Context, attrs *BucketAttrs) error {
 	return err
 }
 
+func (b *b2Bucket) getNotificationRules(ctx context.Context) ([]NotificationRule, error) {
+	rules, err := b.b.NotificationRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return notificationRules(rules), nil
+}
+
+func (b *b2Bucket) setNotificationRules(ctx context.Context, rules []NotificationRule) error {
+	var baseRules []base.NotificationRule
+	for _, rule := range rules {
+		baseRules = append(baseRules, base.NotificationRule(rule))
+	}
+	_, err := b.b.SetNotificationRules(ctx, baseRules)
+	return err
+}
+
+func notificationRules(rules []base.NotificationRule) []NotificationRule {
+	var rtn []NotificationRule
+	for _, rule := range rules {
+		rtn = append(rtn, NotificationRule(rule))
+	}
+	return rtn
+}
+
 func (b *b2Root) createKey(ctx context.Context, name string, caps []string, valid time.Duration, bucketID string, prefix string) (b2KeyInterface, error) {
 	k, err := b.b.CreateKey(ctx, name, caps, valid, bucketID, prefix)
 	if err != nil {
@@ -302,15 +392,33 @@ func (b *b2Bucket) attrs() *BucketAttrs {
 	var rules []LifecycleRule
 	for _, rule := range b.b.LifecycleRules {
 		rules = append(rules, LifecycleRule{
-			DaysNewUntilHidden:     rule.DaysNewUntilHidden,
-			DaysHiddenUntilDeleted: rule.DaysHiddenUntilDeleted,
-			Prefix:                 rule.Prefix,
+			DaysNewUntilHidden:       rule.DaysNewUntilHidden,
+			DaysHiddenUntilDeleted:   rule.DaysHiddenUntilDeleted,
+			DaysStartedUntilCanceled: rule.DaysStartedUntilCanceled,
+			Prefix:                   rule.Prefix,
 		})
 	}
+	var cors []CORSRule
+	for _, rule := range b.b.CORSRules {
+		cors = append(cors, CORSRule(rule))
+	}
+	var repl *Replication
+	if r := b.b.Replication; r != nil {
+		repl = &Replication{
+			SourceKeyID: r.SourceKeyID,
+			KeyMapping:  r.KeyMapping,
+		}
+		for _, rule := range r.Rules {
+			repl.Rules = append(repl.Rules, ReplicationRule(rule))
+		}
+	}
 	return &BucketAttrs{
-		LifecycleRules: rules,
-		Info:           b.b.Info,
-		Type:           BucketType(b.b.Type),
+		LifecycleRules:    rules,
+		Info:              b.b.Info,
+		Type:              BucketType(b.b.Type),
+		CORSRules:         cors,
+		DefaultEncryption: EncryptionMode(b.b.DefaultEncryption),
+		Replication:       repl,
 	}
 }
 
@@ -332,16 +440,11 @@ func (b *b2Bucket) startLargeFile(ctx context.Context, name, ct string, info map
 	return &b2LargeFile{lf}, nil
 }
 
-func (b *b2Bucket) listFileNames(ctx context.Context, count int, continuation, prefix, delimiter string) ([]b2FileInterface, string, error) {
-	fs, c, err := b.b.ListFileNames(ctx, count, continuation, prefix, delimiter)
-	if err != nil {
-		return nil, "", err
-	}
-	var files []b2FileInterface
-	for _, f := range fs {
-		files = append(files, &b2File{f})
-	}
-	return files, c, nil
+func (b *b2Bucket) listFileNames(ctx context.Context, count int, continuation, prefix, delimiter string, f func(b2FileInterface)) (string, error) {
+	return b.b.ListFileNamesFunc(ctx, count, continuation, prefix, delimiter, func(file *base.File) error {
+		f(&b2File{file})
+		return nil
+	})
 }
 
 func (b *b2Bucket) listFileVersions(ctx context.Context, count int, nextName, nextID, prefix, delimiter string) ([]b2FileInterface, string, string, error) {
@@ -413,10 +516,22 @@ func (b *b2URL) reload(ctx context.Context) error {
 	return b.b.Reload(ctx)
 }
 
+func (b *b2URL) endpoint() (string, string) {
+	return b.b.Endpoint()
+}
+
 func (b *b2File) deleteFileVersion(ctx context.Context) error {
 	return b.b.DeleteFileVersion(ctx)
 }
 
+func (b *b2File) copyFile(ctx context.Context, bucketID, name, ctype string, info map[string]string) (b2FileInterface, error) {
+	f, err := b.b.CopyFile(ctx, bucketID, name, ctype, info)
+	if err != nil {
+		return nil, err
+	}
+	return &b2File{f}, nil
+}
+
 func (b *b2File) name() string {
 	return b.b.Name
 }
@@ -506,6 +621,8 @@ func (b *b2FileReader) stats() (int, string, string, map[string]string) {
 
 func (b *b2FileReader) id() string { return b.b.ID }
 
+func (b *b2FileReader) header() http.Header { return b.b.Header }
+
 func (b *b2FileInfo) stats() (string, string, int64, string, map[string]string, string, time.Time) {
 	return b.b.Name, b.b.SHA1, b.b.Size, b.b.ContentType, b.b.Info, b.b.Status, b.b.Timestamp
 }
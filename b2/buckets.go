@@ -0,0 +1,130 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+type bucketIteratorOptions struct {
+	types  map[BucketType]bool
+	prefix string
+}
+
+// A BucketListOption alters the default behavior of Buckets.
+type BucketListOption func(*bucketIteratorOptions)
+
+// BucketTypes restricts the listing to buckets of the given types.
+func BucketTypes(types ...BucketType) BucketListOption {
+	return func(o *bucketIteratorOptions) {
+		if o.types == nil {
+			o.types = make(map[BucketType]bool)
+		}
+		for _, t := range types {
+			o.types[t] = true
+		}
+	}
+}
+
+// BucketPrefix restricts the listing to buckets whose names begin with pfx.
+func BucketPrefix(pfx string) BucketListOption {
+	return func(o *bucketIteratorOptions) {
+		o.prefix = pfx
+	}
+}
+
+// Buckets returns an iterator over the account's buckets, or the bucket that
+// the client's key is restricted to.  B2 returns every bucket in a single
+// call, which the iterator makes the first time Next is called; the options
+// are applied as the buckets are iterated.
+//
+// It is intended to be called in a loop, as an ObjectIterator is:
+//
+//	for iter.Next() {
+//	  bucket, attrs := iter.Bucket(), iter.Attrs()
+//	  // act on bucket
+//	}
+//	if err := iter.Err(); err != nil {
+//	  // handle err
+//	}
+func (c *Client) Buckets(ctx context.Context, opts ...BucketListOption) *BucketIterator {
+	bi := &BucketIterator{
+		c:   c,
+		ctx: ctx,
+		idx: -1,
+	}
+	for _, opt := range opts {
+		opt(&bi.opts)
+	}
+	return bi
+}
+
+// BucketIterator iterates over buckets.
+type BucketIterator struct {
+	c    *Client
+	ctx  context.Context
+	opts bucketIteratorOptions
+	init sync.Once
+	bs   []beBucketInterface
+	idx  int
+	err  error
+}
+
+// Next advances the iterator to the next bucket, and reports whether there is
+// one.  It must be called before the first call to Bucket.
+func (bi *BucketIterator) Next() bool {
+	bi.init.Do(func() {
+		bi.bs, bi.err = bi.c.backend.listBuckets(bi.ctx, "")
+	})
+	if bi.err != nil {
+		return false
+	}
+	for bi.idx++; bi.idx < len(bi.bs); bi.idx++ {
+		if bi.match(bi.bs[bi.idx]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (bi *BucketIterator) match(b beBucketInterface) bool {
+	if !strings.HasPrefix(b.name(), bi.opts.prefix) {
+		return false
+	}
+	return bi.opts.types == nil || bi.opts.types[b.btype()]
+}
+
+// Bucket returns the current bucket.
+func (bi *BucketIterator) Bucket() *Bucket {
+	return &Bucket{
+		b:       bi.bs[bi.idx],
+		r:       bi.c.backend,
+		c:       bi.c,
+		urlPool: bi.c.newURLPool(),
+	}
+}
+
+// Attrs returns the current bucket's attributes, as of the listing.  Unlike
+// Bucket.Attrs, it makes no API call.
+func (bi *BucketIterator) Attrs() *BucketAttrs {
+	return bi.bs[bi.idx].attrs()
+}
+
+// Err returns the error, if any, that ended the iteration.
+func (bi *BucketIterator) Err() error {
+	return bi.err
+}
@@ -49,6 +49,19 @@ type writeBuffer interface {
 	Close() error
 }
 
+// Special values of the X-Bz-Content-Sha1 header.
+const (
+	sha1AtEnd      = "hex_digits_at_end" // the hex SHA-1 follows the data
+	sha1Unverified = "do_not_verify"     // B2 does not check the data
+)
+
+// trailingHasher is implemented by buffers that send the SHA-1 of their data
+// after the data, rather than up front.
+type trailingHasher interface {
+	dataLen() int         // the length of the data, without the SHA-1
+	trailingHash() string // valid once the buffer has been read to the end
+}
+
 // nonBuffer doesn't buffer anything, but passes values directly from the
 // source readseeker.  Many nonBuffers can point at different parts of the same
 // underlying source, and be accessed by multiple goroutines simultaneously.
@@ -70,10 +83,12 @@ type nonBuffer struct {
 }
 
 func (nb *nonBuffer) Len() int                      { return nb.size + 40 }
-func (nb *nonBuffer) Hash() string                  { return "hex_digits_at_end" }
+func (nb *nonBuffer) Hash() string                  { return sha1AtEnd }
 func (nb *nonBuffer) Close() error                  { return nil }
 func (nb *nonBuffer) Reader() (readResetter, error) { return nb, nil }
 func (nb *nonBuffer) Write([]byte) (int, error)     { return 0, errors.New("writes not supported") }
+func (nb *nonBuffer) dataLen() int                  { return nb.size }
+func (nb *nonBuffer) trailingHash() string          { return fmt.Sprintf("%x", nb.hsh.Sum(nil)) }
 
 func (nb *nonBuffer) Read(p []byte) (int, error) {
 	if nb.isEOF {
@@ -95,6 +110,99 @@ func (nb *nonBuffer) Reset() error {
 	return err
 }
 
+// dataSum returns the size and SHA-1 of the data in buf, as B2 records them for
+// the part it is uploaded as.  A nonBuffer holds no data, so its part of the
+// source is read again to compute them.
+func dataSum(buf writeBuffer) (int64, string, error) {
+	switch b := buf.(type) {
+	case *nonBuffer:
+		h := sha1.New()
+		n, err := io.Copy(h, io.NewSectionReader(b.r, 0, int64(b.size)))
+		if err != nil {
+			return 0, "", err
+		}
+		return n, fmt.Sprintf("%x", h.Sum(nil)), nil
+	case *trailerBuffer:
+		return dataSum(b.writeBuffer)
+	case unverifiedBuffer:
+		return dataSum(b.writeBuffer)
+	}
+	return int64(buf.Len()), buf.Hash(), nil
+}
+
+// trailerBuffer wraps another buffer, and sends the SHA-1 of its contents after
+// them.  The SHA-1 is computed from the data as it is read back for the upload,
+// so it also covers the trip through the underlying buffer.
+type trailerBuffer struct {
+	writeBuffer
+	hsh   hash.Hash
+	r     readResetter
+	isEOF bool
+	buf   *strings.Reader
+}
+
+func newTrailerBuffer(wb writeBuffer) *trailerBuffer {
+	return &trailerBuffer{writeBuffer: wb, hsh: sha1.New()}
+}
+
+func (tb *trailerBuffer) Len() int             { return tb.writeBuffer.Len() + 40 }
+func (tb *trailerBuffer) Hash() string         { return sha1AtEnd }
+func (tb *trailerBuffer) dataLen() int         { return tb.writeBuffer.Len() }
+func (tb *trailerBuffer) trailingHash() string { return fmt.Sprintf("%x", tb.hsh.Sum(nil)) }
+
+func (tb *trailerBuffer) Reader() (readResetter, error) {
+	r, err := tb.writeBuffer.Reader()
+	if err != nil {
+		return nil, err
+	}
+	tb.r = r
+	tb.hsh.Reset()
+	tb.isEOF = false
+	return tb, nil
+}
+
+func (tb *trailerBuffer) Read(p []byte) (int, error) {
+	if tb.isEOF {
+		return tb.buf.Read(p)
+	}
+	n, err := tb.r.Read(p)
+	tb.hsh.Write(p[:n])
+	if err == io.EOF {
+		err = nil
+		tb.isEOF = true
+		tb.buf = strings.NewReader(fmt.Sprintf("%x", tb.hsh.Sum(nil)))
+	}
+	return n, err
+}
+
+func (tb *trailerBuffer) Reset() error {
+	tb.hsh.Reset()
+	tb.isEOF = false
+	return tb.r.Reset()
+}
+
+// unverifiedBuffer wraps another buffer, and sends its contents without any
+// SHA-1 at all.
+type unverifiedBuffer struct {
+	writeBuffer
+}
+
+func (ub unverifiedBuffer) Hash() string { return sha1Unverified }
+
+func (ub unverifiedBuffer) Len() int {
+	if th, ok := ub.writeBuffer.(trailingHasher); ok {
+		return th.dataLen()
+	}
+	return ub.writeBuffer.Len()
+}
+
+func (ub unverifiedBuffer) Reader() (readResetter, error) {
+	if nb, ok := ub.writeBuffer.(*nonBuffer); ok {
+		return resetter{rs: nb.r}, nil
+	}
+	return ub.writeBuffer.Reader()
+}
+
 type memoryBuffer struct {
 	buf *bytes.Buffer
 	hsh hash.Hash
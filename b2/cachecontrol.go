@@ -0,0 +1,90 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import "context"
+
+// BucketCacheControl is the bucket info key whose value B2 serves as the
+// Cache-Control header for objects in the bucket that do not set their own,
+// with InfoCacheControl.
+//
+// The value that applies to an object is therefore, in order of precedence:
+//
+//   - the object's InfoCacheControl, which is set when it is written, either
+//     with WithCacheControl, in the Info given to WithAttrsOption, or from the
+//     defaults given to SetDefaultAttrs, in that order;
+//   - the bucket's BucketCacheControl, which can be changed at any time and
+//     applies to existing objects;
+//   - none at all.
+const BucketCacheControl = "Cache-Control"
+
+// effectiveCacheControl applies the precedence described at
+// BucketCacheControl.
+func effectiveCacheControl(file, bucket map[string]string) string {
+	if v, ok := file[InfoCacheControl]; ok {
+		return v
+	}
+	return bucket[BucketCacheControl]
+}
+
+// WithCacheControl sets the object's Cache-Control header, overriding any
+// InfoCacheControl given with WithAttrsOption or SetDefaultAttrs and the
+// bucket's default.
+func WithCacheControl(v string) WriterOption {
+	return func(w *Writer) {
+		w.cacheControl = v
+	}
+}
+
+// SetCacheControl sets the bucket's default Cache-Control, which is served for
+// every object in the bucket without one of its own.  An empty value removes
+// the default.
+func (b *Bucket) SetCacheControl(ctx context.Context, v string) error {
+	return b.UpdateWith(ctx, func(attrs *BucketAttrs) error {
+		if attrs.Info == nil {
+			attrs.Info = make(map[string]string)
+		}
+		if v == "" {
+			delete(attrs.Info, BucketCacheControl)
+			return nil
+		}
+		attrs.Info[BucketCacheControl] = v
+		return nil
+	})
+}
+
+// CacheControl returns the bucket's default Cache-Control, or "" if there is
+// none.
+func (b *Bucket) CacheControl(ctx context.Context) (string, error) {
+	attrs, err := b.Attrs(ctx)
+	if err != nil {
+		return "", err
+	}
+	return attrs.Info[BucketCacheControl], nil
+}
+
+// CacheControl returns the Cache-Control that B2 serves for the object: its
+// own if it has one, and otherwise the bucket's default.
+func (o *Object) CacheControl(ctx context.Context) (string, error) {
+	attrs, err := o.Attrs(ctx)
+	if err != nil {
+		return "", err
+	}
+	battrs, err := o.b.Attrs(ctx)
+	if err != nil {
+		return "", err
+	}
+	return effectiveCacheControl(attrs.Info, battrs.Info), nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import "time"
+
+// A Clock tells the time and waits.  The client uses it to wait between
+// retries, to tell when its authorization token is about to expire, and to
+// count requests against MaxRequestRate, so that tests of code that retries can
+// substitute a Clock that does not sleep.
+type Clock interface {
+	Now() time.Time
+	After(time.Duration) <-chan time.Time
+}
+
+// WithClock returns a ClientOption that makes the client keep time with c
+// instead of the system clock.
+func WithClock(c Clock) ClientOption {
+	return func(o *clientOptions) {
+		o.clock = c
+	}
+}
+
+// after waits on the client's clock, if it has one.
+func (o *clientOptions) after(d time.Duration) <-chan time.Time {
+	if o.clock != nil {
+		return o.clock.After(d)
+	}
+	return after(d)
+}
@@ -0,0 +1,157 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"context"
+	"errors"
+)
+
+// A BucketConfig is the whole of a bucket's configuration, as far as this
+// package knows it.  It can be encoded as JSON, kept under version control or
+// in a backup, and applied to the same bucket or to another.
+//
+// To recreate a bucket from a saved configuration:
+//
+//	bucket, err := client.NewBucket(ctx, cfg.Name, cfg.Attrs())
+//	if err != nil {
+//		return err
+//	}
+//	return bucket.ApplyConfig(ctx, cfg)
+//
+// NewBucket leaves an existing bucket as it is; ApplyConfig brings it into
+// line, so the same steps serve for both.
+//
+// Bucket info keys that blazer reserves for itself (see IsReservedInfoKey),
+// such as the table of an x/consistent group, belong to the bucket and not to
+// its configuration: ExportConfig leaves them out, and ApplyConfig neither
+// sets them from cfg nor removes them from the bucket.
+//
+// Replication rules and key mappings name buckets and keys by ID, and so
+// apply as they are only within the account they were exported from.
+type BucketConfig struct {
+	// Name is the name of the bucket the configuration was exported from.
+	// It is not changed by ApplyConfig.
+	Name string
+
+	Type              BucketType
+	Info              map[string]string
+	LifecycleRules    []LifecycleRule
+	CORSRules         []CORSRule
+	DefaultEncryption EncryptionMode
+	Replication       *Replication
+
+	// NotificationRules is nil if the configuration was exported with a key
+	// that may not read them.
+	NotificationRules []NotificationRule
+}
+
+// Attrs returns the configuration as bucket attributes, as for NewBucket.
+func (c *BucketConfig) Attrs() *BucketAttrs {
+	attrs := (&BucketAttrs{
+		Type:              c.Type,
+		Info:              c.Info,
+		LifecycleRules:    c.LifecycleRules,
+		CORSRules:         c.CORSRules,
+		DefaultEncryption: c.DefaultEncryption,
+		Replication:       c.Replication,
+	}).copy()
+	if attrs.Info == nil {
+		attrs.Info = map[string]string{}
+	}
+	if attrs.LifecycleRules == nil {
+		attrs.LifecycleRules = []LifecycleRule{}
+	}
+	if attrs.CORSRules == nil {
+		attrs.CORSRules = []CORSRule{}
+	}
+	return attrs
+}
+
+// ExportConfig returns the bucket's current configuration.  Settings that the
+// client's key may not read are left unset.
+func (b *Bucket) ExportConfig(ctx context.Context) (*BucketConfig, error) {
+	b.InvalidateAttrs()
+	attrs, err := b.Attrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := b.NotificationRules(ctx)
+	if err != nil && !errors.Is(err, ErrReadOnlyKey) {
+		return nil, err
+	}
+	if err == nil && rules == nil {
+		rules = []NotificationRule{}
+	}
+	return &BucketConfig{
+		Name:              b.Name(),
+		Type:              attrs.Type,
+		Info:              userInfo(attrs.Info),
+		LifecycleRules:    attrs.LifecycleRules,
+		CORSRules:         attrs.CORSRules,
+		DefaultEncryption: attrs.DefaultEncryption,
+		Replication:       attrs.Replication,
+		NotificationRules: rules,
+	}, nil
+}
+
+// ApplyConfig sets every attribute of the bucket to its value in cfg.  Unlike
+// Update, attributes missing from cfg are cleared rather than left alone: a
+// nil Info removes all bucket info, and nil LifecycleRules or CORSRules remove
+// all rules.  Only the type, default encryption, replication, notification
+// rules, and reserved info keys are kept if cfg does not give them, since a
+// configuration exported with a key that may not read them leaves them unset;
+// to remove them, set NoEncryption, an empty Replication, or an empty list of
+// NotificationRules.
+func (b *Bucket) ApplyConfig(ctx context.Context, cfg *BucketConfig) error {
+	if err := b.applyAttrs(ctx, cfg); err != nil {
+		return err
+	}
+	if cfg.NotificationRules == nil {
+		return nil
+	}
+	return b.SetNotificationRules(ctx, cfg.NotificationRules)
+}
+
+func (b *Bucket) applyAttrs(ctx context.Context, cfg *BucketConfig) error {
+	return b.UpdateWith(ctx, func(attrs *BucketAttrs) error {
+		live := *attrs
+		*attrs = *cfg.Attrs()
+		if attrs.Type == UnknownType {
+			attrs.Type = live.Type
+		}
+		attrs.Info = userInfo(attrs.Info)
+		for k, v := range live.Info {
+			if IsReservedInfoKey(k) {
+				attrs.Info[k] = v
+			}
+		}
+		return nil
+	})
+}
+
+// userInfo returns a copy of info without its reserved keys.
+func userInfo(info map[string]string) map[string]string {
+	if info == nil {
+		return nil
+	}
+	n := make(map[string]string, len(info))
+	for k, v := range info {
+		if !IsReservedInfoKey(k) {
+			n[k] = v
+		}
+	}
+	return n
+}
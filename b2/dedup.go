@@ -0,0 +1,182 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/burner-account/blazer/internal/blog"
+)
+
+// A DedupStore remembers which object versions hold data with a given SHA-1,
+// so that a Writer given WithDedup can have B2 copy an existing version
+// rather than upload the same data again.
+type DedupStore interface {
+	// Get returns the file ID of a version whose contents have the given
+	// hex SHA-1, if one is known.
+	Get(sha1 string) (id string, ok bool)
+
+	// Put records that the version with the given file ID has the given
+	// SHA-1.
+	Put(sha1, id string) error
+
+	// Delete forgets the version recorded for sha1, for instance because it
+	// could not be copied.
+	Delete(sha1 string) error
+}
+
+// WithDedup makes the writer consult s before uploading.  If s knows of a
+// version with the same SHA-1 as the data written, the object is made by
+// copying that version on the server, and the data is not sent.  Versions
+// that are uploaded are added to s.
+//
+// Only objects small enough to be sent in one piece (less than ChunkSize
+// bytes) are deduplicated, since B2 does not record the SHA-1 of large files.
+// If the data is written with an io.ReadSeeker, through io.Copy or ReadFrom,
+// it is read twice: once to compute its SHA-1, and again to upload it.
+func WithDedup(s DedupStore) WriterOption {
+	return func(w *Writer) {
+		w.dedup = s
+	}
+}
+
+// dedupCopy tries to make the object by copying a version already known to
+// have the given SHA-1.  It reports whether it succeeded.
+func (w *Writer) dedupCopy(ctx context.Context, sum, ctype string, info map[string]string) bool {
+	id, ok := w.dedup.Get(sum)
+	if !ok {
+		return false
+	}
+	f, err := w.o.b.b.file(id, "").copyFile(ctx, w.o.b.b, w.name, ctype, info)
+	if err != nil {
+		blog.V(1).Infof("b2 writer: copying %s to %s: %v; uploading instead", id, w.name, err)
+		if err := w.dedup.Delete(sum); err != nil {
+			blog.V(1).Infof("b2 writer: dedup store: %v", err)
+		}
+		return false
+	}
+	w.o.f = f
+	return true
+}
+
+// dedupPut records a newly uploaded version.
+func (w *Writer) dedupPut(sum string) {
+	if err := w.dedup.Put(sum, w.o.f.id()); err != nil {
+		blog.V(1).Infof("b2 writer: dedup store: %v", err)
+	}
+}
+
+type memoryDedupStore struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+// NewMemoryDedupStore returns a DedupStore that is kept in memory, and so only
+// lasts as long as the process.
+func NewMemoryDedupStore() DedupStore {
+	return &memoryDedupStore{ids: make(map[string]string)}
+}
+
+func (m *memoryDedupStore) Get(sha1 string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.ids[sha1]
+	return id, ok
+}
+
+func (m *memoryDedupStore) Put(sha1, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ids[sha1] = id
+	return nil
+}
+
+func (m *memoryDedupStore) Delete(sha1 string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.ids, sha1)
+	return nil
+}
+
+// FileDedupStore is a DedupStore that is saved in a file, so that it can be
+// shared by successive runs of a backup program.  The file is a log of "sha1
+// id" lines, with "sha1 -" marking a deleted entry; the whole of it is held in
+// memory.
+type FileDedupStore struct {
+	memoryDedupStore
+	f *os.File
+}
+
+// OpenDedupFile opens, or creates, a FileDedupStore at path.
+func OpenDedupFile(path string) (*FileDedupStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileDedupStore{
+		memoryDedupStore: memoryDedupStore{ids: make(map[string]string)},
+		f:                f,
+	}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 {
+			continue // a torn write
+		}
+		if fields[1] == "-" {
+			delete(s.ids, fields[0])
+			continue
+		}
+		s.ids[fields[0]] = fields[1]
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileDedupStore) Put(sha1, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.f, "%s %s\n", sha1, id); err != nil {
+		return err
+	}
+	s.ids[sha1] = id
+	return nil
+}
+
+func (s *FileDedupStore) Delete(sha1 string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.ids[sha1]; !ok {
+		return nil
+	}
+	if _, err := fmt.Fprintf(s.f, "%s -\n", sha1); err != nil {
+		return err
+	}
+	delete(s.ids, sha1)
+	return nil
+}
+
+// Close closes the underlying file.
+func (s *FileDedupStore) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,284 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/burner-account/blazer/internal/blog"
+)
+
+type downloadOptions struct {
+	workers    int
+	partSize   int64
+	resumes    int
+	checkpoint string
+}
+
+// A DownloadOption alters the default behavior of DownloadTo.
+type DownloadOption func(*downloadOptions)
+
+// DownloadWorkers sets the number of parts that are downloaded at once.  The
+// default is 1.
+func DownloadWorkers(n int) DownloadOption {
+	return func(d *downloadOptions) {
+		d.workers = n
+	}
+}
+
+// DownloadPartSize sets the size of each part, and so of each request, and the
+// granularity with which a checkpoint records progress.  The default is 100MB.
+func DownloadPartSize(n int64) DownloadOption {
+	return func(d *downloadOptions) {
+		d.partSize = n
+	}
+}
+
+// DownloadResumeAttempts is the number of times a part whose download is cut
+// off partway will be resumed, as with Reader.ResumeAttempts.  The default is
+// 10.  If negative, an interrupted part fails the download.
+func DownloadResumeAttempts(n int) DownloadOption {
+	return func(d *downloadOptions) {
+		d.resumes = n
+	}
+}
+
+// WithCheckpoint records each part as it is completed in a checkpoint file at
+// path, so that if DownloadTo is interrupted, even by a crash, a later call
+// given the same destination and checkpoint fetches only the parts that are
+// missing.  The checkpoint is tied to the version of the object it was made
+// for, and to the part size; if either has changed, it is discarded and the
+// download starts over.  The file is removed once the download succeeds.
+//
+// A part is recorded only after it has been written to the destination, and,
+// if the destination has a Sync method as *os.File does, flushed to stable
+// storage.  The checkpoint is meaningless without the destination it
+// describes, so if one is removed, the other should be too.
+func WithCheckpoint(path string) DownloadOption {
+	return func(d *downloadOptions) {
+		d.checkpoint = path
+	}
+}
+
+// DownloadTo downloads the object into w, which must be at least as large as
+// the object or able to grow, as an *os.File is.  The object is fetched in
+// parts, several at once if DownloadWorkers is given, and the parts are
+// written at their offsets in whatever order they arrive.
+//
+// If the object is replaced while it is being downloaded, or since the
+// checkpoint was made, DownloadTo returns an error rather than mix versions.
+func (o *Object) DownloadTo(ctx context.Context, w io.WriterAt, opts ...DownloadOption) error {
+	do := &downloadOptions{partSize: 1e8}
+	for _, opt := range opts {
+		opt(do)
+	}
+	if do.workers < 1 {
+		do.workers = 1
+	}
+	if do.partSize < 1 {
+		return fmt.Errorf("b2: bad download part size %d", do.partSize)
+	}
+	attrs, err := o.Attrs(ctx)
+	if err != nil {
+		return err
+	}
+	id := o.ID()
+	parts := (attrs.Size + do.partSize - 1) / do.partSize
+
+	var cp *checkpoint
+	if do.checkpoint != "" {
+		cp, err = openCheckpoint(do.checkpoint, id, attrs.Size, do.partSize)
+		if err != nil {
+			return err
+		}
+		defer cp.f.Close()
+		blog.V(2).Infof("b2: %s: %d of %d parts already downloaded", o.name, len(cp.done), parts)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	var rerr error
+	setErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if rerr == nil {
+			rerr = err
+			cancel()
+		}
+	}
+	sem := make(chan struct{}, do.workers)
+	var wg sync.WaitGroup
+	for i := int64(0); i < parts; i++ {
+		if cp != nil && cp.done[i] {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		off := i * do.partSize
+		size := do.partSize
+		if off+size > attrs.Size {
+			size = attrs.Size - off
+		}
+		wg.Add(1)
+		go func(i int64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := o.downloadPart(ctx, w, id, off, size, do.resumes); err != nil {
+				setErr(err)
+				return
+			}
+			if cp != nil {
+				if err := cp.record(w, i); err != nil {
+					setErr(err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if rerr != nil {
+		return rerr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if cp != nil {
+		return cp.remove()
+	}
+	return nil
+}
+
+// downloadPart writes size bytes of version id of the object, beginning at off,
+// to the same offset in w.
+func (o *Object) downloadPart(ctx context.Context, w io.WriterAt, id string, off, size int64, resumes int) error {
+	switch {
+	case resumes < 0:
+		resumes = 0
+	case resumes == 0:
+		resumes = 10
+	}
+	var b backoff
+	var got int64
+	for attempt := 0; ; attempt++ {
+		fr, err := o.b.b.downloadFileByName(ctx, o.name, off+got, size-got, false)
+		if err != nil {
+			return err
+		}
+		if fr.id() != id {
+			fr.Close()
+			return fmt.Errorf("b2: %s changed while being downloaded", o.name)
+		}
+		n, err := copyContext(ctx, io.NewOffsetWriter(w, off+got), fr)
+		fr.Close()
+		got += n
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if got == size {
+			return nil
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		if attempt >= resumes {
+			return fmt.Errorf("b2: %s: got %dB of %dB at offset %d: %v", o.name, got, size, off, err)
+		}
+		blog.V(1).Infof("b2: %s: got %dB of %dB at offset %d (%v); resuming after %v", o.name, got, size, off, err, b)
+		if err := b.wait(ctx, o.b.c.opts.after); err != nil {
+			return err
+		}
+	}
+}
+
+// checkpoint is the sidecar file of a DownloadTo.  It begins with a line that
+// identifies the download, followed by the number of each part completed, one
+// per line.
+type checkpoint struct {
+	path string
+	mu   sync.Mutex
+	f    *os.File
+	done map[int64]bool
+}
+
+func openCheckpoint(path, id string, size, partSize int64) (*checkpoint, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	cp := &checkpoint{path: path, f: f, done: make(map[int64]bool)}
+	header := fmt.Sprintf("blazer-checkpoint v1 %s %d %d", id, size, partSize)
+	parts := (size + partSize - 1) / partSize
+	br := bufio.NewReader(f)
+	if line, err := br.ReadString('\n'); err == nil && line == header+"\n" {
+		for {
+			line, err := br.ReadString('\n')
+			if err == io.EOF {
+				// Anything left over is a torn write.
+				return cp, nil
+			}
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			i, err := strconv.ParseInt(strings.TrimSuffix(line, "\n"), 10, 64)
+			if err != nil || i < 0 || i >= parts {
+				continue
+			}
+			cp.done[i] = true
+		}
+	}
+	// A new download, or one of something else; start over.
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := fmt.Fprintln(f, header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return cp, nil
+}
+
+// record notes that part i has been written to w.
+func (cp *checkpoint) record(w io.WriterAt, i int64) error {
+	if s, ok := w.(interface{ Sync() error }); ok {
+		if err := s.Sync(); err != nil {
+			return err
+		}
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if _, err := fmt.Fprintln(cp.f, i); err != nil {
+		return err
+	}
+	return cp.f.Sync()
+}
+
+// remove deletes the checkpoint once the download is complete.
+func (cp *checkpoint) remove() error {
+	return os.Remove(cp.path)
+}
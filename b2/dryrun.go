@@ -0,0 +1,433 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/burner-account/blazer/internal/blog"
+)
+
+// A DryRunOp describes a call that would have modified B2, but was skipped
+// because the client is in dry-run mode.
+type DryRunOp struct {
+	// Method is the B2 API call that was skipped, e.g. "b2_upload_file".
+	Method string
+
+	// Bucket is the name of the bucket that would have been modified, if any.
+	Bucket string
+
+	// Name is the name of the object or key that would have been modified, if
+	// any.
+	Name string
+
+	// Size is the number of bytes that would have been uploaded.
+	Size int64
+}
+
+func (op DryRunOp) String() string {
+	s := op.Method
+	if op.Bucket != "" {
+		s += " " + op.Bucket
+	}
+	if op.Name != "" {
+		s += "/" + op.Name
+	}
+	if op.Size > 0 {
+		s += fmt.Sprintf(" (%d bytes)", op.Size)
+	}
+	return s
+}
+
+// DryRun returns a ClientOption that turns every call that would modify B2
+// (uploads, deletes, hides, bucket and key changes) into a no-op.  Reads are
+// passed through as normal.  Each skipped call is logged, and passed to record
+// if it is not nil.
+//
+// Uploaded data is read in full and discarded, and operations on the
+// resulting objects behave as though the upload succeeded.  Nothing is
+// remembered between calls, however: an object "written" in dry-run mode will
+// not show up in a subsequent listing or download.
+func DryRun(record func(DryRunOp)) ClientOption {
+	return func(c *clientOptions) {
+		c.dryRun = true
+		c.dryRunRecord = record
+	}
+}
+
+type dryRunRoot struct {
+	b2RootInterface
+	record func(DryRunOp)
+}
+
+func (r *dryRunRoot) clone() b2RootInterface {
+	return &dryRunRoot{b2RootInterface: r.b2RootInterface.clone(), record: r.record}
+}
+
+func (r *dryRunRoot) log(op DryRunOp) {
+	blog.V(1).Infof("dry run: %v", op)
+	if r.record != nil {
+		r.record(op)
+	}
+}
+
+func (r *dryRunRoot) createBucket(ctx context.Context, name, btype string, info map[string]string, rules []LifecycleRule) (b2BucketInterface, error) {
+	r.log(DryRunOp{Method: "b2_create_bucket", Bucket: name})
+	return &dryRunBucket{
+		b2BucketInterface: &dryRunNewBucket{
+			n:  name,
+			bt: btype,
+			ba: &BucketAttrs{
+				Type:           BucketType(btype),
+				Info:           info,
+				LifecycleRules: rules,
+			},
+		},
+		r: r,
+	}, nil
+}
+
+func (r *dryRunRoot) listBuckets(ctx context.Context, name string) ([]b2BucketInterface, error) {
+	buckets, err := r.b2RootInterface.listBuckets(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for i, b := range buckets {
+		buckets[i] = &dryRunBucket{b2BucketInterface: b, r: r}
+	}
+	return buckets, nil
+}
+
+func (r *dryRunRoot) createKey(ctx context.Context, name string, caps []string, valid time.Duration, bucketID, prefix string) (b2KeyInterface, error) {
+	r.log(DryRunOp{Method: "b2_create_key", Name: name})
+	k := &dryRunKey{n: name, c: caps, r: r}
+	if valid > 0 {
+		k.exp = time.Now().Add(valid)
+	}
+	return k, nil
+}
+
+func (r *dryRunRoot) listKeys(ctx context.Context, max int, next string) ([]b2KeyInterface, string, error) {
+	keys, next, err := r.b2RootInterface.listKeys(ctx, max, next)
+	if err != nil {
+		return nil, "", err
+	}
+	for i, k := range keys {
+		keys[i] = &dryRunKey{b2KeyInterface: k, r: r}
+	}
+	return keys, next, nil
+}
+
+type dryRunBucket struct {
+	b2BucketInterface
+	r *dryRunRoot
+}
+
+func (b *dryRunBucket) log(method, name string, size int64) {
+	b.r.log(DryRunOp{Method: method, Bucket: b.name(), Name: name, Size: size})
+}
+
+func (b *dryRunBucket) wrap(f b2FileInterface) b2FileInterface {
+	return &dryRunFile{b2FileInterface: f, b: b}
+}
+
+func (b *dryRunBucket) wrapAll(fs []b2FileInterface) []b2FileInterface {
+	for i, f := range fs {
+		fs[i] = b.wrap(f)
+	}
+	return fs
+}
+
+func (b *dryRunBucket) updateBucket(context.Context, *BucketAttrs) error {
+	b.log("b2_update_bucket", "", 0)
+	return nil
+}
+
+func (b *dryRunBucket) deleteBucket(context.Context) error {
+	b.log("b2_delete_bucket", "", 0)
+	return nil
+}
+
+func (b *dryRunBucket) setNotificationRules(context.Context, []NotificationRule) error {
+	b.log("b2_set_bucket_notification_rules", "", 0)
+	return nil
+}
+
+func (b *dryRunBucket) getUploadURL(context.Context) (b2URLInterface, error) {
+	return &dryRunURL{b: b}, nil
+}
+
+func (b *dryRunBucket) startLargeFile(ctx context.Context, name, contentType string, info map[string]string) (b2LargeFileInterface, error) {
+	b.log("b2_start_large_file", name, 0)
+	return &dryRunLargeFile{
+		b: b,
+		f: &dryRunObject{n: name, ct: contentType, info: info, st: "start", t: time.Now()},
+	}, nil
+}
+
+func (b *dryRunBucket) listFileNames(ctx context.Context, count int, continuation, prefix, delimiter string, f func(b2FileInterface)) (string, error) {
+	return b.b2BucketInterface.listFileNames(ctx, count, continuation, prefix, delimiter, func(file b2FileInterface) {
+		f(b.wrap(file))
+	})
+}
+
+func (b *dryRunBucket) listFileVersions(ctx context.Context, count int, nextName, nextID, prefix, delimiter string) ([]b2FileInterface, string, string, error) {
+	fs, name, id, err := b.b2BucketInterface.listFileVersions(ctx, count, nextName, nextID, prefix, delimiter)
+	return b.wrapAll(fs), name, id, err
+}
+
+func (b *dryRunBucket) listUnfinishedLargeFiles(ctx context.Context, count int, continuation string) ([]b2FileInterface, string, error) {
+	fs, c, err := b.b2BucketInterface.listUnfinishedLargeFiles(ctx, count, continuation)
+	return b.wrapAll(fs), c, err
+}
+
+func (b *dryRunBucket) hideFile(ctx context.Context, name string) (b2FileInterface, error) {
+	b.log("b2_hide_file", name, 0)
+	return b.wrap(&dryRunObject{n: name, st: "hide", t: time.Now()}), nil
+}
+
+func (b *dryRunBucket) file(id, name string) b2FileInterface {
+	return b.wrap(b.b2BucketInterface.file(id, name))
+}
+
+// dryRunNewBucket stands in for a bucket that was "created" in dry-run mode.
+// It is always empty.
+type dryRunNewBucket struct {
+	b2BucketInterface // nil; mutating methods are handled by dryRunBucket
+
+	n  string
+	bt string
+	ba *BucketAttrs
+}
+
+func (b *dryRunNewBucket) name() string        { return b.n }
+func (b *dryRunNewBucket) btype() string       { return b.bt }
+func (b *dryRunNewBucket) attrs() *BucketAttrs { return b.ba }
+func (b *dryRunNewBucket) id() string          { return "" }
+func (b *dryRunNewBucket) baseURL() string     { return "" }
+
+func (b *dryRunNewBucket) getNotificationRules(context.Context) ([]NotificationRule, error) {
+	return nil, nil
+}
+
+func (b *dryRunNewBucket) listFileNames(context.Context, int, string, string, string, func(b2FileInterface)) (string, error) {
+	return "", nil
+}
+
+func (b *dryRunNewBucket) listFileVersions(context.Context, int, string, string, string, string) ([]b2FileInterface, string, string, error) {
+	return nil, "", "", nil
+}
+
+func (b *dryRunNewBucket) listUnfinishedLargeFiles(context.Context, int, string) ([]b2FileInterface, string, error) {
+	return nil, "", nil
+}
+
+func (b *dryRunNewBucket) downloadFileByName(_ context.Context, name string, _, _ int64, _ bool) (b2FileReaderInterface, error) {
+	return nil, b2err{err: fmt.Errorf("%s: not found", name), notFoundErr: true}
+}
+
+func (b *dryRunNewBucket) getDownloadAuthorization(context.Context, string, time.Duration, string) (string, error) {
+	return "", nil
+}
+
+func (b *dryRunNewBucket) file(id, name string) b2FileInterface {
+	return &dryRunObject{n: name}
+}
+
+type dryRunURL struct {
+	b *dryRunBucket
+}
+
+func (u *dryRunURL) reload(context.Context) error { return nil }
+func (u *dryRunURL) endpoint() (string, string)   { return "", "" }
+
+func (u *dryRunURL) uploadFile(ctx context.Context, r io.Reader, size int, name, contentType, sha1 string, info map[string]string) (b2FileInterface, error) {
+	n, err := io.Copy(ioutil.Discard, r)
+	if err != nil {
+		return nil, err
+	}
+	u.b.log("b2_upload_file", name, n)
+	return u.b.wrap(&dryRunObject{
+		n:    name,
+		sz:   n,
+		ct:   contentType,
+		sha:  sha1,
+		info: info,
+		st:   "upload",
+		t:    time.Now(),
+	}), nil
+}
+
+// dryRunFile intercepts mutating calls on files that exist in B2.
+type dryRunFile struct {
+	b2FileInterface
+	b *dryRunBucket
+}
+
+func (f *dryRunFile) deleteFileVersion(context.Context) error {
+	f.b.log("b2_delete_file_version", f.name(), 0)
+	return nil
+}
+
+func (f *dryRunFile) copyFile(ctx context.Context, bucketID, name, ctype string, info map[string]string) (b2FileInterface, error) {
+	f.b.log("b2_copy_file", name, f.size())
+	return f.b.wrap(&dryRunObject{n: name, sz: f.size(), ct: ctype, info: info, st: "upload", t: time.Now()}), nil
+}
+
+func (f *dryRunFile) compileParts(size int64, seen map[int]string) b2LargeFileInterface {
+	return &dryRunLargeFile{
+		b: f.b,
+		f: &dryRunObject{n: f.name(), sz: size, st: "upload", t: time.Now()},
+	}
+}
+
+type dryRunLargeFile struct {
+	b *dryRunBucket
+	f *dryRunObject
+
+	mu   sync.Mutex
+	size int64
+}
+
+func (l *dryRunLargeFile) finishLargeFile(context.Context) (b2FileInterface, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.f.sz += l.size
+	l.f.st = "upload"
+	l.b.log("b2_finish_large_file", l.f.n, l.f.sz)
+	return l.b.wrap(l.f), nil
+}
+
+func (l *dryRunLargeFile) getUploadPartURL(context.Context) (b2FileChunkInterface, error) {
+	return &dryRunFileChunk{l: l}, nil
+}
+
+func (l *dryRunLargeFile) cancel(context.Context) error {
+	l.b.log("b2_cancel_large_file", l.f.n, 0)
+	return nil
+}
+
+type dryRunFileChunk struct {
+	l *dryRunLargeFile
+}
+
+func (c *dryRunFileChunk) reload(context.Context) error { return nil }
+
+func (c *dryRunFileChunk) uploadPart(ctx context.Context, r io.Reader, sha1 string, size, index int) (int, error) {
+	n, err := io.Copy(ioutil.Discard, r)
+	if err != nil {
+		return int(n), err
+	}
+	c.l.mu.Lock()
+	c.l.size += n
+	c.l.mu.Unlock()
+	return int(n), nil
+}
+
+// dryRunObject stands in for a file that was "uploaded" in dry-run mode.
+type dryRunObject struct {
+	n    string
+	sz   int64
+	ct   string
+	sha  string
+	info map[string]string
+	st   string
+	t    time.Time
+}
+
+func (o *dryRunObject) name() string                            { return o.n }
+func (o *dryRunObject) id() string                              { return "" }
+func (o *dryRunObject) size() int64                             { return o.sz }
+func (o *dryRunObject) timestamp() time.Time                    { return o.t }
+func (o *dryRunObject) status() string                          { return o.st }
+func (o *dryRunObject) deleteFileVersion(context.Context) error { return nil }
+
+func (o *dryRunObject) copyFile(context.Context, string, string, string, map[string]string) (b2FileInterface, error) {
+	return nil, nil // dryRunFile handles this
+}
+
+func (o *dryRunObject) getFileInfo(context.Context) (b2FileInfoInterface, error) {
+	return o, nil
+}
+
+func (o *dryRunObject) listParts(context.Context, int, int) ([]b2FilePartInterface, int, error) {
+	return nil, 0, nil
+}
+
+func (o *dryRunObject) compileParts(int64, map[int]string) b2LargeFileInterface {
+	return nil // dryRunFile handles this
+}
+
+func (o *dryRunObject) stats() (string, string, int64, string, map[string]string, string, time.Time) {
+	info := make(map[string]string)
+	for k, v := range o.info {
+		info[k] = v
+	}
+	return o.n, o.sha, o.sz, o.ct, info, o.st, o.t
+}
+
+type dryRunKey struct {
+	b2KeyInterface // nil for keys "created" in dry-run mode
+
+	n   string
+	c   []string
+	exp time.Time
+	r   *dryRunRoot
+}
+
+func (k *dryRunKey) del(context.Context) error {
+	k.r.log(DryRunOp{Method: "b2_delete_key", Name: k.name()})
+	return nil
+}
+
+func (k *dryRunKey) name() string {
+	if k.b2KeyInterface != nil {
+		return k.b2KeyInterface.name()
+	}
+	return k.n
+}
+
+func (k *dryRunKey) caps() []string {
+	if k.b2KeyInterface != nil {
+		return k.b2KeyInterface.caps()
+	}
+	return k.c
+}
+
+func (k *dryRunKey) expires() time.Time {
+	if k.b2KeyInterface != nil {
+		return k.b2KeyInterface.expires()
+	}
+	return k.exp
+}
+
+func (k *dryRunKey) secret() string {
+	if k.b2KeyInterface != nil {
+		return k.b2KeyInterface.secret()
+	}
+	return ""
+}
+
+func (k *dryRunKey) id() string {
+	if k.b2KeyInterface != nil {
+		return k.b2KeyInterface.id()
+	}
+	return ""
+}
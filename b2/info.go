@@ -0,0 +1,119 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// File info keys that B2's own tools set, and that Attrs reads.
+const (
+	infoLastModified  = "src_last_modified_millis"
+	infoLargeFileSHA1 = "large_file_sha1"
+)
+
+// maxInfoKeys is the most info entries B2 allows a file.
+const maxInfoKeys = 10
+
+// IsReservedInfoKey reports whether k is used by blazer itself, and so should
+// not be set directly.  This includes the keys that WithS3ETag and
+// WithManifest write, the standard keys that have typed accessors on Info,
+// and any key beginning with "blazer", which is the prefix that blazer and
+// its x packages use for their own bookkeeping.
+func IsReservedInfoKey(k string) bool {
+	switch k {
+	case s3ETagKey, manifestKey, infoLastModified, infoLargeFileSHA1:
+		return true
+	}
+	return strings.HasPrefix(k, "blazer")
+}
+
+// Info is an object's info map, with typed accessors for the keys that have
+// standard meanings.  Any map[string]string, such as Attrs.Info, can be
+// converted to an Info, and an Info can be used wherever such a map is; the
+// accessors read and write the same map.
+//
+//	info := b2.Info(attrs.Info)
+//	if err := info.Set("owner", "bob"); err != nil { ... }
+type Info map[string]string
+
+// Get returns the value of k, or "" if it is unset.
+func (i Info) Get(k string) string {
+	return i[k]
+}
+
+// Set sets k to v.  It returns an error if k is reserved (see
+// IsReservedInfoKey), or if setting it would give the object more than the ten
+// entries that B2 allows.  To set a reserved key anyway, write to the map.
+func (i Info) Set(k, v string) error {
+	if IsReservedInfoKey(k) {
+		return fmt.Errorf("b2: info key %q is reserved", k)
+	}
+	return i.set(k, v)
+}
+
+func (i Info) set(k, v string) error {
+	if _, ok := i[k]; !ok && len(i) >= maxInfoKeys {
+		return fmt.Errorf("b2: cannot set info key %q: already %d keys", k, maxInfoKeys)
+	}
+	i[k] = v
+	return nil
+}
+
+// Delete removes k.  Like Set, it refuses to remove a reserved key.
+func (i Info) Delete(k string) error {
+	if IsReservedInfoKey(k) {
+		return fmt.Errorf("b2: info key %q is reserved", k)
+	}
+	delete(i, k)
+	return nil
+}
+
+// LastModified returns the source file's modification time, as recorded by
+// SetLastModified or by B2's command-line tool.
+func (i Info) LastModified() (time.Time, bool) {
+	v, ok := i[infoLastModified]
+	if !ok {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(ms/1e3, (ms%1e3)*1e6), true
+}
+
+// SetLastModified records the source file's modification time, to the
+// millisecond.
+func (i Info) SetLastModified(t time.Time) error {
+	return i.set(infoLastModified, strconv.FormatInt(t.UnixNano()/1e6, 10))
+}
+
+// LargeFileSHA1 returns the hex SHA-1 of a large file's contents, as recorded
+// by SetLargeFileSHA1.  B2 computes no SHA-1 of its own for large files.
+func (i Info) LargeFileSHA1() (string, bool) {
+	v, ok := i[infoLargeFileSHA1]
+	return v, ok
+}
+
+// SetLargeFileSHA1 records the hex SHA-1 of a large file's contents.  It must
+// be set before the upload begins, and so is generally only known for files
+// copied from elsewhere.
+func (i Info) SetLargeFileSHA1(sha1 string) error {
+	return i.set(infoLargeFileSHA1, sha1)
+}
@@ -281,6 +281,42 @@ func TestResumeWriter(t *testing.T) {
 	}
 }
 
+func TestResumeWriterSeekable(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	bucket, done := startLiveTest(ctx, t)
+	defer done()
+
+	data := make([]byte, 15e6)
+	if _, err := io.ReadFull(rand.Reader, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Leave a large file unfinished, with only its first part uploaded.
+	w := bucket.Object("foo").NewWriter(ctx)
+	w.ChunkSize = 5e6
+	if _, err := w.Write(data[:6e6]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	w2 := bucket.Object("foo").NewWriter(ctx)
+	w2.ChunkSize = 5e6
+	w2.Resume = true
+	if _, err := io.Copy(w2, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := readFile(ctx, bucket.Object("foo"), fmt.Sprintf("%x", sha1.Sum(data)), 5e6, 3); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestResumeWriterWithoutExtantFile(t *testing.T) {
 	ctx := context.Background()
 	bucket, done := startLiveTest(ctx, t)
@@ -807,6 +843,35 @@ func TestWriteEmpty(t *testing.T) {
 	}
 }
 
+func TestVerifyManifest(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	bucket, done := startLiveTest(ctx, t)
+	defer done()
+
+	data := make([]byte, 1e7+42)
+	if _, err := io.ReadFull(rand.Reader, data); err != nil {
+		t.Fatal(err)
+	}
+	obj := bucket.Object(largeFileName)
+	w := obj.NewWriter(ctx, WithManifest())
+	w.ChunkSize = 5e6
+	if _, err := io.Copy(w, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(w.Manifest().Parts); n != 3 {
+		t.Errorf("got %d parts in the manifest, want 3", n)
+	}
+	if err := bucket.Object(largeFileName).VerifyManifest(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestAttrsNoRoundtrip(t *testing.T) {
 	ctx := context.Background()
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
@@ -953,6 +1018,39 @@ func TestListUnfinishedLargeFiles(t *testing.T) {
 	}
 }
 
+func TestPurgeUnfinishedLargeFiles(t *testing.T) {
+	ctx := context.Background()
+	bucket, done := startLiveTest(ctx, t)
+	defer done()
+
+	w := bucket.Object(largeFileName).NewWriter(ctx)
+	w.ChunkSize = 1e5
+	if _, err := io.Copy(w, io.LimitReader(zReader{}, 1e6)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := bucket.PurgeUnfinishedLargeFiles(ctx, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("PurgeUnfinishedLargeFiles(1h): purged %d files, want 0", n)
+	}
+	n, err = bucket.PurgeUnfinishedLargeFiles(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("PurgeUnfinishedLargeFiles(0): purged %d files, want 1", n)
+	}
+	iter := bucket.List(ctx, ListUnfinished())
+	if iter.Next() {
+		t.Errorf("ListUnfinished: got %s, want none", iter.Object().Name())
+	}
+	if err := iter.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestReauthPreservesOptions(t *testing.T) {
 	ctx := context.Background()
 	bucket, done := startLiveTest(ctx, t)
@@ -1214,6 +1312,50 @@ func TestListKeys(t *testing.T) {
 	}
 }
 
+func TestUploadTicket(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	bucket, done := startLiveTest(ctx, t)
+	defer done()
+
+	tk, err := bucket.NewUploadTicket(ctx, smallFileName, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tk.Revoke(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	data := []byte("uploaded by someone else")
+	req, err := http.NewRequest("POST", tk.URL, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", tk.Token)
+	req.Header.Set("X-Bz-File-Name", tk.Name)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("X-Bz-Content-Sha1", fmt.Sprintf("%x", sha1.Sum(data)))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("upload: %s", resp.Status)
+	}
+
+	if _, err := bucket.VerifyUpload(ctx, tk, int64(len(data))); err != nil {
+		t.Error(err)
+	}
+	if _, err := bucket.VerifyUpload(ctx, tk, 1); err == nil {
+		t.Error("VerifyUpload: got no error for an oversized object")
+	}
+}
+
 func TestEmptyObject(t *testing.T) {
 	ctx := context.Background()
 	bucket, done := startLiveTest(ctx, t)
@@ -0,0 +1,84 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// IsolatedBucket is like Bucket, but the returned bucket makes its requests
+// apart from the rest of the client, with the client's options as modified by
+// opts.  This allows, for instance, a latency-sensitive bucket and a bulk
+// backup bucket to share credentials without sharing fate: each can be given
+// its own Transport, MaxConcurrentRequests, or MaxRetries.
+//
+// The bucket has its own authorization token, obtained with the client's
+// credentials, and its own request limits, which start with the client's
+// values unless opts sets them; the client's limits do not apply to it.  Its
+// readers and writers are still reported in the client's Status.  Options that
+// govern Writers and attribute caching, such as DefaultWriterOptions and
+// BucketAttrsTTL, are the client's and are not overridden.
+//
+// A bucket isolated from a dry-run client is itself dry-run.  DryRun may also
+// be given in opts, to make the isolated bucket alone dry-run, or to record its
+// skipped calls separately.
+func (c *Client) IsolatedBucket(ctx context.Context, name string, opts ...ClientOption) (*Bucket, error) {
+	br, ok := c.backend.(*beRoot)
+	if !ok {
+		return nil, errors.New("b2: client does not support isolated buckets")
+	}
+	o := c.opts
+	o.userAgents = append([]string(nil), c.opts.userAgents...)
+	o.writerOpts = append([]WriterOption(nil), c.opts.writerOpts...)
+	for _, f := range opts {
+		f(&o)
+	}
+	o.client = c
+	o.limits = newRequestLimiter(o)
+	if o.limits == nil {
+		o.limits = &requestLimiter{} // unlimited, rather than the client's
+	}
+	b2i := br.b2i.clone()
+	if o.dryRun {
+		if d, ok := b2i.(*dryRunRoot); ok {
+			b2i = d.b2RootInterface
+		}
+		b2i = &dryRunRoot{b2RootInterface: b2i, record: o.dryRunRecord}
+	}
+	root := &beRoot{b2i: b2i}
+	if err := root.authorizeAccount(ctx, br.account, br.key, o); err != nil {
+		return nil, err
+	}
+	buckets, err := root.listBuckets(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, bucket := range buckets {
+		if bucket.name() == name {
+			return &Bucket{
+				b:       bucket,
+				r:       root,
+				c:       c,
+				urlPool: c.newURLPool(),
+			}, nil
+		}
+	}
+	return nil, b2err{
+		err:         fmt.Errorf("%s: bucket not found", name),
+		notFoundErr: true,
+	}
+}
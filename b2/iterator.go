@@ -53,19 +53,64 @@ type ObjectIterator struct {
 	c      *cursor
 	opts   objectIteratorOptions
 	objs   []*Object
+	obj    *Object
 	init   sync.Once
 	l      lister
+	s      streamer
+	sp     *streamedPage
 	count  int
 }
 
 type lister func(context.Context, int, *cursor) ([]*Object, *cursor, error)
 
+// A streamer is a lister that passes each object to f as it is read, rather
+// than collecting them into a page.
+type streamer func(context.Context, int, *cursor, func(*Object)) (*cursor, error)
+
+// A streamedPage is a page of objects that is being read.  When objs is
+// closed, c and err are set.
+type streamedPage struct {
+	objs chan *Object
+	c    *cursor
+	err  error
+}
+
 func (o *ObjectIterator) page(ctx context.Context) error {
 	if o.opts.locker != nil {
 		o.opts.locker.Lock()
 		defer o.opts.locker.Unlock()
 	}
 	objs, c, err := o.l(ctx, o.count, o.c)
+	if err := o.pageDone(c, err); err != nil {
+		return err
+	}
+	o.objs = objs
+	o.idx = 0
+	return nil
+}
+
+// stream starts reading a page of objects, which Next takes from o.sp as they
+// arrive.  The page is read to its end even if the iterator is abandoned, so
+// that the request's connection can be reused.
+func (o *ObjectIterator) stream(ctx context.Context) {
+	size := o.count
+	if size == 0 {
+		size = 1000
+	}
+	sp := &streamedPage{objs: make(chan *Object, size)}
+	o.sp = sp
+	go func() {
+		if o.opts.locker != nil {
+			o.opts.locker.Lock()
+			defer o.opts.locker.Unlock()
+		}
+		sp.c, sp.err = o.s(ctx, o.count, o.c, func(obj *Object) { sp.objs <- obj })
+		close(sp.objs)
+	}()
+}
+
+// pageDone records the cursor and error returned by a lister or streamer.
+func (o *ObjectIterator) pageDone(c *cursor, err error) error {
 	if err != nil && err != io.EOF {
 		if bNotExist.MatchString(err.Error()) {
 			return b2err{
@@ -76,8 +121,6 @@ func (o *ObjectIterator) page(ctx context.Context) error {
 		return err
 	}
 	o.c = c
-	o.objs = objs
-	o.idx = 0
 	if err == io.EOF {
 		o.final = true
 	}
@@ -103,11 +146,18 @@ func (o *ObjectIterator) Next() bool {
 		case o.opts.hidden:
 			o.l = o.bucket.listObjects
 		default:
-			o.l = o.bucket.listCurrentObjects
+			o.s = o.bucket.streamCurrentObjects
 		}
 		o.c = &cursor{
 			prefix:    o.opts.prefix,
 			delimiter: o.opts.delimiter,
+			name:      o.opts.startName,
+			id:        o.opts.startID,
+		}
+		if o.opts.unfinished {
+			// Unfinished large files are paged by ID alone.
+			o.c.name = o.opts.startID
+			o.c.id = ""
 		}
 	})
 	if o.err != nil {
@@ -117,24 +167,42 @@ func (o *ObjectIterator) Next() bool {
 		o.err = o.ctx.Err()
 		return false
 	}
+	if o.sp != nil {
+		if obj, ok := <-o.sp.objs; ok {
+			o.obj = obj
+			return true
+		}
+		sp := o.sp
+		o.sp = nil
+		if err := o.pageDone(sp.c, sp.err); err != nil {
+			o.err = err
+			return false
+		}
+		return o.Next()
+	}
 	if o.idx >= len(o.objs) {
 		if o.final {
 			o.err = io.EOF
 			return false
 		}
+		if o.s != nil {
+			o.stream(o.ctx)
+			return o.Next()
+		}
 		if err := o.page(o.ctx); err != nil {
 			o.err = err
 			return false
 		}
 		return o.Next()
 	}
+	o.obj = o.objs[o.idx]
 	o.idx++
 	return true
 }
 
 // Object returns the current object.
 func (o *ObjectIterator) Object() *Object {
-	return o.objs[o.idx-1]
+	return o.obj
 }
 
 // Err returns the current error or nil.  If Next() returns false and Err() is
@@ -153,6 +221,8 @@ type objectIteratorOptions struct {
 	delimiter  string
 	pageSize   int
 	locker     sync.Locker
+	startName  string
+	startID    string
 }
 
 // A ListOption alters the default behavor of List.
@@ -216,6 +286,18 @@ func ListLocker(l sync.Locker) ListOption {
 	}
 }
 
+// ListStart begins the listing at the given object name, inclusive.  When
+// listing with ListHidden, a non-empty id will begin the listing at that
+// specific version of the named object, which allows a previous listing to be
+// resumed exactly.  With ListUnfinished, only id is used, and name is ignored.
+// Otherwise, id is ignored.
+func ListStart(name, id string) ListOption {
+	return func(o *objectIteratorOptions) {
+		o.startName = name
+		o.startID = id
+	}
+}
+
 type cursor struct {
 	// Prefix limits the listed objects to those that begin with this string.
 	prefix string
@@ -270,13 +352,21 @@ func (b *Bucket) listObjects(ctx context.Context, count int, c *cursor) ([]*Obje
 	return objects, next, rtnErr
 }
 
-func (b *Bucket) listCurrentObjects(ctx context.Context, count int, c *cursor) ([]*Object, *cursor, error) {
+func (b *Bucket) streamCurrentObjects(ctx context.Context, count int, c *cursor, fn func(*Object)) (*cursor, error) {
 	if c == nil {
 		c = &cursor{}
 	}
-	fs, name, err := b.b.listFileNames(ctx, count, c.name, c.prefix, c.delimiter)
+	var n int
+	name, err := b.b.listFileNames(ctx, count, c.name, c.prefix, c.delimiter, func(f beFileInterface) {
+		n++
+		fn(&Object{
+			name: f.name(),
+			f:    f,
+			b:    b,
+		})
+	})
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	var next *cursor
 	if name != "" {
@@ -286,19 +376,11 @@ func (b *Bucket) listCurrentObjects(ctx context.Context, count int, c *cursor) (
 			name:      name,
 		}
 	}
-	var objects []*Object
-	for _, f := range fs {
-		objects = append(objects, &Object{
-			name: f.name(),
-			f:    f,
-			b:    b,
-		})
-	}
 	var rtnErr error
-	if len(objects) == 0 || next == nil {
+	if n == 0 || next == nil {
 		rtnErr = io.EOF
 	}
-	return objects, next, rtnErr
+	return next, rtnErr
 }
 
 func (b *Bucket) listUnfinishedLargeFiles(ctx context.Context, count int, c *cursor) ([]*Object, *cursor, error) {
@@ -17,8 +17,12 @@ package b2
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"time"
+
+	"github.com/burner-account/blazer/internal/b2types"
+	"github.com/burner-account/blazer/internal/blog"
 )
 
 // Key is a B2 application key.  A Key grants limited access on a global or
@@ -53,6 +57,7 @@ func (k *Key) ID() string { return k.k.id() }
 
 type keyOptions struct {
 	caps     []string
+	granted  []Capability // those of caps given with Grant
 	prefix   string
 	lifetime time.Duration
 }
@@ -73,13 +78,94 @@ func Deadline(t time.Time) KeyOption {
 	return Lifetime(d)
 }
 
-// Capabilities requests a key with the given capability.
+// Capabilities requests a key with the given capability.  Capabilities that
+// this package does not know are passed to B2 as they are, so that ones added
+// since can still be requested, but they are logged.
 func Capabilities(caps ...string) KeyOption {
 	return func(k *keyOptions) {
 		k.caps = append(k.caps, caps...)
 	}
 }
 
+// A Capability is a permission that an application key can grant.
+type Capability = b2types.Capability
+
+// The capabilities that B2 understands.
+const (
+	CapListKeys                 = b2types.ListKeys
+	CapWriteKeys                = b2types.WriteKeys
+	CapDeleteKeys               = b2types.DeleteKeys
+	CapListBuckets              = b2types.ListBuckets
+	CapListAllBucketNames       = b2types.ListAllBucketNames
+	CapReadBuckets              = b2types.ReadBuckets
+	CapWriteBuckets             = b2types.WriteBuckets
+	CapDeleteBuckets            = b2types.DeleteBuckets
+	CapReadBucketRetentions     = b2types.ReadBucketRetentions
+	CapWriteBucketRetentions    = b2types.WriteBucketRetentions
+	CapReadBucketEncryption     = b2types.ReadBucketEncryption
+	CapWriteBucketEncryption    = b2types.WriteBucketEncryption
+	CapReadBucketReplications   = b2types.ReadBucketReplications
+	CapWriteBucketReplications  = b2types.WriteBucketReplications
+	CapReadBucketNotifications  = b2types.ReadBucketNotifications
+	CapWriteBucketNotifications = b2types.WriteBucketNotifications
+	CapListFiles                = b2types.ListFiles
+	CapReadFiles                = b2types.ReadFiles
+	CapShareFiles               = b2types.ShareFiles
+	CapWriteFiles               = b2types.WriteFiles
+	CapDeleteFiles              = b2types.DeleteFiles
+	CapReadFileLegalHolds       = b2types.ReadFileLegalHolds
+	CapWriteFileLegalHolds      = b2types.WriteFileLegalHolds
+	CapReadFileRetentions       = b2types.ReadFileRetentions
+	CapWriteFileRetentions      = b2types.WriteFileRetentions
+	CapBypassGovernance         = b2types.BypassGovernance
+)
+
+// AllCapabilities returns every capability that B2 understands.
+func AllCapabilities() []Capability {
+	return append([]Capability(nil), b2types.Capabilities...)
+}
+
+// Grant requests a key with the given capabilities.  It is the typed
+// equivalent of Capabilities, except that capabilities this package does not
+// know cause CreateKey to fail; see ValidateCapabilities.
+func Grant(caps ...Capability) KeyOption {
+	return func(k *keyOptions) {
+		for _, c := range caps {
+			k.caps = append(k.caps, string(c))
+		}
+		k.granted = append(k.granted, caps...)
+	}
+}
+
+// ValidateCapabilities returns an error naming the first of caps that is not a
+// capability this package knows, such as "writeFile" for "writeFiles".  It is
+// meant for checking input from users; B2 may understand capabilities added
+// since this package was written.
+func ValidateCapabilities(caps ...string) error {
+	for _, c := range caps {
+		if !Capability(c).Valid() {
+			return fmt.Errorf("b2: unknown capability %q", c)
+		}
+	}
+	return nil
+}
+
+// check returns an error if a capability given with Grant is unknown, and logs
+// those given with Capabilities.
+func (ko *keyOptions) check() error {
+	for _, c := range ko.granted {
+		if !c.Valid() {
+			return fmt.Errorf("b2: unknown capability %q", c)
+		}
+	}
+	for _, c := range ko.caps {
+		if !Capability(c).Valid() {
+			blog.V(1).Infof("b2: requesting unknown capability %q", c)
+		}
+	}
+	return nil
+}
+
 // Prefix limits the requested application key to be valid only for objects
 // that begin with prefix.  This can only be used when requesting an
 // application key within a specific bucket.
@@ -100,6 +186,9 @@ func (c *Client) CreateKey(ctx context.Context, name string, opts ...KeyOption)
 	if ko.prefix != "" {
 		return nil, errors.New("Prefix is not a valid option for global application keys")
 	}
+	if err := ko.check(); err != nil {
+		return nil, err
+	}
 	ki, err := c.backend.createKey(ctx, name, ko.caps, ko.lifetime, "", "")
 	if err != nil {
 		return nil, err
@@ -145,6 +234,9 @@ func (b *Bucket) CreateKey(ctx context.Context, name string, opts ...KeyOption)
 	for _, o := range opts {
 		o(&ko)
 	}
+	if err := ko.check(); err != nil {
+		return nil, err
+	}
 	ki, err := b.r.createKey(ctx, name, ko.caps, ko.lifetime, b.b.id(), ko.prefix)
 	if err != nil {
 		return nil, err
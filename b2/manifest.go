@@ -0,0 +1,173 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// manifestKey is the file info key under which WithManifest stores the
+// manifest.
+const manifestKey = "blazer_manifest"
+
+// A Manifest describes the contents of an object in enough detail to check
+// it end to end: the size and SHA-1 of each part, and the SHA-256 of the
+// whole.  B2 does not record a SHA-1 for large files, so for them a manifest
+// is the only way to verify a download against what was uploaded.
+type Manifest struct {
+	Size     int64  // The size of the object.
+	PartSize int64  // The size of every part but the last.
+	Parts    []Part // Numbered from 1.
+	SHA256   string // The hex SHA-256 of the whole object.
+}
+
+// encode returns the form of m that is stored in the object's info.  File
+// info values are limited in size, and large files can have thousands of
+// parts, so rather than every part's SHA-1, it holds the SHA-1 of their
+// concatenation.
+func (m *Manifest) encode() string {
+	h := sha1.New()
+	for _, p := range m.Parts {
+		io.WriteString(h, p.SHA1)
+	}
+	return fmt.Sprintf("v1,size=%d,part=%d,n=%d,sha256=%s,parts=%x", m.Size, m.PartSize, len(m.Parts), m.SHA256, h.Sum(nil))
+}
+
+// manifestPartSize extracts the part size from an encoded manifest.
+func manifestPartSize(s string) (int64, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) == 0 || fields[0] != "v1" {
+		return 0, fmt.Errorf("b2: unknown manifest format %q", s)
+	}
+	for _, f := range fields[1:] {
+		if v := strings.TrimPrefix(f, "part="); v != f {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("b2: bad part size in manifest %q", s)
+			}
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("b2: no part size in manifest %q", s)
+}
+
+// sumManifest reads r to the end and returns its manifest, splitting it into
+// parts of partSize bytes.  Empty data has one empty part, as it does when
+// uploaded.
+func sumManifest(ctx context.Context, r io.Reader, partSize int64) (*Manifest, error) {
+	m := &Manifest{PartSize: partSize}
+	whole := sha256.New()
+	for {
+		h := sha1.New()
+		n, err := copyContext(ctx, io.MultiWriter(h, whole), io.LimitReader(r, partSize))
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 && len(m.Parts) > 0 {
+			break
+		}
+		m.Parts = append(m.Parts, Part{Number: len(m.Parts) + 1, Size: n, SHA1: fmt.Sprintf("%x", h.Sum(nil))})
+		m.Size += n
+		if n < partSize {
+			break
+		}
+	}
+	m.SHA256 = fmt.Sprintf("%x", whole.Sum(nil))
+	return m, nil
+}
+
+// WithManifest records a Manifest of the data written in the object's info,
+// provided there is room, so that the object can later be checked with
+// VerifyManifest.
+//
+// As with WithS3ETag, B2 fixes the info of a large file when the upload
+// begins, so for large files the manifest can only be saved if the data is
+// known beforehand, as it is when the writer is given an io.ReadSeeker with
+// io.Copy (or ReadFrom); the source is then read twice.  In all cases the
+// manifest is available from Writer.Manifest once the writer has been closed.
+func WithManifest() WriterOption {
+	return func(w *Writer) {
+		w.manifest = true
+	}
+}
+
+// Manifest returns a Manifest of the data written, if WithManifest was given.
+// It is only valid after Close returns without error.
+func (w *Writer) Manifest() *Manifest {
+	if !w.manifest || !w.everStarted {
+		return nil
+	}
+	if w.mfstKnown || w.cidx == 0 {
+		return w.mfst
+	}
+	m := &Manifest{
+		PartSize: int64(w.csize),
+		Parts:    w.Parts(),
+		SHA256:   fmt.Sprintf("%x", w.msha256.Sum(nil)),
+	}
+	for _, p := range m.Parts {
+		m.Size += p.Size
+	}
+	return m
+}
+
+// simpleManifest returns the manifest of a file that is sent in one piece.
+func (w *Writer) simpleManifest() *Manifest {
+	size := int64(w.w.Len())
+	return &Manifest{
+		Size:     size,
+		PartSize: int64(w.csize),
+		Parts:    []Part{{Number: 1, Size: size, SHA1: fmt.Sprintf("%x", w.msha1.Sum(nil))}},
+		SHA256:   fmt.Sprintf("%x", w.msha256.Sum(nil)),
+	}
+}
+
+// VerifyManifest downloads the object and checks it against the manifest
+// recorded by WithManifest.  It returns an error if the object has no
+// manifest, or if its contents do not match.
+func (o *Object) VerifyManifest(ctx context.Context) error {
+	attrs, err := o.Attrs(ctx)
+	if err != nil {
+		return err
+	}
+	want, ok := attrs.Info[manifestKey]
+	if !ok {
+		return fmt.Errorf("b2: %s has no manifest", o.name)
+	}
+	psize, err := manifestPartSize(want)
+	if err != nil {
+		return err
+	}
+	r := o.NewReader(ctx)
+	defer r.Close()
+	return verifyManifest(ctx, r, want, psize)
+}
+
+func verifyManifest(ctx context.Context, r io.Reader, want string, partSize int64) error {
+	m, err := sumManifest(ctx, r, partSize)
+	if err != nil {
+		return err
+	}
+	if got := m.encode(); got != want {
+		return fmt.Errorf("b2: manifest mismatch: got %q, want %q", got, want)
+	}
+	return nil
+}
@@ -23,7 +23,7 @@ import (
 	"time"
 
 	"github.com/burner-account/blazer/internal/b2assets"
-	"github.com/burner-account/blazer/x/window"
+	"github.com/burner-account/blazer/internal/window"
 )
 
 // StatusInfo reports information about a client.
@@ -118,12 +118,12 @@ func (c *Client) Status() *StatusInfo {
 		RPCs:    make(map[time.Duration]MethodList),
 	}
 
-	for name, w := range c.sWriters {
-		si.Writers[name] = w.status()
+	for w := range c.sWriters {
+		si.Writers[fmt.Sprintf("%s/%s", w.o.b.Name(), w.name)] = w.status()
 	}
 
-	for name, r := range c.sReaders {
-		si.Readers[name] = r.status()
+	for r := range c.sReaders {
+		si.Readers[fmt.Sprintf("%s/%s", r.o.b.Name(), r.name)] = r.status()
 	}
 
 	for _, c := range c.sMethods {
@@ -155,10 +155,10 @@ func (c *Client) addWriter(w *Writer) {
 	defer c.slock.Unlock()
 
 	if c.sWriters == nil {
-		c.sWriters = make(map[string]*Writer)
+		c.sWriters = make(map[*Writer]bool)
 	}
 
-	c.sWriters[fmt.Sprintf("%s/%s", w.o.b.Name(), w.name)] = w
+	c.sWriters[w] = true
 }
 
 func (c *Client) removeWriter(w *Writer) {
@@ -169,7 +169,7 @@ func (c *Client) removeWriter(w *Writer) {
 		return
 	}
 
-	delete(c.sWriters, fmt.Sprintf("%s/%s", w.o.b.Name(), w.name))
+	delete(c.sWriters, w)
 }
 
 func (c *Client) addReader(r *Reader) {
@@ -177,10 +177,10 @@ func (c *Client) addReader(r *Reader) {
 	defer c.slock.Unlock()
 
 	if c.sReaders == nil {
-		c.sReaders = make(map[string]*Reader)
+		c.sReaders = make(map[*Reader]bool)
 	}
 
-	c.sReaders[fmt.Sprintf("%s/%s", r.o.b.Name(), r.name)] = r
+	c.sReaders[r] = true
 }
 
 func (c *Client) removeReader(r *Reader) {
@@ -191,7 +191,7 @@ func (c *Client) removeReader(r *Reader) {
 		return
 	}
 
-	delete(c.sReaders, fmt.Sprintf("%s/%s", r.o.b.Name(), r.name))
+	delete(c.sReaders, r)
 }
 
 var (
@@ -0,0 +1,137 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits that B2 places on object names and info.
+const (
+	maxNameBytes    = 1024 // in a whole name
+	maxSegmentBytes = 250  // between slashes
+	maxInfoKeyLen   = 50
+	maxHeaderBytes  = 7000 // the name and info together, as sent in headers
+)
+
+// An InvalidNameError reports an object name that B2 would reject.
+type InvalidNameError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvalidNameError) Error() string {
+	return fmt.Sprintf("b2: invalid object name %q: %s", e.Name, e.Reason)
+}
+
+// An InvalidInfoError reports object info that B2 would reject.  Key is empty
+// if the problem is with the info as a whole.
+type InvalidInfoError struct {
+	Key    string
+	Reason string
+}
+
+func (e *InvalidInfoError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("b2: invalid info: %s", e.Reason)
+	}
+	return fmt.Sprintf("b2: invalid info key %q: %s", e.Key, e.Reason)
+}
+
+// ValidateName returns an *InvalidNameError if B2 would not accept name as the
+// name of an object.  Names must be valid UTF-8 of at most 1024 bytes, with at
+// most 250 bytes between slashes; must not contain control characters,
+// backslashes, or "//"; and must not begin or end with a slash.
+//
+// Writers check their object's name before uploading anything.
+func ValidateName(name string) error {
+	bad := func(reason string) error { return &InvalidNameError{Name: name, Reason: reason} }
+	switch {
+	case name == "":
+		return bad("empty")
+	case !utf8.ValidString(name):
+		return bad("not valid UTF-8")
+	case len(name) > maxNameBytes:
+		return bad(fmt.Sprintf("longer than %d bytes", maxNameBytes))
+	case strings.HasPrefix(name, "/"):
+		return bad("begins with a slash")
+	case strings.HasSuffix(name, "/"):
+		return bad("ends with a slash")
+	case strings.Contains(name, "//"):
+		return bad("contains \"//\"")
+	case strings.Contains(name, `\`):
+		return bad("contains a backslash")
+	}
+	for _, r := range name {
+		if r < 32 || r == 127 {
+			return bad(fmt.Sprintf("contains control character %U", r))
+		}
+	}
+	for _, seg := range strings.Split(name, "/") {
+		if len(seg) > maxSegmentBytes {
+			return bad(fmt.Sprintf("has a segment longer than %d bytes", maxSegmentBytes))
+		}
+	}
+	return nil
+}
+
+// NormalizeName rewrites name into the form that B2 accepts, if it can: it
+// turns backslashes into slashes, collapses repeated slashes, and removes
+// leading and trailing slashes.  The result is then checked with
+// ValidateName.
+func NormalizeName(name string) (string, error) {
+	n := strings.ReplaceAll(name, `\`, "/")
+	for strings.Contains(n, "//") {
+		n = strings.ReplaceAll(n, "//", "/")
+	}
+	n = strings.Trim(n, "/")
+	if err := ValidateName(n); err != nil {
+		return "", err
+	}
+	return n, nil
+}
+
+// ValidateInfo returns an *InvalidInfoError if B2 would not accept info for
+// an object of the given name: if there are more than ten keys, if a key is
+// longer than 50 characters or contains anything but letters, digits, "-" and
+// "_", or if the name and info together would exceed the 7000 bytes that B2
+// allows them in request headers.
+//
+// Writers check their info before uploading anything, but blazer may add keys
+// of its own, for options such as WithS3ETag, after this check.
+func ValidateInfo(name string, info map[string]string) error {
+	if len(info) > maxInfoKeys {
+		return &InvalidInfoError{Reason: fmt.Sprintf("%d keys; at most %d are allowed", len(info), maxInfoKeys)}
+	}
+	size := len(url.QueryEscape(name))
+	for k, v := range info {
+		if k == "" || len(k) > maxInfoKeyLen {
+			return &InvalidInfoError{Key: k, Reason: fmt.Sprintf("keys must be 1 to %d characters", maxInfoKeyLen)}
+		}
+		for _, r := range k {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return &InvalidInfoError{Key: k, Reason: fmt.Sprintf("contains %q", r)}
+			}
+		}
+		size += len("X-Bz-Info-") + len(k) + len(url.QueryEscape(v))
+	}
+	if size > maxHeaderBytes {
+		return &InvalidInfoError{Reason: fmt.Sprintf("name and info take %d bytes; at most %d are allowed", size, maxHeaderBytes)}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import "context"
+
+// A NotificationRule has B2 send a webhook to URL for each event of the given
+// types, such as "b2:ObjectCreated:*", on objects beginning with Prefix.
+type NotificationRule struct {
+	Name       string
+	EventTypes []string
+	Prefix     string
+	Enabled    bool
+
+	URL           string
+	CustomHeaders map[string]string
+
+	// SigningSecret, if set, is used to sign each webhook with HMAC-SHA256.
+	SigningSecret string
+
+	// B2 suspends rules whose webhooks keep failing.  Suspended and
+	// SuspensionReason are ignored when setting rules; to resume a rule, set
+	// it again.
+	Suspended        bool
+	SuspensionReason string
+}
+
+// NotificationRules returns the bucket's event notification rules.
+func (b *Bucket) NotificationRules(ctx context.Context) ([]NotificationRule, error) {
+	return b.b.getNotificationRules(ctx)
+}
+
+// SetNotificationRules replaces the bucket's event notification rules with
+// rules.  An empty list removes them all.
+func (b *Bucket) SetNotificationRules(ctx context.Context, rules []NotificationRule) error {
+	return b.b.setNotificationRules(ctx, rules)
+}
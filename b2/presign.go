@@ -0,0 +1,155 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// uploadTokenLifetime is how long B2 honors the token from b2_get_upload_url.
+const uploadTokenLifetime = 24 * time.Hour
+
+// An UploadTicket lets an untrusted client, such as a web browser, upload one
+// object directly to B2, without passing the data through the server or
+// revealing its application key.  It is meant to be sent to the client as
+// JSON; the client then POSTs the file to URL as described for
+// b2_upload_file, with the Authorization header set to Token and the
+// X-Bz-File-Name header set to Name.
+type UploadTicket struct {
+	URL     string    `json:"uploadUrl"`
+	Token   string    `json:"authorizationToken"`
+	Bucket  string    `json:"bucketName"`
+	Name    string    `json:"fileName"`
+	Issued  time.Time `json:"issued"`
+	Expires time.Time `json:"expires"`
+
+	key *Key // the key the token was made with, if issued by this process
+}
+
+// endpointOptions returns the options a client made with another key needs
+// to reach B2 the way the client with options o does.  Limits, retry and
+// dry-run callbacks, and the audit log are left with the original client.
+func endpointOptions(o clientOptions) []ClientOption {
+	opts := []ClientOption{
+		Transport(o.transport),
+		DownloadTransport(o.downloadTransport),
+		APIBase(o.apiBase),
+		APIURL(o.apiURL),
+		DownloadURL(o.downloadURL),
+		WithClock(o.clock),
+	}
+	for _, ua := range o.userAgents {
+		opts = append(opts, UserAgent(ua))
+	}
+	return opts
+}
+
+// NewUploadTicket issues a ticket for uploading the named object, valid for at
+// most 24 hours, the lifetime of an upload token.  The token is obtained with
+// a new application key that can only write to this bucket, and only objects
+// whose names begin with name, and that expires along with the ticket.
+// Expired keys still count toward the account's keys until they are deleted,
+// so callers should Revoke tickets once the upload is verified or abandoned.
+//
+// Since B2 limits keys by prefix, a client could also upload objects whose
+// names merely begin with name.  VerifyUpload only accepts the named object.
+func (b *Bucket) NewUploadTicket(ctx context.Context, name string, valid time.Duration) (*UploadTicket, error) {
+	if name == "" {
+		return nil, errors.New("b2: an upload ticket needs an object name")
+	}
+	if valid <= 0 {
+		return nil, fmt.Errorf("b2: bad upload ticket lifetime %v", valid)
+	}
+	if valid > uploadTokenLifetime {
+		valid = uploadTokenLifetime
+	}
+	issued := time.Now()
+	key, err := b.CreateKey(ctx, "upload-ticket", Grant(CapListBuckets, CapWriteFiles), Prefix(name), Lifetime(valid))
+	if err != nil {
+		return nil, err
+	}
+	uri, token, err := b.uploadEndpoint(ctx, key)
+	if err != nil {
+		key.Delete(ctx)
+		return nil, err
+	}
+	return &UploadTicket{
+		URL:     uri,
+		Token:   token,
+		Bucket:  b.Name(),
+		Name:    name,
+		Issued:  issued,
+		Expires: issued.Add(valid),
+		key:     key,
+	}, nil
+}
+
+// uploadEndpoint returns an upload URL and token obtained with key.
+func (b *Bucket) uploadEndpoint(ctx context.Context, key *Key) (string, string, error) {
+	sub, err := NewClient(ctx, key.ID(), key.Secret(), endpointOptions(b.c.opts)...)
+	if err != nil {
+		return "", "", err
+	}
+	defer sub.Close()
+	sb, err := sub.Bucket(ctx, b.Name())
+	if err != nil {
+		return "", "", err
+	}
+	u, err := sb.b.getUploadURL(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	uri, token := u.endpoint()
+	return uri, token, nil
+}
+
+// Revoke deletes the application key behind the ticket, so that it can no
+// longer be used.  Only tickets issued by NewUploadTicket in this process can
+// be revoked; a ticket decoded from JSON lacks the key, which expires with the
+// ticket.
+func (t *UploadTicket) Revoke(ctx context.Context) error {
+	if t.key == nil {
+		return errors.New("b2: upload ticket was not issued by this client")
+	}
+	return t.key.Delete(ctx)
+}
+
+// VerifyUpload checks that the upload a ticket was issued for has been made,
+// and returns the uploaded object.  It fails if the ticket is for another
+// bucket, if there is no object of the ticket's name, if the object predates
+// the ticket, or if it is larger than maxSize bytes (when maxSize is
+// positive).  Callers should check the object's other attributes, such as its
+// content type, against what they expect.
+func (b *Bucket) VerifyUpload(ctx context.Context, t *UploadTicket, maxSize int64) (*Object, error) {
+	if t.Bucket != b.Name() {
+		return nil, fmt.Errorf("b2: ticket is for bucket %q, not %q", t.Bucket, b.Name())
+	}
+	obj := b.Object(t.Name)
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Allow for the clocks here and at B2 disagreeing.
+	if attrs.UploadTimestamp.Before(t.Issued.Add(-time.Minute)) {
+		return nil, fmt.Errorf("b2: %s was uploaded at %v, before the ticket was issued", t.Name, attrs.UploadTimestamp)
+	}
+	if maxSize > 0 && attrs.Size > maxSize {
+		return nil, fmt.Errorf("b2: %s is %d bytes, more than the %d allowed", t.Name, attrs.Size, maxSize)
+	}
+	return obj, nil
+}
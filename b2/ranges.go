@@ -0,0 +1,206 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+var errRangesClosed = errors.New("b2: RangesReader is closed")
+
+// A ByteRange is Length bytes of an object, starting at Offset.
+type ByteRange struct {
+	Offset int64
+	Length int64
+}
+
+// RangesReader reads a set of byte ranges from an object, such as the footer of
+// a Parquet or zip file and the segments it points to.  Ranges that overlap or
+// lie close together are fetched in a single request.  The ranges are fetched
+// in full on the first call to ReadAt, and held in memory.
+//
+// Changes to public RangesReader attributes must be made before the first call
+// to ReadAt.
+type RangesReader struct {
+	// MaxGap is the largest number of unwanted bytes that will be downloaded
+	// in order to fetch two ranges in one request.  The default is 64KB.  If
+	// negative, only overlapping or adjacent ranges are combined.
+	MaxGap int64
+
+	// ConcurrentDownloads is the number of requests to make at once.  Values
+	// less than 1 are equivalent to 1.
+	ConcurrentDownloads int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	o      *Object
+	ranges []ByteRange
+	init   sync.Once
+	spans  []*rspan
+	err    error
+}
+
+type rspan struct {
+	ByteRange
+	data []byte
+}
+
+// NewRangesReader returns a RangesReader for the given ranges of the object.
+// The order of the ranges does not matter.
+func (o *Object) NewRangesReader(ctx context.Context, ranges []ByteRange) *RangesReader {
+	ctx, cancel := context.WithCancel(ctx)
+	return &RangesReader{
+		ctx:    ctx,
+		cancel: cancel,
+		o:      o,
+		ranges: ranges,
+	}
+}
+
+// coalesce sorts ranges and merges those separated by no more than gap bytes.
+func coalesce(ranges []ByteRange, gap int64) []ByteRange {
+	rs := make([]ByteRange, 0, len(ranges))
+	for _, r := range ranges {
+		if r.Length > 0 {
+			rs = append(rs, r)
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].Offset < rs[j].Offset })
+	var out []ByteRange
+	for _, r := range rs {
+		if n := len(out); n > 0 {
+			last := &out[n-1]
+			end := last.Offset + last.Length
+			if r.Offset <= end+gap {
+				if e := r.Offset + r.Length; e > end {
+					last.Length = e - last.Offset
+				}
+				continue
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// Requests returns the ranges that will be (or have been) requested from B2.
+func (r *RangesReader) Requests() []ByteRange {
+	gap := r.MaxGap
+	switch {
+	case gap == 0:
+		gap = 64 << 10
+	case gap < 0:
+		gap = 0
+	}
+	return coalesce(r.ranges, gap)
+}
+
+func (r *RangesReader) initFunc() {
+	reqs := r.Requests()
+	cr := r.ConcurrentDownloads
+	if cr < 1 {
+		cr = 1
+	}
+	sem := make(chan struct{}, cr)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, br := range reqs {
+		s := &rspan{ByteRange: br}
+		r.spans = append(r.spans, s)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-r.ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+			if err := r.fetch(s); err != nil {
+				mu.Lock()
+				if r.err == nil {
+					r.err = err
+					r.cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if r.err == nil {
+		r.err = r.ctx.Err()
+	}
+}
+
+func (r *RangesReader) fetch(s *rspan) error {
+	fr, err := r.o.b.b.downloadFileByName(r.ctx, r.o.name, s.Offset, s.Length, false)
+	if err == errNoMoreContent {
+		// The whole span is past the end of the object.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+	buf := &bytes.Buffer{}
+	if _, err := copyContext(r.ctx, buf, fr); err != nil {
+		return err
+	}
+	s.data = buf.Bytes()
+	return nil
+}
+
+// ReadAt satisfies the io.ReaderAt interface, for offsets that lie within the
+// requested ranges.  Reading bytes that were not requested is an error.  As
+// with any io.ReaderAt, if fewer than len(p) bytes are read because the object
+// ends, ReadAt returns io.EOF.
+func (r *RangesReader) ReadAt(p []byte, off int64) (int, error) {
+	r.init.Do(r.initFunc)
+	if r.err != nil {
+		return 0, r.err
+	}
+	i := sort.Search(len(r.spans), func(i int) bool {
+		s := r.spans[i]
+		return s.Offset+s.Length > off
+	})
+	if i == len(r.spans) || r.spans[i].Offset > off || off+int64(len(p)) > r.spans[i].Offset+r.spans[i].Length {
+		return 0, fmt.Errorf("b2: bytes %d-%d of %s were not requested", off, off+int64(len(p)), r.o.name)
+	}
+	s := r.spans[i]
+	start := off - s.Offset
+	if start >= int64(len(s.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, s.data[start:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Close frees the downloaded data, and cancels any downloads in progress.
+func (r *RangesReader) Close() error {
+	r.cancel()
+	r.init.Do(func() {})
+	r.spans = nil
+	r.err = errRangesClosed
+	return nil
+}
@@ -16,12 +16,14 @@ package b2
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/sha1"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
+	"net/http"
 	"sync"
 	"time"
 
@@ -39,15 +41,36 @@ type Reader struct {
 	ConcurrentDownloads int
 
 	// ChunkSize is the size to fetch per ConcurrentDownload.  The default is
-	// 10MB.
+	// 10MB.  On high-latency links, larger chunks spend proportionally less
+	// time waiting on each request.
 	ChunkSize int
 
+	// MaxBufferedBytes, if positive, bounds the memory used to hold downloaded
+	// data that has not yet been read.  Each concurrent download buffers one
+	// chunk, so ConcurrentDownloads is reduced until ConcurrentDownloads *
+	// ChunkSize fits, and ChunkSize is reduced if a single chunk would not.
+	MaxBufferedBytes int
+
+	// ResumeAttempts is the number of times a chunk whose download is cut
+	// off partway will be resumed, by requesting the rest of the chunk from
+	// where the interrupted download left off.  The default is 10.  If
+	// negative, an interrupted download returns an error to the caller.
+	ResumeAttempts int
+
+	// Decompress, if true, transparently decompresses objects that were
+	// stored with a Content-Encoding of gzip, as some other tools upload
+	// them.  Objects with no encoding, or any other, are read as stored.  It
+	// has no effect on readers for a range of an object, since only whole
+	// gzip streams can be decompressed.
+	Decompress bool
+
 	ctx        context.Context
 	cancel     context.CancelFunc // cancels ctx
 	o          *Object
 	name       string
 	offset     int64 // the start of the file
-	length     int64 // the length to read, or -1
+	length     int64 // the length to read, or -1; decremented as chunks are fetched
+	whole      bool  // whether the whole object was requested; never changes
 	csize      int   // chunk size
 	read       int   // amount read
 	chwid      int   // chunks written
@@ -58,9 +81,15 @@ type Reader struct {
 	vrfy       hash.Hash
 	readOffEnd bool
 	sha1       string
+	encoding   string // the stored Content-Encoding; guarded by rmux
+
+	gzinit  sync.Once
+	gz      *gzip.Reader // if decompressing; set once, by initGzip
+	decoded int64        // bytes returned to the caller
 
-	rmux  sync.Mutex // guards rcond
+	rmux  sync.Mutex // guards rcond and hdr
 	rcond *sync.Cond
+	hdr   http.Header
 
 	emux sync.RWMutex // guards err, believe it or not
 	err  error
@@ -131,9 +160,13 @@ func (r *Reader) thread() {
 				r.length -= size
 			}
 			var b backoff
+			var resumes int
+			var fileID string
+			want := size
 		redo:
-			fr, err := r.o.b.b.downloadFileByName(r.ctx, r.name, offset, size, false)
-			if err == errNoMoreContent {
+			got := int64(buf.Len())
+			fr, err := r.o.b.b.downloadFileByName(r.ctx, r.name, offset+got, want-got, false)
+			if err == errNoMoreContent && got == 0 {
 				// this read generated a 416 so we are entirely past the end of the object
 				r.readOffEnd = true
 				buf.final = true
@@ -148,28 +181,59 @@ func (r *Reader) thread() {
 				r.rcond.Broadcast()
 				return
 			}
-			rsize, _, sha1, _ := fr.stats()
+			rsize, _, sha1, info := fr.stats()
+			if fileID == "" {
+				fileID = fr.id()
+			} else if fr.id() != fileID {
+				// The object was replaced between requests; the bytes we have
+				// can't be spliced onto the new version's.
+				fr.Close()
+				r.setErr(fmt.Errorf("b2 reader %d: %s changed while being downloaded", chunkID, r.name))
+				r.rcond.Broadcast()
+				return
+			}
+			if got == 0 {
+				want = int64(rsize)
+			}
 			if len(sha1) == 40 && r.sha1 != sha1 {
 				r.sha1 = sha1
 			}
-			mr := &meteredReader{r: noopResetter{fr}, size: int(rsize)}
+			r.rmux.Lock()
+			if r.hdr == nil {
+				r.hdr = fr.header()
+				r.encoding = r.hdr.Get("Content-Encoding")
+				if r.encoding == "" {
+					r.encoding = info[InfoContentEncoding]
+				}
+			}
+			r.rmux.Unlock()
+			mr := &meteredReader{read: got, r: noopResetter{fr}, size: int(want)}
 			r.smux.Lock()
 			r.smap[chunkID] = mr
 			r.smux.Unlock()
-			i, err := copyContext(r.ctx, buf, mr)
+			_, err = copyContext(r.ctx, buf, mr)
 			fr.Close()
 			r.smux.Lock()
 			r.smap[chunkID] = nil
 			r.smux.Unlock()
-			if i < int64(rsize) || err == io.ErrUnexpectedEOF {
-				// Probably the network connection was closed early.  Retry.
-				blog.V(1).Infof("b2 reader %d: got %dB of %dB; retrying after %v", chunkID, i, rsize, b)
-				if err := b.wait(r.ctx); err != nil {
+			if r.ctx.Err() == nil && (int64(buf.Len()) < want || err != nil) {
+				// Probably the network connection was closed early.  Pick up
+				// where it left off.
+				if err == nil {
+					err = io.ErrUnexpectedEOF
+				}
+				if resumes >= r.resumeAttempts() {
+					r.setErr(fmt.Errorf("b2 reader %d: got %dB of %dB: %v", chunkID, buf.Len(), want, err))
+					r.rcond.Broadcast()
+					return
+				}
+				resumes++
+				blog.V(1).Infof("b2 reader %d: got %dB of %dB (%v); resuming after %v", chunkID, buf.Len(), want, err, b)
+				if err := b.wait(r.ctx, r.o.b.c.opts.after); err != nil {
 					r.setErr(err)
 					r.rcond.Broadcast()
 					return
 				}
-				buf.Reset()
 				goto redo
 			}
 			if err != nil {
@@ -185,6 +249,16 @@ func (r *Reader) thread() {
 	}()
 }
 
+func (r *Reader) resumeAttempts() int {
+	switch {
+	case r.ResumeAttempts < 0:
+		return 0
+	case r.ResumeAttempts == 0:
+		return 10
+	}
+	return r.ResumeAttempts
+}
+
 func (r *Reader) curChunk() (*rchunk, error) {
 	ch := make(chan *rchunk)
 	go func() {
@@ -224,6 +298,14 @@ func (r *Reader) initFunc() {
 		r.ChunkSize = 1e7
 	}
 	r.csize = r.ChunkSize
+	if m := r.MaxBufferedBytes; m > 0 {
+		if r.csize > m {
+			r.csize = m
+		}
+		if cr > m/r.csize {
+			cr = m / r.csize
+		}
+	}
 	r.chbuf = make(chan *rchunk, cr)
 	for i := 0; i < cr; i++ {
 		r.thread()
@@ -232,7 +314,66 @@ func (r *Reader) initFunc() {
 	r.vrfy = sha1.New()
 }
 
+// Read satisfies the io.Reader interface.
 func (r *Reader) Read(p []byte) (int, error) {
+	r.gzinit.Do(r.initGzip)
+	if r.gz == nil {
+		n, err := r.readRaw(p)
+		r.decoded += int64(n)
+		return n, err
+	}
+	n, err := r.gz.Read(p)
+	r.decoded += int64(n)
+	return n, err
+}
+
+// initGzip decides, once, whether the Reader decompresses.  If it should and
+// the whole object was requested, it waits for the first chunk, and if the
+// object is gzipped, sets up the decompressor.
+func (r *Reader) initGzip() {
+	if !r.Decompress || !r.whole {
+		return
+	}
+	r.init.Do(r.initFunc)
+	if _, err := r.curChunk(); err != nil {
+		r.setErrNoCancel(err)
+		return
+	}
+	r.rmux.Lock()
+	enc := r.encoding
+	r.rmux.Unlock()
+	if enc != "gzip" {
+		return
+	}
+	gz, err := gzip.NewReader(rawReader{r})
+	if err != nil {
+		r.setErr(fmt.Errorf("b2: decompressing %s: %v", r.name, err))
+		return
+	}
+	r.gz = gz
+}
+
+// rawReader reads the stored bytes of an object, whether or not the Reader
+// is decompressing them.
+type rawReader struct{ r *Reader }
+
+func (rr rawReader) Read(p []byte) (int, error) { return rr.r.readRaw(p) }
+
+// Sizes reports the number of bytes read so far, both as stored in B2 and as
+// returned by Read.  They differ only when the Reader is decompressing the
+// object; once the whole object has been read, they are its compressed and
+// decompressed sizes.
+func (r *Reader) Sizes() (stored, decoded int64) {
+	return int64(r.read), r.decoded
+}
+
+// Decompressing reports whether the Reader is decompressing the object.  It is
+// only valid after the first call to Read.
+func (r *Reader) Decompressing() bool {
+	return r.gz != nil
+}
+
+func (r *Reader) readRaw(p []byte) (int, error) {
 	if err := r.getErr(); err != nil {
 		return 0, err
 	}
@@ -260,6 +401,16 @@ func (r *Reader) Read(p []byte) (int, error) {
 	return n, err
 }
 
+// Header returns the raw HTTP response headers from the first chunk that was
+// downloaded, or nil if nothing has yet been read.  Headers such as
+// Cache-Control, which are not otherwise exposed, can be found here; note that
+// Content-Length and Content-Range describe that chunk, not the whole object.
+func (r *Reader) Header() http.Header {
+	r.rmux.Lock()
+	defer r.rmux.Unlock()
+	return r.hdr
+}
+
 func (r *Reader) status() *ReaderStatus {
 	r.smux.Lock()
 	defer r.smux.Unlock()
@@ -328,12 +479,12 @@ func (noopResetter) Reset() error { return nil }
 
 type backoff time.Duration
 
-func (b *backoff) wait(ctx context.Context) error {
+func (b *backoff) wait(ctx context.Context, after func(time.Duration) <-chan time.Time) error {
 	if *b == 0 {
 		*b = backoff(time.Millisecond)
 	}
 	select {
-	case <-time.After(time.Duration(*b)):
+	case <-after(time.Duration(*b)):
 		if time.Duration(*b) < time.Second*10 {
 			*b <<= 1
 		}
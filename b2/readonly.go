@@ -0,0 +1,78 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnlyKey matches, with errors.Is, the errors returned by calls that
+// would change something the client's key is not allowed to change.  Such
+// calls fail before anything is sent to B2.
+var ErrReadOnlyKey = errors.New("b2: key is read-only")
+
+// A ReadOnlyKeyError is returned by a call that needs a capability the
+// client's key lacks.
+type ReadOnlyKeyError struct {
+	Capability Capability // The capability the call needs.
+}
+
+func (e *ReadOnlyKeyError) Error() string {
+	return fmt.Sprintf("b2: key lacks the %s capability", e.Capability)
+}
+
+// Is reports whether target is ErrReadOnlyKey.
+func (e *ReadOnlyKeyError) Is(target error) bool {
+	return target == ErrReadOnlyKey
+}
+
+// writeCapabilities are those that allow a key to change buckets or files.
+var writeCapabilities = []Capability{CapWriteBuckets, CapDeleteBuckets, CapWriteFiles, CapDeleteFiles}
+
+// ReadOnly reports whether the client's key lacks every capability that would
+// let it change buckets or files, so that a tool can, for instance, disable
+// its editing commands up front.  A key that can do some things but not others
+// is not read-only, but calls it cannot make still fail with ErrReadOnlyKey.
+func (c *Client) ReadOnly() bool {
+	caps := c.backend.capabilities()
+	if caps == nil {
+		return false
+	}
+	for _, w := range writeCapabilities {
+		if hasCapability(caps, w) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasCapability(caps []string, c Capability) bool {
+	for _, have := range caps {
+		if have == string(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// requireCapability returns a *ReadOnlyKeyError if the key is known to lack c.
+func requireCapability(ri beRootInterface, c Capability) error {
+	caps := ri.capabilities()
+	if caps == nil || hasCapability(caps, c) {
+		return nil
+	}
+	return &ReadOnlyKeyError{Capability: c}
+}
@@ -0,0 +1,108 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"context"
+	"strings"
+)
+
+// Usage summarizes the storage used by a set of objects.
+type Usage struct {
+	Objects int64 // The number of objects (or versions, with UsageAllVersions).
+	Bytes   int64 // Their total size.
+
+	// Prefixes breaks the totals down by top-level prefix, if UsageByPrefix
+	// was given.  Objects whose names do not contain the delimiter are
+	// counted under their own name.
+	Prefixes map[string]*Usage
+}
+
+func (u *Usage) add(size int64) {
+	u.Objects++
+	u.Bytes += size
+}
+
+// A UsageOption alters how Bucket.Usage counts objects.
+type UsageOption func(*usageOptions)
+
+type usageOptions struct {
+	delimiter   string
+	allVersions bool
+}
+
+// UsageByPrefix groups the totals by the part of each object's name that
+// follows the prefix, up to and including the first delimiter.  For example,
+// with a delimiter of "/", "logs/2018/a.txt" and "logs/2019/b.txt" under the
+// prefix "logs/" are counted under "logs/2018/" and "logs/2019/".
+func UsageByPrefix(delimiter string) UsageOption {
+	return func(o *usageOptions) {
+		o.delimiter = delimiter
+	}
+}
+
+// UsageAllVersions counts every stored version of every object, not just the
+// current ones.  B2 bills for all stored versions, so this reflects the
+// storage that is paid for.
+func UsageAllVersions() UsageOption {
+	return func(o *usageOptions) {
+		o.allVersions = true
+	}
+}
+
+// Usage totals the number and size of the objects in the bucket whose names
+// begin with prefix.  Objects are listed a page at a time, so memory use does
+// not grow with the size of the bucket (other than for UsageByPrefix groups).
+func (b *Bucket) Usage(ctx context.Context, prefix string, opts ...UsageOption) (*Usage, error) {
+	var uo usageOptions
+	for _, f := range opts {
+		f(&uo)
+	}
+	lopts := []ListOption{ListPrefix(prefix), ListPageSize(1000)}
+	if uo.allVersions {
+		lopts = append(lopts, ListHidden())
+	}
+	u := &Usage{}
+	if uo.delimiter != "" {
+		u.Prefixes = make(map[string]*Usage)
+	}
+	iter := b.List(ctx, lopts...)
+	for iter.Next() {
+		obj := iter.Object()
+		if obj.f.status() != "upload" {
+			continue // hide markers and the like take no space
+		}
+		size := obj.f.size()
+		u.add(size)
+		if u.Prefixes == nil {
+			continue
+		}
+		group := obj.name
+		rest := strings.TrimPrefix(obj.name, prefix)
+		if i := strings.Index(rest, uo.delimiter); i >= 0 {
+			group = prefix + rest[:i+len(uo.delimiter)]
+		}
+		g, ok := u.Prefixes[group]
+		if !ok {
+			g = &Usage{}
+			u.Prefixes[group] = g
+		}
+		g.add(size)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// A WalkFunc is called by Walk for each object.  If it returns an error, the
+// walk stops, unless WalkContinueOnError was given.
+type WalkFunc func(*Object, *Attrs) error
+
+type walkOptions struct {
+	workers  int
+	keepOn   bool
+	listOpts []ListOption
+}
+
+// A WalkOption alters the default behavior of Walk.
+type WalkOption func(*walkOptions)
+
+// WalkWorkers sets the number of objects that are processed at once.  The
+// default is 1.
+func WalkWorkers(n int) WalkOption {
+	return func(w *walkOptions) {
+		w.workers = n
+	}
+}
+
+// WalkContinueOnError makes Walk visit every object even if some calls fail.
+// The errors are then returned together, as by errors.Join.
+func WalkContinueOnError() WalkOption {
+	return func(w *walkOptions) {
+		w.keepOn = true
+	}
+}
+
+// WalkListOptions passes options to the underlying List, for instance
+// ListHidden.  ListPrefix is overridden by Walk's prefix argument.
+func WalkListOptions(opts ...ListOption) WalkOption {
+	return func(w *walkOptions) {
+		w.listOpts = append(w.listOpts, opts...)
+	}
+}
+
+// Walk lists every object whose name begins with prefix and calls fn with it
+// and its attributes, from as many goroutines as WalkWorkers allows.  Calls
+// are not made in any particular order.
+//
+// If fn returns an error, or ctx is cancelled, no further calls are begun and
+// Walk returns the first such error once the calls already under way have
+// returned.  The context given to fn's Object methods should therefore be the
+// one given to Walk.
+func (b *Bucket) Walk(ctx context.Context, prefix string, fn WalkFunc, opts ...WalkOption) error {
+	wo := &walkOptions{}
+	for _, opt := range opts {
+		opt(wo)
+	}
+	if wo.workers < 1 {
+		wo.workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var errs []error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+		if !wo.keepOn {
+			cancel()
+		}
+	}
+
+	sem := make(chan struct{}, wo.workers)
+	var wg sync.WaitGroup
+	iter := b.List(ctx, append(wo.listOpts, ListPrefix(prefix))...)
+	for iter.Next() {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		obj := iter.Object()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			attrs, err := obj.Attrs(ctx)
+			if err == nil {
+				err = fn(obj, attrs)
+			}
+			if err != nil {
+				fail(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) > 0 {
+		if wo.keepOn {
+			return errors.Join(errs...)
+		}
+		return errs[0]
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
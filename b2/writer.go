@@ -16,9 +16,14 @@ package b2
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -36,7 +41,8 @@ type Writer struct {
 	// ConcurrentUploads is number of different threads sending data concurrently
 	// to Backblaze for large files.  This can increase performance greatly, as
 	// each thread will hit a different endpoint.  However, there is a ChunkSize
-	// buffer for each thread.  Values less than 1 are equivalent to 1.
+	// buffer for each thread, unless the data is read from an io.ReadSeeker (see
+	// ReadFrom).  Values less than 1 are equivalent to 1.
 	ConcurrentUploads int
 
 	// Resume an upload.  If true, and the upload is a large file, and a file of
@@ -60,8 +66,9 @@ type Writer struct {
 	// blank, os.TempDir() is used.
 	FileBufferDir string
 
-	contentType string
-	info        map[string]string
+	contentType  string
+	info         map[string]string
+	cacheControl string
 
 	csize       int
 	ctx         context.Context
@@ -88,8 +95,36 @@ type Writer struct {
 	emux sync.RWMutex
 	err  error
 
-	smux sync.RWMutex
-	smap map[int]*meteredReader
+	smux    sync.RWMutex
+	smap    map[int]*meteredReader
+	parts   map[int]Part // chunks successfully uploaded; guarded by smux
+	pending map[int]bool // chunks sent but not yet uploaded; guarded by smux
+	pcond   *sync.Cond   // signals changes to pending or err
+
+	flushed    beFileInterface // the version uploaded by the last simple-file Flush
+	flushedLen int
+
+	failFast  bool
+	unread    func() int64 // bytes of a known source not yet chunked
+	upStart   time.Time    // when the first part was sent; guarded by smux
+	sentBytes int64        // bytes of parts sent; guarded by smux
+	doneBytes int64        // bytes of parts uploaded; guarded by smux
+
+	sha1Mode string // sha1AtEnd or sha1Unverified, for files sent in one piece
+
+	s3etag   bool
+	cmd5     hash.Hash      // md5 of the current chunk
+	md5s     map[int][]byte // md5 of each sent chunk, by part number
+	md5Known bool           // md5s were computed before the upload began
+
+	manifest  bool
+	msha256   hash.Hash // sha256 of everything written
+	msha1     hash.Hash // sha1 of everything written, while it fits in one chunk
+	mfst      *Manifest // computed before the upload began, if mfstKnown
+	mfstKnown bool
+
+	dedup     DedupStore
+	dedupSHA1 string // sha1 of a seekable source, computed before the upload
 }
 
 type chunk struct {
@@ -101,6 +136,7 @@ func (w *Writer) setErr(err error) {
 	if err == nil || err == io.EOF {
 		return
 	}
+	defer w.wakeFlush()
 	w.emux.Lock()
 	defer w.emux.Unlock()
 	if w.err != nil {
@@ -133,16 +169,37 @@ func (w *Writer) registerChunk(id int, r *meteredReader) {
 func (w *Writer) completeChunk(id int) {
 	w.smux.Lock()
 	w.smap[id] = nil
+	delete(w.pending, id)
+	w.pcond.Broadcast()
+	w.smux.Unlock()
+}
+
+func (w *Writer) setPending(id int, p bool) {
+	w.smux.Lock()
+	defer w.smux.Unlock()
+	if p {
+		w.pending[id] = true
+		return
+	}
+	delete(w.pending, id)
+}
+
+func (w *Writer) wakeFlush() {
+	if w.pcond == nil {
+		return
+	}
+	w.smux.Lock()
+	w.pcond.Broadcast()
 	w.smux.Unlock()
 }
 
 var gid int32
 
-func sleepCtx(ctx context.Context, d time.Duration) error {
+func sleepCtx(ctx context.Context, after <-chan time.Time) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case <-time.After(d):
+	case <-after:
 		return nil
 	}
 }
@@ -165,10 +222,16 @@ func (w *Writer) thread() {
 				return
 			}
 			if sha, ok := w.seen[cnk.id]; ok {
-				if sha != cnk.buf.Hash() {
+				size, got, err := dataSum(cnk.buf)
+				if err != nil {
+					w.setErr(err)
+					return
+				}
+				if sha != got {
 					w.setErr(errors.New("resumable upload was requested, but chunks don't match"))
 					return
 				}
+				w.addPart(Part{Number: cnk.id, Size: size, SHA1: got})
 				cnk.buf.Close()
 				w.completeChunk(cnk.id)
 				blog.V(2).Infof("skipping chunk %d", cnk.id)
@@ -187,7 +250,7 @@ func (w *Writer) thread() {
 			n, err := fc.uploadPart(w.ctx, mr, cnk.buf.Hash(), cnk.buf.Len(), cnk.id)
 			if n != cnk.buf.Len() || err != nil {
 				if w.o.b.r.reupload(err) {
-					if err := sleepCtx(w.ctx, sleep); err != nil {
+					if err := sleepCtx(w.ctx, w.o.b.c.opts.after(sleep)); err != nil {
 						w.setErr(err)
 						w.completeChunk(cnk.id)
 						cnk.buf.Close() // TODO: log error
@@ -212,6 +275,7 @@ func (w *Writer) thread() {
 				cnk.buf.Close() // TODO: log error
 				return
 			}
+			w.recordPart(cnk.id, cnk.buf)
 			w.completeChunk(cnk.id)
 			cnk.buf.Close() // TODO: log error
 			blog.V(2).Infof("chunk %d handled", cnk.id)
@@ -219,17 +283,64 @@ func (w *Writer) thread() {
 	}()
 }
 
+// recordPart notes the size and SHA-1 that B2 holds for an uploaded chunk.  It
+// must be called before buf is closed.
+func (w *Writer) recordPart(id int, buf writeBuffer) {
+	p := Part{Number: id, Size: int64(buf.Len()), SHA1: buf.Hash()}
+	if th, ok := buf.(trailingHasher); ok {
+		// The hash is sent after the data.
+		p.Size = int64(th.dataLen())
+		p.SHA1 = th.trailingHash()
+	}
+	w.addPart(p)
+}
+
+func (w *Writer) addPart(p Part) {
+	w.smux.Lock()
+	w.parts[p.Number] = p
+	w.doneBytes += p.Size
+	w.smux.Unlock()
+}
+
+// Parts returns the size and SHA-1 of each part of a large file, in order, so
+// that the upload can be audited piece by piece.  It is only valid after Close
+// returns without error, and returns nil if the object was not uploaded as a
+// large file.
+func (w *Writer) Parts() []Part {
+	if !w.everStarted || w.cidx == 0 {
+		return nil
+	}
+	w.smux.RLock()
+	defer w.smux.RUnlock()
+	var parts []Part
+	for i := 1; i <= len(w.parts); i++ {
+		parts = append(parts, w.parts[i])
+	}
+	return parts
+}
+
 func (w *Writer) init() {
 	w.start.Do(func() {
 		w.everStarted = true
 		w.smux.Lock()
 		w.smap = make(map[int]*meteredReader)
+		w.parts = make(map[int]Part)
+		w.pending = make(map[int]bool)
+		w.pcond = sync.NewCond(&w.smux)
 		w.smux.Unlock()
 		w.o.b.c.addWriter(w)
 		w.csize = w.ChunkSize
 		if w.csize == 0 {
 			w.csize = 1e8
 		}
+		if w.s3etag {
+			w.cmd5 = md5.New()
+			w.md5s = make(map[int][]byte)
+		}
+		if w.manifest {
+			w.msha256 = sha256.New()
+			w.msha1 = sha1.New()
+		}
 		if w.newBuffer == nil {
 			w.newBuffer = func() (writeBuffer, error) { return newMemoryBuffer(), nil }
 			if w.UseFileBuffer {
@@ -242,9 +353,25 @@ func (w *Writer) init() {
 			return
 		}
 		w.w = v
+		if err := w.validate(); err != nil {
+			// Not setErr: there is no large file to cancel.
+			w.emux.Lock()
+			w.err = err
+			w.emux.Unlock()
+			w.cancel()
+		}
 	})
 }
 
+// validate checks the object's name and info, so that nothing is sent if B2
+// would refuse it.
+func (w *Writer) validate() error {
+	if err := ValidateName(w.name); err != nil {
+		return err
+	}
+	return ValidateInfo(w.name, w.info)
+}
+
 // Write satisfies the io.Writer interface.
 func (w *Writer) Write(p []byte) (int, error) {
 	if len(p) == 0 {
@@ -256,9 +383,9 @@ func (w *Writer) Write(p []byte) (int, error) {
 	}
 	left := w.csize - w.w.Len()
 	if len(p) < left {
-		return w.w.Write(p)
+		return w.bufWrite(p)
 	}
-	i, err := w.w.Write(p[:left])
+	i, err := w.bufWrite(p[:left])
 	if err != nil {
 		w.setErr(err)
 		return i, err
@@ -274,41 +401,81 @@ func (w *Writer) Write(p []byte) (int, error) {
 	return i + k, err
 }
 
+func (w *Writer) bufWrite(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if w.cmd5 != nil {
+		w.cmd5.Write(p[:n])
+	}
+	if w.msha256 != nil && !w.mfstKnown {
+		w.msha256.Write(p[:n])
+		if w.cidx == 0 {
+			w.msha1.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
 func (w *Writer) getUploadURL(ctx context.Context) (beURLInterface, error) {
 	u := w.o.b.urlPool.get()
 	if u == nil {
-		return w.o.b.b.getUploadURL(w.ctx)
+		return w.o.b.b.getUploadURL(ctx)
 	}
 
 	return u, nil
 }
 
-func (w *Writer) simpleWriteFile() error {
-	ue, err := w.getUploadURL(w.ctx)
+func (w *Writer) simpleWriteFile(ctx context.Context) error {
+	buf := w.w
+	sum := buf.Hash()
+	if len(sum) != 40 {
+		sum = w.dedupSHA1 // computed by ReadFrom, if at all
+	}
+	switch w.sha1Mode {
+	case sha1AtEnd:
+		if _, ok := buf.(trailingHasher); !ok {
+			buf = newTrailerBuffer(buf)
+		}
+	case sha1Unverified:
+		buf = unverifiedBuffer{buf}
+	}
+	sha1 := buf.Hash()
+	ctype := w.contentType
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	info := w.info
+	if w.s3etag {
+		info = w.infoWithETag(fmt.Sprintf("%x", w.chunkMD5(1)))
+	}
+	if w.manifest {
+		if !w.mfstKnown {
+			w.mfst = w.simpleManifest()
+		}
+		info = withInfo(info, manifestKey, w.mfst.encode())
+	}
+	if w.dedup != nil && sum != "" && w.dedupCopy(ctx, sum, ctype, info) {
+		return nil
+	}
+	ue, err := w.getUploadURL(ctx)
 	if err != nil {
 		return err
 	}
 	// This defer needs to be in a func() so that we put whatever the value of ue
 	// is at function exit.
 	defer func() { w.o.b.urlPool.put(ue) }()
-	sha1 := w.w.Hash()
-	ctype := w.contentType
-	if ctype == "" {
-		ctype = "application/octet-stream"
-	}
-	r, err := w.w.Reader()
+	r, err := buf.Reader()
 	if err != nil {
 		return err
 	}
-	mr := &meteredReader{r: r, size: w.w.Len()}
+	mr := &meteredReader{r: r, size: buf.Len()}
 	w.registerChunk(1, mr)
 	defer w.completeChunk(1)
 redo:
-	f, err := ue.uploadFile(w.ctx, mr, int(w.w.Len()), w.name, ctype, sha1, w.info)
+	f, err := ue.uploadFile(ctx, mr, buf.Len(), w.name, ctype, sha1, info)
 	if err != nil {
 		if w.o.b.r.reupload(err) {
 			blog.V(2).Infof("b2 writer: %v; retrying", err)
-			u, err := w.o.b.b.getUploadURL(w.ctx)
+			u, err := w.o.b.b.getUploadURL(ctx)
 			if err != nil {
 				return err
 			}
@@ -318,6 +485,9 @@ redo:
 		return err
 	}
 	w.o.f = f
+	if w.dedup != nil && sum != "" {
+		w.dedupPut(sum)
+	}
 	return nil
 }
 
@@ -327,7 +497,14 @@ func (w *Writer) getLargeFile() (beLargeFileInterface, error) {
 		if ctype == "" {
 			ctype = "application/octet-stream"
 		}
-		return w.o.b.b.startLargeFile(w.ctx, w.name, ctype, w.info)
+		info := w.info
+		if w.md5Known {
+			info = w.infoWithETag(w.S3ETag())
+		}
+		if w.mfstKnown {
+			info = withInfo(info, manifestKey, w.mfst.encode())
+		}
+		return w.o.b.b.startLargeFile(w.ctx, w.name, ctype, info)
 	}
 	var got bool
 	iter := w.o.b.List(w.ctx, ListPrefix(w.name), ListUnfinished())
@@ -395,14 +572,34 @@ func (w *Writer) sendChunk() error {
 	if err != nil {
 		return err
 	}
+	if err := w.checkDeadline(); err != nil {
+		w.setErr(err)
+		return err
+	}
+	id := w.cidx + 1
+	w.smux.Lock()
+	if w.upStart.IsZero() {
+		w.upStart = time.Now()
+	}
+	w.sentBytes += int64(w.w.Len())
+	w.smux.Unlock()
+	if w.cmd5 != nil && !w.md5Known {
+		w.smux.Lock()
+		w.md5s[id] = w.cmd5.Sum(nil)
+		w.smux.Unlock()
+		w.cmd5.Reset()
+	}
+	w.setPending(id, true)
 	select {
 	case <-w.cdone:
+		w.setPending(id, false)
 		return nil
 	case w.ready <- chunk{
-		id:  w.cidx + 1,
+		id:  id,
 		buf: w.w,
 	}:
 	case <-w.ctx.Done():
+		w.setPending(id, false)
 		return w.ctx.Err()
 	}
 	w.cidx++
@@ -416,19 +613,20 @@ func (w *Writer) sendChunk() error {
 
 // ReadFrom reads all of r into w, returning the first error or no error if r
 // returns io.EOF.  If r is also an io.Seeker, ReadFrom will stream r directly
-// over the wire instead of buffering it locally.  This reduces memory usage.
+// over the wire instead of buffering it locally.  This reduces memory usage:
+// no part is held in memory, even while it is being sent, and a part that
+// fails is sent again by reading it from r once more.  The same goes for
+// w.Resume, where the parts already uploaded are checked by reading them from
+// r rather than by buffering them.
 //
 // Do not issue multiple calls to ReadFrom, or mix ReadFrom and Write.  If you
 // have multiple readers you want to concatenate into the same B2 object, use
 // an io.MultiReader.
 //
 // Note that io.Copy will automatically choose to use ReadFrom.
-//
-// ReadFrom currently doesn't handle w.Resume; if w.Resume is true, ReadFrom
-// will act as if r is not an io.Seeker.
 func (w *Writer) ReadFrom(r io.Reader) (int64, error) {
 	rs, ok := r.(io.ReadSeeker)
-	if !ok || w.Resume {
+	if !ok {
 		return copyContext(w.ctx, w, r)
 	}
 	blog.V(2).Info("streaming without buffer")
@@ -444,6 +642,7 @@ func (w *Writer) ReadFrom(r io.Reader) (int64, error) {
 	}
 	var offset int64
 	var wrote int64
+	w.unread = func() int64 { return size - offset }
 	w.newBuffer = func() (writeBuffer, error) {
 		left := size - offset
 		if left <= 0 {
@@ -463,6 +662,29 @@ func (w *Writer) ReadFrom(r io.Reader) (int64, error) {
 		return nb, nil
 	}
 	w.init()
+	if err := w.getErr(); err != nil {
+		return 0, err
+	}
+	if w.s3etag {
+		if err := w.sumParts(ra, size); err != nil {
+			return 0, err
+		}
+	}
+	if w.manifest {
+		m, err := sumManifest(w.ctx, io.NewSectionReader(ra, 0, size), int64(w.csize))
+		if err != nil {
+			return 0, err
+		}
+		w.mfst = m
+		w.mfstKnown = true
+	}
+	if w.dedup != nil && size < int64(w.csize) {
+		h := sha1.New()
+		if _, err := copyContext(w.ctx, h, io.NewSectionReader(ra, 0, size)); err != nil {
+			return 0, err
+		}
+		w.dedupSHA1 = fmt.Sprintf("%x", h.Sum(nil))
+	}
 	if size < int64(w.csize) {
 		// the magic happens on w.Close()
 		return size, nil
@@ -483,7 +705,9 @@ func (w *Writer) Close() error {
 	w.done.Do(func() {
 		if !w.everStarted {
 			w.init()
-			w.setErr(w.simpleWriteFile())
+			if w.getErr() == nil {
+				w.setErr(w.simpleWriteFile(w.ctx))
+			}
 			return
 		}
 		defer w.o.b.c.removeWriter(w)
@@ -494,7 +718,17 @@ func (w *Writer) Close() error {
 			}
 		}()
 		if w.cidx == 0 {
-			w.setErr(w.simpleWriteFile())
+			if w.getErr() != nil {
+				// Don't upload what may be incomplete.
+				return
+			}
+			if w.flushed != nil && w.w.Len() == w.flushedLen {
+				// Nothing has been written since the last Flush.
+				w.o.f = w.flushed
+				return
+			}
+			w.setErr(w.simpleWriteFile(w.ctx))
+			w.dropFlushed()
 			return
 		}
 		if w.w.Len() > 0 {
@@ -513,10 +747,306 @@ func (w *Writer) Close() error {
 			return
 		}
 		w.o.f = f
+		w.dropFlushed()
 	})
 	return w.getErr()
 }
 
+// minPartSize is the smallest part, other than the last, that B2 will accept.
+const minPartSize = 5e6
+
+// Flush commits the data written so far to B2, so that long-lived writers
+// (e.g. log shippers) can bound how much data is lost if the process exits
+// without calling Close.  B2 has no append operation, so how this is done
+// depends on how much has been written.
+//
+// If the writer has not yet switched to the large file API, Flush uploads
+// everything written so far as a new version of the object.  Subsequent calls
+// to Flush or Close upload a replacement and delete the previously flushed
+// version.  Since each Flush re-sends the whole object, callers should set
+// ChunkSize with this in mind.
+//
+// Otherwise, Flush sends the buffered data as a new part if it is at least
+// 5MB, and then waits for all outstanding parts to be uploaded.  Less than 5MB
+// remains buffered, since B2 does not allow small parts except at the end of
+// a file.  Parts of an unfinished large file can be recovered with Resume.
+//
+// Canceling ctx abandons the Flush but not the writer, which can still be
+// flushed or closed.  Flush must not be called concurrently with Write,
+// ReadFrom, or Close.
+func (w *Writer) Flush(ctx context.Context) error {
+	if !w.everStarted {
+		return nil
+	}
+	if err := w.getErr(); err != nil {
+		return err
+	}
+	if w.cidx == 0 {
+		if w.w.Len() == 0 || (w.flushed != nil && w.w.Len() == w.flushedLen) {
+			return nil
+		}
+		prev := w.flushed
+		if err := w.flushSimple(ctx); err != nil {
+			return err
+		}
+		w.flushed = w.o.f
+		w.flushedLen = w.w.Len()
+		if prev != nil && prev.id() != w.flushed.id() {
+			if err := prev.deleteFileVersion(ctx); err != nil {
+				blog.V(1).Infof("flush %s: couldn't remove previous version: %v", w.name, err)
+			}
+		}
+		return nil
+	}
+	if w.w.Len() >= minPartSize {
+		if err := w.sendChunk(); err != nil {
+			w.setErr(err)
+			return w.getErr()
+		}
+	}
+	return w.waitForChunks(ctx)
+}
+
+// flushSimple uploads the buffer as a new version of the object, for Flush.
+// The upload is canceled if either ctx or the writer's context is done; a
+// failure is fatal to the writer only if ctx was not canceled.
+func (w *Writer) flushSimple(ctx context.Context) error {
+	uctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.ctx.Done():
+			cancel()
+		case <-uctx.Done():
+		}
+	}()
+	if err := w.simpleWriteFile(uctx); err != nil {
+		if ctx.Err() != nil && w.ctx.Err() == nil {
+			return ctx.Err()
+		}
+		w.setErr(err)
+		return w.getErr()
+	}
+	return nil
+}
+
+func (w *Writer) waitForChunks(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		w.wakeFlush()
+	}()
+	w.smux.Lock()
+	for len(w.pending) > 0 && w.getErr() == nil && ctx.Err() == nil && w.ctx.Err() == nil {
+		w.pcond.Wait()
+	}
+	w.smux.Unlock()
+	if err := w.getErr(); err != nil {
+		return err
+	}
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// dropFlushed removes the version left behind by Flush, once the object has
+// been successfully replaced.
+func (w *Writer) dropFlushed() {
+	if w.flushed == nil || w.getErr() != nil {
+		return
+	}
+	if w.o.f != nil && w.o.f.id() == w.flushed.id() {
+		return
+	}
+	if err := w.flushed.deleteFileVersion(w.ctx); err != nil {
+		blog.V(1).Infof("close %s: couldn't remove flushed version: %v", w.name, err)
+	}
+	w.flushed = nil
+}
+
+// An UploadDeadlineError is returned by a Writer created with FailFastOnDeadline
+// when, at the throughput observed so far, the upload cannot finish before its
+// context's deadline.
+type UploadDeadlineError struct {
+	Remaining int64         // Bytes not yet uploaded.
+	Estimate  time.Duration // Estimated time to upload them.
+	Deadline  time.Time     // The context's deadline.
+}
+
+func (e *UploadDeadlineError) Error() string {
+	return fmt.Sprintf("upload won't finish: %d bytes left would take %v, but the deadline is in %v", e.Remaining, e.Estimate, time.Until(e.Deadline).Round(time.Second))
+}
+
+// FailFastOnDeadline requests that, if the writer's context has a deadline, a
+// large file upload be abandoned with an *UploadDeadlineError as soon as it
+// becomes clear that it cannot finish in time, rather than using bandwidth on
+// an upload that will be canceled anyway.  The estimate is based on the
+// throughput of the parts uploaded so far, and the data known to remain: what
+// has been written but not uploaded and, when the writer is given an
+// io.ReadSeeker with io.Copy (or ReadFrom), the rest of the source.
+//
+// Combine with WithCancelOnError to also cancel the unfinished large file.
+func FailFastOnDeadline() WriterOption {
+	return func(w *Writer) {
+		w.failFast = true
+	}
+}
+
+func (w *Writer) checkDeadline() error {
+	if !w.failFast {
+		return nil
+	}
+	deadline, ok := w.ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	w.smux.RLock()
+	start, sent, done := w.upStart, w.sentBytes, w.doneBytes
+	w.smux.RUnlock()
+	if done == 0 {
+		return nil // nothing to go on yet
+	}
+	rate := float64(done) / time.Since(start).Seconds() // bytes per second
+	left := sent - done + int64(w.w.Len())
+	if w.unread != nil {
+		left += w.unread()
+	}
+	est := time.Duration(float64(left) / rate * float64(time.Second))
+	if time.Now().Add(est).Before(deadline) {
+		return nil
+	}
+	blog.V(1).Infof("%s: %d bytes left at %.0fB/s won't finish by %v", w.name, left, rate, deadline)
+	return &UploadDeadlineError{
+		Remaining: left,
+		Estimate:  est,
+		Deadline:  deadline,
+	}
+}
+
+// WithTrailingSHA1 sends the SHA-1 of an object uploaded in one piece after
+// its data, instead of in the request headers, and B2 still verifies it.
+//
+// This does not let data of unknown length be streamed.  B2 must be told the
+// length of an upload before it begins, so data given to Write is buffered in
+// full either way, and for it the option only moves the checksum: it is
+// computed as the data is read back from the buffer, and so also covers the
+// trip through a file buffer.  Data given to ReadFrom from an io.ReadSeeker is
+// not buffered, and is always sent with a trailing SHA-1.  Parts of large
+// files are unaffected.
+func WithTrailingSHA1() WriterOption {
+	return func(w *Writer) {
+		w.sha1Mode = sha1AtEnd
+	}
+}
+
+// WithoutSHA1 uploads an object in one piece without any checksum.  B2 will
+// not verify the data and will not record a SHA-1 for the object, so
+// Reader.Verify cannot check it later; this should only be used when the
+// data's integrity is checked some other way.  Parts of large files always
+// carry a checksum, since B2 requires one to finish the file.
+func WithoutSHA1() WriterOption {
+	return func(w *Writer) {
+		w.sha1Mode = sha1Unverified
+	}
+}
+
+// s3ETagKey is the file info key under which WithS3ETag stores the ETag.
+const s3ETagKey = "s3_etag"
+
+// WithS3ETag requests that the writer compute the ETag that Amazon S3 would
+// report for the object, so that S3-compatible tools can check its integrity.
+// For objects uploaded in one piece this is the hex MD5 of the data; for large
+// files it is the MD5 of the concatenated part MD5s, followed by "-" and the
+// number of parts.
+//
+// The ETag is saved in the object's info under "s3_etag", provided there is
+// room.  B2 fixes the info of a large file when the upload begins, however, so
+// for large files the ETag can only be saved if the data is known beforehand,
+// as it is when the writer is given an io.ReadSeeker with io.Copy (or
+// ReadFrom); the source is then read twice.  In all cases the ETag is
+// available from S3ETag once the writer has been closed.
+func WithS3ETag() WriterOption {
+	return func(w *Writer) {
+		w.s3etag = true
+	}
+}
+
+// S3ETag returns the S3-style ETag of the data written, if WithS3ETag was
+// given.  It is only valid after Close returns without error.
+func (w *Writer) S3ETag() string {
+	if !w.s3etag || !w.everStarted {
+		return ""
+	}
+	w.smux.RLock()
+	n := len(w.md5s)
+	w.smux.RUnlock()
+	if w.cidx == 0 && n <= 1 {
+		return fmt.Sprintf("%x", w.chunkMD5(1))
+	}
+	h := md5.New()
+	for i := 1; i <= n; i++ {
+		h.Write(w.chunkMD5(i))
+	}
+	return fmt.Sprintf("%x-%d", h.Sum(nil), n)
+}
+
+// chunkMD5 returns the md5 of the given part, which for the current chunk is
+// the data written so far.
+func (w *Writer) chunkMD5(id int) []byte {
+	w.smux.RLock()
+	sum, ok := w.md5s[id]
+	w.smux.RUnlock()
+	if ok || w.cmd5 == nil {
+		return sum
+	}
+	return w.cmd5.Sum(nil)
+}
+
+// sumParts computes the md5 of every part of size bytes of ra, so that the ETag
+// is known before the upload begins.
+func (w *Writer) sumParts(ra io.ReaderAt, size int64) error {
+	md5s := make(map[int][]byte)
+	id := 1
+	for off := int64(0); off < size || off == 0; off += int64(w.csize) {
+		n := size - off
+		if n > int64(w.csize) {
+			n = int64(w.csize)
+		}
+		h := md5.New()
+		if _, err := io.Copy(h, io.NewSectionReader(ra, off, n)); err != nil {
+			return err
+		}
+		md5s[id] = h.Sum(nil)
+		id++
+		if n == 0 {
+			break
+		}
+	}
+	w.smux.Lock()
+	w.md5s = md5s
+	w.smux.Unlock()
+	w.md5Known = true
+	return nil
+}
+
+func (w *Writer) infoWithETag(etag string) map[string]string {
+	return withInfo(w.info, s3ETagKey, etag)
+}
+
+// withInfo returns a copy of info with k set to v, if there is room.
+func withInfo(info map[string]string, k, v string) map[string]string {
+	out := make(map[string]string)
+	for k, v := range info {
+		out[k] = v
+	}
+	if len(out) < 10 {
+		out[k] = v
+	}
+	return out
+}
+
 func (w *Writer) withAttrs(attrs *Attrs) *Writer {
 	w.contentType = attrs.ContentType
 	w.info = make(map[string]string)
@@ -524,14 +1054,39 @@ func (w *Writer) withAttrs(attrs *Attrs) *Writer {
 		w.info[k] = v
 	}
 	if len(w.info) < 10 && attrs.SHA1 != "" {
-		w.info["large_file_sha1"] = attrs.SHA1
+		w.info[infoLargeFileSHA1] = attrs.SHA1
 	}
 	if len(w.info) < 10 && !attrs.LastModified.IsZero() {
-		w.info["src_last_modified_millis"] = fmt.Sprintf("%d", attrs.LastModified.UnixNano()/1e6)
+		w.info[infoLastModified] = fmt.Sprintf("%d", attrs.LastModified.UnixNano()/1e6)
 	}
 	return w
 }
 
+// withDefaults fills in any attributes the writer has not been given from the
+// bucket's defaults.
+func (w *Writer) withDefaults(attrs *Attrs) {
+	if attrs == nil {
+		return
+	}
+	if w.contentType == "" {
+		w.contentType = attrs.ContentType
+	}
+	keys := make([]string, 0, len(attrs.Info))
+	for k := range attrs.Info {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, ok := w.info[k]; ok || len(w.info) >= 10 {
+			continue
+		}
+		if w.info == nil {
+			w.info = make(map[string]string)
+		}
+		w.info[k] = attrs.Info[k]
+	}
+}
+
 // A WriterOption sets Writer-specific behavior.
 type WriterOption func(*Writer)
 
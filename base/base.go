@@ -31,6 +31,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -184,6 +185,16 @@ func Backoff(err error) time.Duration {
 	return time.Duration(e.retry) * time.Second
 }
 
+// Method returns the name of the B2 API call that returned err, if it was
+// returned by the server, or else "".
+func Method(err error) string {
+	e, ok := err.(b2err)
+	if !ok {
+		return ""
+	}
+	return e.method
+}
+
 func logRequest(req *http.Request, args []byte) {
 	if !blog.V(2) {
 		return
@@ -230,12 +241,55 @@ func millitime(t int64) time.Time {
 }
 
 type b2Options struct {
-	transport       http.RoundTripper
-	failSomeUploads bool
-	expireTokens    bool
-	capExceeded     bool
-	apiBase         string
-	userAgent       string
+	transport         http.RoundTripper
+	downloadTransport http.RoundTripper
+	failSomeUploads   bool
+	expireTokens      bool
+	capExceeded       bool
+	apiBase           string
+	apiURL            string
+	downloadURL       string
+	userAgent         string
+	clock             serverClock
+}
+
+// serverClock estimates how far B2's clock is ahead of the local one, from
+// the Date headers of its responses.
+type serverClock struct {
+	local func() time.Time // if nil, time.Now
+
+	mu   sync.Mutex
+	skew time.Duration
+}
+
+func (c *serverClock) localNow() time.Time {
+	if c.local != nil {
+		return c.local()
+	}
+	return time.Now()
+}
+
+func (c *serverClock) observe(resp *http.Response) {
+	d, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// Date is truncated to the second, so B2's clock is, on average, half a
+	// second ahead of it.
+	skew := d.Add(500 * time.Millisecond).Sub(c.localNow())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.skew = skew
+}
+
+func (c *serverClock) offset() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.skew
+}
+
+func (c *serverClock) now() time.Time {
+	return c.localNow().Add(c.offset())
 }
 
 func (o *b2Options) addHeaders(req *http.Request) {
@@ -272,6 +326,13 @@ func (o *b2Options) getTransport() http.RoundTripper {
 	return o.transport
 }
 
+func (o *b2Options) getDownloadTransport() http.RoundTripper {
+	if o.downloadTransport == nil {
+		return o.getTransport()
+	}
+	return o.downloadTransport
+}
+
 // B2 holds account information for Backblaze.
 type B2 struct {
 	accountID   string
@@ -280,8 +341,10 @@ type B2 struct {
 	downloadURI string
 	minPartSize int
 	opts        *b2Options
-	bucket      string // restricted to this bucket if present
-	pfx         string // restricted to objects with this prefix if present
+	bucket      string    // restricted to this bucket if present
+	pfx         string    // restricted to objects with this prefix if present
+	caps        []string  // the capabilities of the key
+	authTime    time.Time // when the token was issued, by B2's clock
 }
 
 // Update replaces the B2 object with a new one, in-place.
@@ -292,6 +355,35 @@ func (b *B2) Update(n *B2) {
 	b.downloadURI = n.downloadURI
 	b.minPartSize = n.minPartSize
 	b.opts = n.opts
+	b.caps = n.caps
+	b.authTime = n.authTime
+}
+
+// Capabilities returns the capabilities granted to the key with which the
+// account was authorized.
+func (b *B2) Capabilities() []string {
+	return b.caps
+}
+
+// AuthTokenLifetime is how long B2 honors the token returned by
+// b2_authorize_account.
+const AuthTokenLifetime = 24 * time.Hour
+
+// ClockSkew returns how far B2's clock is estimated to be ahead of the local
+// clock (negative if it is behind), as of the most recent response.
+func (b *B2) ClockSkew() time.Duration {
+	return b.opts.clock.offset()
+}
+
+// TokenExpiresWithin reports whether the account authorization token will
+// expire within d.  Its age is measured by B2's clock rather than the local
+// one, so that a host whose clock has drifted does not use the token after
+// B2 has stopped accepting it.
+func (b *B2) TokenExpiresWithin(d time.Duration) bool {
+	if b.authTime.IsZero() {
+		return false
+	}
+	return b.opts.clock.now().Add(d).After(b.authTime.Add(AuthTokenLifetime))
 }
 
 type httpReply struct {
@@ -410,15 +502,24 @@ func (o *b2Options) makeRequest(ctx context.Context, method, verb, uri string, b
 		return err
 	}
 	defer resp.Body.Close()
+	o.clock.observe(resp)
 	if resp.StatusCode != 200 {
 		return mkErr(resp)
 	}
 	var replyArgs []byte
 	if b2resp != nil {
+		// Keep a copy of the reply only if it is going to be logged.
+		var r io.Reader = resp.Body
 		rbuf := &bytes.Buffer{}
-		r := io.TeeReader(resp.Body, rbuf)
-		decoder := json.NewDecoder(r)
-		if err := decoder.Decode(b2resp); err != nil {
+		if blog.V(2) {
+			r = io.TeeReader(resp.Body, rbuf)
+		}
+		if d, ok := b2resp.(responseDecoder); ok {
+			err = d.decodeResponse(r)
+		} else {
+			err = json.NewDecoder(r).Decode(b2resp)
+		}
+		if err != nil {
 			return err
 		}
 		replyArgs = rbuf.Bytes()
@@ -447,15 +548,25 @@ func AuthorizeAccount(ctx context.Context, account, key string, opts ...AuthOpti
 	if err := b2opts.makeRequest(ctx, "b2_authorize_account", "GET", b2opts.getAPIBase()+b2types.V1api+"b2_authorize_account", nil, b2resp, headers, nil); err != nil {
 		return nil, err
 	}
+	apiURI := b2resp.URI
+	if b2opts.apiURL != "" {
+		apiURI = b2opts.apiURL
+	}
+	downloadURI := b2resp.DownloadURI
+	if b2opts.downloadURL != "" {
+		downloadURI = b2opts.downloadURL
+	}
 	return &B2{
 		accountID:   b2resp.AccountID,
 		authToken:   b2resp.AuthToken,
-		apiURI:      b2resp.URI,
-		downloadURI: b2resp.DownloadURI,
+		apiURI:      apiURI,
+		downloadURI: downloadURI,
 		minPartSize: b2resp.PartSize,
 		bucket:      b2resp.Allowed.Bucket,
 		pfx:         b2resp.Allowed.Prefix,
+		caps:        b2resp.Allowed.Capabilities,
 		opts:        b2opts,
+		authTime:    b2opts.clock.now(),
 	}, nil
 }
 
@@ -514,10 +625,71 @@ func SetAPIBase(url string) AuthOption {
 	}
 }
 
+// SetAPIURL returns an AuthOption that overrides the API URL returned by
+// b2_authorize_account.  All API calls after authorization are sent here.
+func SetAPIURL(url string) AuthOption {
+	return func(o *b2Options) {
+		o.apiURL = url
+	}
+}
+
+// LocalClock returns an AuthOption that reads the local time from now instead
+// of time.Now, for testing token expiry.
+func LocalClock(now func() time.Time) AuthOption {
+	return func(o *b2Options) {
+		o.clock.local = now
+	}
+}
+
+// SetDownloadURL returns an AuthOption that overrides the download URL
+// returned by b2_authorize_account.  This can be used to route downloads
+// through a CDN or proxy while API calls go directly to B2.
+func SetDownloadURL(url string) AuthOption {
+	return func(o *b2Options) {
+		o.downloadURL = url
+	}
+}
+
+// DownloadTransport returns an AuthOption that sets the HTTP mechanism used
+// for downloads.  If unset, downloads use the transport set by Transport.
+func DownloadTransport(rt http.RoundTripper) AuthOption {
+	return func(o *b2Options) {
+		o.downloadTransport = rt
+	}
+}
+
 type LifecycleRule struct {
-	Prefix                 string
-	DaysNewUntilHidden     int
-	DaysHiddenUntilDeleted int
+	Prefix                   string
+	DaysNewUntilHidden       int
+	DaysHiddenUntilDeleted   int
+	DaysStartedUntilCanceled int
+}
+
+type CORSRule struct {
+	Name              string
+	AllowedOrigins    []string
+	AllowedOperations []string
+	AllowedHeaders    []string
+	ExposeHeaders     []string
+	MaxAgeSeconds     int
+}
+
+type ReplicationRule struct {
+	Name                 string
+	DestinationBucketID  string
+	Prefix               string
+	IncludeExistingFiles bool
+	Enabled              bool
+	Priority             int
+}
+
+// Replication is a bucket's replication configuration.  A bucket that is a
+// replication source has a SourceKeyID and Rules; one that is a destination
+// has a KeyMapping, from source key IDs to the destination keys they use.
+type Replication struct {
+	SourceKeyID string
+	Rules       []ReplicationRule
+	KeyMapping  map[string]string
 }
 
 // CreateBucket wraps b2_create_bucket.
@@ -528,9 +700,10 @@ func (b *B2) CreateBucket(ctx context.Context, name, btype string, info map[stri
 	var b2rules []b2types.LifecycleRule
 	for _, rule := range rules {
 		b2rules = append(b2rules, b2types.LifecycleRule{
-			Prefix:                 rule.Prefix,
-			DaysNewUntilHidden:     rule.DaysNewUntilHidden,
-			DaysHiddenUntilDeleted: rule.DaysHiddenUntilDeleted,
+			Prefix:                   rule.Prefix,
+			DaysNewUntilHidden:       rule.DaysNewUntilHidden,
+			DaysHiddenUntilDeleted:   rule.DaysHiddenUntilDeleted,
+			DaysStartedUntilCanceled: rule.DaysStartedUntilCanceled,
 		})
 	}
 	b2req := &b2types.CreateBucketRequest{
@@ -550,19 +723,22 @@ func (b *B2) CreateBucket(ctx context.Context, name, btype string, info map[stri
 	var respRules []LifecycleRule
 	for _, rule := range b2resp.LifecycleRules {
 		respRules = append(respRules, LifecycleRule{
-			Prefix:                 rule.Prefix,
-			DaysNewUntilHidden:     rule.DaysNewUntilHidden,
-			DaysHiddenUntilDeleted: rule.DaysHiddenUntilDeleted,
+			Prefix:                   rule.Prefix,
+			DaysNewUntilHidden:       rule.DaysNewUntilHidden,
+			DaysHiddenUntilDeleted:   rule.DaysHiddenUntilDeleted,
+			DaysStartedUntilCanceled: rule.DaysStartedUntilCanceled,
 		})
 	}
-	return &Bucket{
+	bucket := &Bucket{
 		Name:           name,
 		Info:           b2resp.Info,
 		LifecycleRules: respRules,
 		ID:             b2resp.BucketID,
 		rev:            b2resp.Revision,
 		b2:             b,
-	}, nil
+	}
+	bucket.readSettings(b2resp)
+	return bucket, nil
 }
 
 // DeleteBucket wraps b2_delete_bucket.
@@ -583,53 +759,148 @@ type Bucket struct {
 	Type           string
 	Info           map[string]string
 	LifecycleRules []LifecycleRule
-	ID             string
-	rev            int
-	b2             *B2
+	CORSRules      []CORSRule
+
+	// DefaultEncryption is the mode of the bucket's default server-side
+	// encryption, such as "SSE-B2", or "none".  It is "" if the key may not
+	// read it.
+	DefaultEncryption string
+
+	// Replication is nil if the key may not read the bucket's replication
+	// configuration.
+	Replication *Replication
+
+	ID  string
+	rev int
+	b2  *B2
+}
+
+// readSettings sets the bucket's CORS rules, default encryption, and
+// replication from a bucket in a B2 response.
+func (b *Bucket) readSettings(r *b2types.CreateBucketResponse) {
+	for _, rule := range r.CORSRules {
+		b.CORSRules = append(b.CORSRules, CORSRule{
+			Name:              rule.Name,
+			AllowedOrigins:    rule.AllowedOrigins,
+			AllowedOperations: rule.AllowedOperations,
+			AllowedHeaders:    rule.AllowedHeaders,
+			ExposeHeaders:     rule.ExposeHeaders,
+			MaxAgeSeconds:     rule.MaxAgeSeconds,
+		})
+	}
+	if e := r.DefaultEncryption; e != nil && e.IsClientAuthorizedToRead {
+		b.DefaultEncryption = "none"
+		if e.Value != nil && e.Value.Mode != "" {
+			b.DefaultEncryption = e.Value.Mode
+		}
+	}
+	if rc := r.Replication; rc != nil && rc.IsClientAuthorizedToRead {
+		b.Replication = &Replication{}
+		if rc.Value != nil && rc.Value.AsSource != nil {
+			b.Replication.SourceKeyID = rc.Value.AsSource.SourceKeyID
+			for _, rule := range rc.Value.AsSource.Rules {
+				b.Replication.Rules = append(b.Replication.Rules, ReplicationRule(rule))
+			}
+		}
+		if rc.Value != nil && rc.Value.AsDestination != nil {
+			b.Replication.KeyMapping = rc.Value.AsDestination.KeyMapping
+		}
+	}
 }
 
-// Update wraps b2_update_bucket.
+// Update wraps b2_update_bucket.  It sends the bucket's info, rules, default
+// encryption, and replication only if they are set: a nil Info or rules, a
+// DefaultEncryption of "", and a nil Replication are left as they are.  Like
+// b2_update_bucket itself, it needs the writeBucketEncryption and
+// writeBucketReplications capabilities to change those.
 func (b *Bucket) Update(ctx context.Context) (*Bucket, error) {
-	var rules []b2types.LifecycleRule
-	for _, rule := range b.LifecycleRules {
-		rules = append(rules, b2types.LifecycleRule{
-			DaysNewUntilHidden:     rule.DaysNewUntilHidden,
-			DaysHiddenUntilDeleted: rule.DaysHiddenUntilDeleted,
-			Prefix:                 rule.Prefix,
-		})
-	}
 	b2req := &b2types.UpdateBucketRequest{
 		AccountID: b.b2.accountID,
 		BucketID:  b.ID,
 		// Name:           b.Name,
-		Type:           b.Type,
-		Info:           b.Info,
-		LifecycleRules: rules,
-		IfRevisionIs:   b.rev,
+		Type:         b.Type,
+		IfRevisionIs: b.rev,
+	}
+	if b.Info != nil {
+		b2req.Info = &b.Info
+	}
+	if b.LifecycleRules != nil {
+		rules := []b2types.LifecycleRule{}
+		for _, rule := range b.LifecycleRules {
+			rules = append(rules, b2types.LifecycleRule{
+				DaysNewUntilHidden:       rule.DaysNewUntilHidden,
+				DaysHiddenUntilDeleted:   rule.DaysHiddenUntilDeleted,
+				DaysStartedUntilCanceled: rule.DaysStartedUntilCanceled,
+				Prefix:                   rule.Prefix,
+			})
+		}
+		b2req.LifecycleRules = &rules
+	}
+	if b.CORSRules != nil {
+		rules := []b2types.CORSRule{}
+		for _, rule := range b.CORSRules {
+			rules = append(rules, b2types.CORSRule{
+				Name:              rule.Name,
+				AllowedOrigins:    rule.AllowedOrigins,
+				AllowedOperations: rule.AllowedOperations,
+				AllowedHeaders:    rule.AllowedHeaders,
+				ExposeHeaders:     rule.ExposeHeaders,
+				MaxAgeSeconds:     rule.MaxAgeSeconds,
+			})
+		}
+		b2req.CORSRules = &rules
+	}
+	switch b.DefaultEncryption {
+	case "":
+	case "none":
+		b2req.DefaultEncryption = &b2types.DefaultEncryptionSetting{}
+	default:
+		mode := b.DefaultEncryption
+		b2req.DefaultEncryption = &b2types.DefaultEncryptionSetting{Mode: &mode, Algorithm: "AES256"}
+	}
+	if r := b.Replication; r != nil {
+		b2req.Replication = &b2types.ReplicationConfiguration{}
+		if r.SourceKeyID != "" || len(r.Rules) > 0 {
+			src := &b2types.ReplicationSource{
+				Rules:       []b2types.ReplicationRule{},
+				SourceKeyID: r.SourceKeyID,
+			}
+			for _, rule := range r.Rules {
+				src.Rules = append(src.Rules, b2types.ReplicationRule(rule))
+			}
+			b2req.Replication.AsSource = src
+		}
+		if len(r.KeyMapping) > 0 {
+			b2req.Replication.AsDestination = &b2types.ReplicationDestination{KeyMapping: r.KeyMapping}
+		}
 	}
 	headers := map[string]string{
 		"Authorization": b.b2.authToken,
 	}
 	b2resp := &b2types.UpdateBucketResponse{}
-	if err := b.b2.opts.makeRequest(ctx, "b2_update_bucket", "POST", b.b2.apiURI+b2types.V1api+"b2_update_bucket", b2req, b2resp, headers, nil); err != nil {
+	// v2, which takes CORS rules, default encryption, and replication.
+	if err := b.b2.opts.makeRequest(ctx, "b2_update_bucket", "POST", b.b2.apiURI+b2types.V2api+"b2_update_bucket", b2req, b2resp, headers, nil); err != nil {
 		return nil, err
 	}
 	var respRules []LifecycleRule
 	for _, rule := range b2resp.LifecycleRules {
 		respRules = append(respRules, LifecycleRule{
-			Prefix:                 rule.Prefix,
-			DaysNewUntilHidden:     rule.DaysNewUntilHidden,
-			DaysHiddenUntilDeleted: rule.DaysHiddenUntilDeleted,
+			Prefix:                   rule.Prefix,
+			DaysNewUntilHidden:       rule.DaysNewUntilHidden,
+			DaysHiddenUntilDeleted:   rule.DaysHiddenUntilDeleted,
+			DaysStartedUntilCanceled: rule.DaysStartedUntilCanceled,
 		})
 	}
-	return &Bucket{
+	bucket := &Bucket{
 		Name:           b.Name,
 		Type:           b2resp.Type,
 		Info:           b2resp.Info,
 		LifecycleRules: respRules,
 		ID:             b2resp.BucketID,
 		b2:             b.b2,
-	}, nil
+	}
+	bucket.readSettings((*b2types.CreateBucketResponse)(b2resp))
+	return bucket, nil
 }
 
 // BaseURL returns the base part of the download URLs.
@@ -637,6 +908,95 @@ func (b *Bucket) BaseURL() string {
 	return b.b2.downloadURI
 }
 
+// NotificationRule is a bucket's event notification rule.  B2 sends the
+// events it matches to URL as webhooks.
+type NotificationRule struct {
+	Name             string
+	EventTypes       []string
+	Prefix           string
+	Enabled          bool
+	URL              string
+	CustomHeaders    map[string]string
+	SigningSecret    string
+	Suspended        bool
+	SuspensionReason string
+}
+
+// NotificationRules wraps b2_get_bucket_notification_rules.
+func (b *Bucket) NotificationRules(ctx context.Context) ([]NotificationRule, error) {
+	b2req := &b2types.GetBucketNotificationRulesRequest{
+		BucketID: b.ID,
+	}
+	headers := map[string]string{
+		"Authorization": b.b2.authToken,
+	}
+	b2resp := &b2types.GetBucketNotificationRulesResponse{}
+	// Notification rules are only in v3.
+	if err := b.b2.opts.makeRequest(ctx, "b2_get_bucket_notification_rules", "POST", b.b2.apiURI+b2types.V3api+"b2_get_bucket_notification_rules", b2req, b2resp, headers, nil); err != nil {
+		return nil, err
+	}
+	return notificationRules(b2resp.Rules), nil
+}
+
+// SetNotificationRules wraps b2_set_bucket_notification_rules.  It replaces
+// all of the bucket's rules, and returns them as B2 has stored them.
+func (b *Bucket) SetNotificationRules(ctx context.Context, rules []NotificationRule) ([]NotificationRule, error) {
+	b2req := &b2types.SetBucketNotificationRulesRequest{
+		BucketID: b.ID,
+		Rules:    []b2types.NotificationRule{},
+	}
+	for _, rule := range rules {
+		r := b2types.NotificationRule{
+			Name:       rule.Name,
+			EventTypes: rule.EventTypes,
+			Enabled:    rule.Enabled,
+			Prefix:     rule.Prefix,
+			Target: b2types.NotificationTarget{
+				Type:          "webhook",
+				URL:           rule.URL,
+				SigningSecret: rule.SigningSecret,
+			},
+		}
+		for k, v := range rule.CustomHeaders {
+			r.Target.CustomHeaders = append(r.Target.CustomHeaders, b2types.CustomHeader{Name: k, Value: v})
+		}
+		sort.Slice(r.Target.CustomHeaders, func(i, j int) bool { return r.Target.CustomHeaders[i].Name < r.Target.CustomHeaders[j].Name })
+		b2req.Rules = append(b2req.Rules, r)
+	}
+	headers := map[string]string{
+		"Authorization": b.b2.authToken,
+	}
+	b2resp := &b2types.SetBucketNotificationRulesResponse{}
+	if err := b.b2.opts.makeRequest(ctx, "b2_set_bucket_notification_rules", "POST", b.b2.apiURI+b2types.V3api+"b2_set_bucket_notification_rules", b2req, b2resp, headers, nil); err != nil {
+		return nil, err
+	}
+	return notificationRules(b2resp.Rules), nil
+}
+
+func notificationRules(rules []b2types.NotificationRule) []NotificationRule {
+	var rtn []NotificationRule
+	for _, rule := range rules {
+		r := NotificationRule{
+			Name:             rule.Name,
+			EventTypes:       rule.EventTypes,
+			Prefix:           rule.Prefix,
+			Enabled:          rule.Enabled,
+			URL:              rule.Target.URL,
+			SigningSecret:    rule.Target.SigningSecret,
+			Suspended:        rule.Suspended,
+			SuspensionReason: rule.SuspensionReason,
+		}
+		if len(rule.Target.CustomHeaders) > 0 {
+			r.CustomHeaders = make(map[string]string)
+			for _, h := range rule.Target.CustomHeaders {
+				r.CustomHeaders[h.Name] = h.Value
+			}
+		}
+		rtn = append(rtn, r)
+	}
+	return rtn
+}
+
 // ListBuckets wraps b2_list_buckets.  If name is non-empty, only that bucket
 // will be returned if it exists; else nothing will be returned.
 func (b *B2) ListBuckets(ctx context.Context, name string) ([]*Bucket, error) {
@@ -657,12 +1017,13 @@ func (b *B2) ListBuckets(ctx context.Context, name string) ([]*Bucket, error) {
 		var rules []LifecycleRule
 		for _, rule := range bucket.LifecycleRules {
 			rules = append(rules, LifecycleRule{
-				Prefix:                 rule.Prefix,
-				DaysNewUntilHidden:     rule.DaysNewUntilHidden,
-				DaysHiddenUntilDeleted: rule.DaysHiddenUntilDeleted,
+				Prefix:                   rule.Prefix,
+				DaysNewUntilHidden:       rule.DaysNewUntilHidden,
+				DaysHiddenUntilDeleted:   rule.DaysHiddenUntilDeleted,
+				DaysStartedUntilCanceled: rule.DaysStartedUntilCanceled,
 			})
 		}
-		buckets = append(buckets, &Bucket{
+		bk := &Bucket{
 			Name:           bucket.Name,
 			Type:           bucket.Type,
 			Info:           bucket.Info,
@@ -670,7 +1031,9 @@ func (b *B2) ListBuckets(ctx context.Context, name string) ([]*Bucket, error) {
 			ID:             bucket.BucketID,
 			rev:            bucket.Revision,
 			b2:             b,
-		})
+		}
+		bk.readSettings(&bucket)
+		buckets = append(buckets, bk)
 	}
 	return buckets, nil
 }
@@ -695,6 +1058,12 @@ func (url *URL) Reload(ctx context.Context) error {
 	return nil
 }
 
+// Endpoint returns the URL to which files are uploaded, and the token that
+// authorizes uploads to it.
+func (url *URL) Endpoint() (string, string) {
+	return url.uri, url.token
+}
+
 // GetUploadURL wraps b2_get_upload_url.
 func (b *Bucket) GetUploadURL(ctx context.Context) (*URL, error) {
 	b2req := &b2types.GetUploadURLRequest{
@@ -748,9 +1117,13 @@ func (url *URL) UploadFile(ctx context.Context, r io.Reader, size int, name, con
 	if err := url.b2.opts.makeRequest(ctx, "b2_upload_file", "POST", url.uri, nil, b2resp, headers, &requestBody{body: r, size: int64(size)}); err != nil {
 		return nil, err
 	}
+	fsize := int64(size)
+	if sha1 == "hex_digits_at_end" {
+		fsize -= 40
+	}
 	return &File{
 		Name:      name,
-		Size:      int64(size),
+		Size:      fsize,
 		Timestamp: millitime(b2resp.Timestamp),
 		Status:    b2resp.Action,
 		ID:        b2resp.FileID,
@@ -770,6 +1143,48 @@ func (f *File) DeleteFileVersion(ctx context.Context) error {
 	return f.b2.opts.makeRequest(ctx, "b2_delete_file_version", "POST", f.b2.apiURI+b2types.V1api+"b2_delete_file_version", b2req, nil, headers, nil)
 }
 
+// CopyFile wraps b2_copy_file.  If contentType is empty, the new file keeps the
+// content type and info of the original; otherwise they are replaced with
+// contentType and info.  If bucketID is empty, the copy is made in the same
+// bucket.
+func (f *File) CopyFile(ctx context.Context, bucketID, name, contentType string, info map[string]string) (*File, error) {
+	b2req := &b2types.CopyFileRequest{
+		SourceID:     f.ID,
+		DestBucketID: bucketID,
+		Name:         name,
+	}
+	if contentType != "" {
+		b2req.MetadataDirective = "REPLACE"
+		b2req.ContentType = contentType
+		b2req.Info = info
+	}
+	b2resp := &b2types.CopyFileResponse{}
+	headers := map[string]string{
+		"Authorization": f.b2.authToken,
+	}
+	if err := f.b2.opts.makeRequest(ctx, "b2_copy_file", "POST", f.b2.apiURI+b2types.V1api+"b2_copy_file", b2req, b2resp, headers, nil); err != nil {
+		return nil, err
+	}
+	return &File{
+		Name:      b2resp.Name,
+		Size:      b2resp.Size,
+		Status:    b2resp.Action,
+		Timestamp: millitime(b2resp.Timestamp),
+		Info: &FileInfo{
+			Name:        b2resp.Name,
+			SHA1:        b2resp.SHA1,
+			MD5:         b2resp.MD5,
+			Size:        b2resp.Size,
+			ContentType: b2resp.ContentType,
+			Info:        b2resp.Info,
+			Status:      b2resp.Action,
+			Timestamp:   millitime(b2resp.Timestamp),
+		},
+		ID: b2resp.FileID,
+		b2: f.b2,
+	}, nil
+}
+
 // LargeFile holds information necessary to implement B2 large file support.
 type LargeFile struct {
 	ID string
@@ -907,6 +1322,75 @@ func (fc *FileChunk) Reload(ctx context.Context) error {
 	return nil
 }
 
+const defaultChunkPoolSize = 100
+
+// FileChunkPool holds b2_get_upload_part_url results for reuse, keyed by large
+// file ID.  Callers check out a FileChunk before uploading a part and return
+// it afterwards; URLs that produced an error are discarded rather than reused.
+// It is safe for concurrent use.
+type FileChunkPool struct {
+	mu    sync.Mutex
+	size  int
+	files map[string][]*FileChunk
+}
+
+// NewFileChunkPool returns a pool that retains at most size idle upload URLs
+// for each large file.  If size is less than 1, a default of 100 is used.
+func NewFileChunkPool(size int) *FileChunkPool {
+	if size < 1 {
+		size = defaultChunkPoolSize
+	}
+	return &FileChunkPool{
+		size:  size,
+		files: make(map[string][]*FileChunk),
+	}
+}
+
+// Checkout returns a FileChunk for l.  An idle URL for the same file is used if
+// one is available; otherwise a new one is requested with GetUploadPartURL.
+func (p *FileChunkPool) Checkout(ctx context.Context, l *LargeFile) (*FileChunk, error) {
+	p.mu.Lock()
+	fcs := p.files[l.ID]
+	if n := len(fcs); n > 0 {
+		fc := fcs[n-1]
+		p.files[l.ID] = fcs[:n-1]
+		p.mu.Unlock()
+		// Bind the URL to l, so that part hashes are recorded on the caller's
+		// LargeFile and not whichever one originally fetched the URL.
+		return &FileChunk{
+			url:   fc.url,
+			token: fc.token,
+			file:  l,
+		}, nil
+	}
+	p.mu.Unlock()
+	return l.GetUploadPartURL(ctx)
+}
+
+// Return gives fc back to the pool.  err should be the result of the last
+// upload made with fc.  If err is non-nil, the URL is discarded, as B2
+// requires that clients fetch a new upload URL after any failure.
+func (p *FileChunkPool) Return(fc *FileChunk, err error) {
+	if fc == nil || err != nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := fc.file.ID
+	if len(p.files[id]) >= p.size {
+		return
+	}
+	p.files[id] = append(p.files[id], fc)
+}
+
+// Invalidate discards all idle URLs for the given large file.  It should be
+// called once the file has been finished or canceled.
+func (p *FileChunkPool) Invalidate(fileID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.files, fileID)
+}
+
 // UploadPart wraps b2_upload_part.
 func (fc *FileChunk) UploadPart(ctx context.Context, r io.Reader, sha1 string, size, index int) (int, error) {
 	headers := map[string]string{
@@ -997,6 +1481,22 @@ func (b *Bucket) ListUnfinishedLargeFiles(ctx context.Context, count int, contin
 
 // ListFileNames wraps b2_list_file_names.
 func (b *Bucket) ListFileNames(ctx context.Context, count int, continuation, prefix, delimiter string) ([]*File, string, error) {
+	var files []*File
+	cont, err := b.ListFileNamesFunc(ctx, count, continuation, prefix, delimiter, func(f *File) error {
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return files, cont, nil
+}
+
+// ListFileNamesFunc is like ListFileNames, but instead of returning the files
+// it calls f with each one as it is decoded from the response, so that a large
+// page need not be held in memory at once.  If f returns an error, the listing
+// is abandoned and that error is returned.
+func (b *Bucket) ListFileNamesFunc(ctx context.Context, count int, continuation, prefix, delimiter string, f func(*File) error) (string, error) {
 	if prefix == "" {
 		prefix = b.b2.pfx
 	}
@@ -1007,40 +1507,43 @@ func (b *Bucket) ListFileNames(ctx context.Context, count int, continuation, pre
 		Prefix:       prefix,
 		Delimiter:    delimiter,
 	}
-	b2resp := &b2types.ListFileNamesResponse{}
+	b2resp := &fileNameStream{
+		f: func(fi *b2types.GetFileInfoResponse) error {
+			return f(&File{
+				Name:      fi.Name,
+				Size:      fi.Size,
+				Status:    fi.Action,
+				Timestamp: millitime(fi.Timestamp),
+				Info: &FileInfo{
+					Name:        fi.Name,
+					SHA1:        fi.SHA1,
+					MD5:         fi.MD5,
+					Size:        fi.Size,
+					ContentType: fi.ContentType,
+					Info:        fi.Info,
+					Status:      fi.Action,
+					Timestamp:   millitime(fi.Timestamp),
+				},
+				ID: fi.FileID,
+				b2: b.b2,
+			})
+		},
+	}
 	headers := map[string]string{
 		"Authorization": b.b2.authToken,
 	}
 	if err := b.b2.opts.makeRequest(ctx, "b2_list_file_names", "POST", b.b2.apiURI+b2types.V1api+"b2_list_file_names", b2req, b2resp, headers, nil); err != nil {
-		return nil, "", err
-	}
-	cont := b2resp.Continuation
-	var files []*File
-	for _, f := range b2resp.Files {
-		files = append(files, &File{
-			Name:      f.Name,
-			Size:      f.Size,
-			Status:    f.Action,
-			Timestamp: millitime(f.Timestamp),
-			Info: &FileInfo{
-				Name:        f.Name,
-				SHA1:        f.SHA1,
-				MD5:         f.MD5,
-				Size:        f.Size,
-				ContentType: f.ContentType,
-				Info:        f.Info,
-				Status:      f.Action,
-				Timestamp:   millitime(f.Timestamp),
-			},
-			ID: f.FileID,
-			b2: b.b2,
-		})
+		return "", err
 	}
-	return files, cont, nil
+	return b2resp.next, nil
 }
 
-// ListFileVersions wraps b2_list_file_versions.
+// ListFileVersions wraps b2_list_file_versions.  If startID is given,
+// startName must be the name of that file version.
 func (b *Bucket) ListFileVersions(ctx context.Context, count int, startName, startID, prefix, delimiter string) ([]*File, string, string, error) {
+	if startID != "" && startName == "" {
+		return nil, "", "", fmt.Errorf("b2_list_file_versions: start file ID %q given without a start file name", startID)
+	}
 	if prefix == "" {
 		prefix = b.b2.pfx
 	}
@@ -1109,6 +1612,11 @@ type FileReader struct {
 	SHA1          string
 	ID            string
 	Info          map[string]string
+	Timestamp     time.Time
+
+	// Header holds all of the response headers, including those, such as
+	// Cache-Control and Content-Range, that are not otherwise parsed.
+	Header http.Header
 }
 
 func mkRange(offset, size int64) string {
@@ -1141,7 +1649,7 @@ func (b *Bucket) DownloadFileByName(ctx context.Context, name string, offset, si
 		req.Header.Set("Range", rng)
 	}
 	logRequest(req, nil)
-	resp, err := makeNetRequest(ctx, req, b.b2.opts.getTransport())
+	resp, err := makeNetRequest(ctx, req, b.b2.opts.getDownloadTransport())
 	if err != nil {
 		return nil, err
 	}
@@ -1176,6 +1684,15 @@ func (b *Bucket) DownloadFileByName(ctx context.Context, name string, offset, si
 	if sha1 == "none" && info["Large_file_sha1"] != "" {
 		sha1 = info["Large_file_sha1"]
 	}
+	var stamp time.Time
+	if ts := resp.Header.Get("X-Bz-Upload-Timestamp"); ts != "" {
+		ms, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		stamp = millitime(ms)
+	}
 	return &FileReader{
 		ReadCloser:    resp.Body,
 		SHA1:          sha1,
@@ -1183,6 +1700,8 @@ func (b *Bucket) DownloadFileByName(ctx context.Context, name string, offset, si
 		ContentType:   resp.Header.Get("Content-Type"),
 		ContentLength: int(clen),
 		Info:          info,
+		Timestamp:     stamp,
+		Header:        resp.Header.Clone(),
 	}, nil
 }
 
@@ -0,0 +1,223 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/burner-account/blazer/internal/b2types"
+)
+
+func TestFileChunkPool(t *testing.T) {
+	ctx := context.Background()
+	p := NewFileChunkPool(1)
+	lf := &LargeFile{ID: "file1", hashes: make(map[int]string)}
+
+	p.Return(&FileChunk{url: "a", token: "ta", file: lf}, nil)
+	p.Return(&FileChunk{url: "b", token: "tb", file: lf}, nil) // over capacity
+	p.Return(&FileChunk{url: "c", token: "tc", file: lf}, errors.New("bad"))
+
+	other := &LargeFile{ID: "file1", hashes: make(map[int]string)}
+	fc, err := p.Checkout(ctx, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fc.url != "a" || fc.token != "ta" {
+		t.Errorf("Checkout: got %q/%q, want a/ta", fc.url, fc.token)
+	}
+	if fc.file != other {
+		t.Errorf("Checkout: chunk not bound to the requesting file")
+	}
+	if n := len(p.files["file1"]); n != 0 {
+		t.Errorf("pool has %d idle URLs, want 0", n)
+	}
+
+	p.Return(fc, nil)
+	p.Invalidate("file1")
+	if n := len(p.files["file1"]); n != 0 {
+		t.Errorf("after Invalidate: pool has %d idle URLs, want 0", n)
+	}
+}
+
+func TestTokenExpiresWithin(t *testing.T) {
+	// B2's clock runs two hours ahead of ours.
+	opts := &b2Options{}
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Date", time.Now().Add(2*time.Hour).UTC().Format(http.TimeFormat))
+	opts.clock.observe(resp)
+	if skew := opts.clock.offset(); skew < 2*time.Hour-2*time.Second || skew > 2*time.Hour+2*time.Second {
+		t.Fatalf("ClockSkew: got %v, want about 2h", skew)
+	}
+
+	// The token was issued 21h ago by B2's clock, which is only 19h by ours.
+	b := &B2{opts: opts, authTime: opts.clock.now().Add(-21 * time.Hour)}
+	if !b.TokenExpiresWithin(4 * time.Hour) {
+		t.Error("TokenExpiresWithin(4h): got false, want true")
+	}
+	if b.TokenExpiresWithin(2 * time.Hour) {
+		t.Error("TokenExpiresWithin(2h): got true, want false")
+	}
+}
+
+func TestLocalClock(t *testing.T) {
+	local := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	opts := &b2Options{}
+	LocalClock(func() time.Time { return local })(opts)
+	b := &B2{opts: opts, authTime: opts.clock.now()}
+
+	local = local.Add(AuthTokenLifetime - time.Minute)
+	if b.TokenExpiresWithin(0) {
+		t.Error("TokenExpiresWithin(0): got true a minute before expiry")
+	}
+	if !b.TokenExpiresWithin(2 * time.Minute) {
+		t.Error("TokenExpiresWithin(2m): got false a minute before expiry")
+	}
+}
+
+func TestFileNameStream(t *testing.T) {
+	table := []struct {
+		body  string
+		names []string
+		next  string
+		err   bool
+	}{
+		{
+			body:  `{"files": [{"fileName": "a", "size": 1}, {"fileName": "b/", "action": "folder"}], "nextFileName": "c"}`,
+			names: []string{"a", "b/"},
+			next:  "c",
+		},
+		{
+			body:  `{"nextFileName": null, "extra": {"files": [1, 2]}, "files": [{"fileName": "a", "fileInfo": {"k": "v"}}]}`,
+			names: []string{"a"},
+		},
+		{
+			body: `{"files": null, "nextFileName": null}`,
+		},
+		{
+			body: `{"files": {}}`,
+			err:  true,
+		},
+		{
+			body:  `{"files": [{"fileName": "a"}, {"fileName": `,
+			names: []string{"a"},
+			err:   true,
+		},
+	}
+
+	for _, e := range table {
+		var names []string
+		s := &fileNameStream{
+			f: func(fi *b2types.GetFileInfoResponse) error {
+				names = append(names, fi.Name)
+				return nil
+			},
+		}
+		err := s.decodeResponse(strings.NewReader(e.body))
+		if (err != nil) != e.err {
+			t.Errorf("decodeResponse(%s): got error %v, want error %v", e.body, err, e.err)
+		}
+		if !reflect.DeepEqual(names, e.names) {
+			t.Errorf("decodeResponse(%s): got files %v, want %v", e.body, names, e.names)
+		}
+		if !e.err && s.next != e.next {
+			t.Errorf("decodeResponse(%s): got next %q, want %q", e.body, s.next, e.next)
+		}
+	}
+
+	// An error from f stops the decoding.
+	stop := errors.New("stop")
+	var n int
+	s := &fileNameStream{
+		f: func(*b2types.GetFileInfoResponse) error {
+			n++
+			return stop
+		},
+	}
+	if err := s.decodeResponse(strings.NewReader(`{"files": [{"fileName": "a"}, {"fileName": "b"}]}`)); err != stop {
+		t.Errorf("decodeResponse: got %v, want %v", err, stop)
+	}
+	if n != 1 {
+		t.Errorf("decodeResponse: f called %d times, want 1", n)
+	}
+}
+
+func TestReadSettings(t *testing.T) {
+	table := []struct {
+		body string
+		want *Bucket
+	}{
+		{
+			body: `{
+				"corsRules": [{"corsRuleName": "downloads", "allowedOrigins": ["https://example.com"], "allowedOperations": ["b2_download_file_by_name"], "maxAgeSeconds": 3600}],
+				"defaultServerSideEncryption": {"isClientAuthorizedToRead": true, "value": {"algorithm": "AES256", "mode": "SSE-B2"}},
+				"replicationConfiguration": {"isClientAuthorizedToRead": true, "value": {
+					"asReplicationSource": {"replicationRules": [{"destinationBucketId": "dest", "fileNamePrefix": "", "includeExistingFiles": true, "isEnabled": true, "priority": 1, "replicationRuleName": "all"}], "sourceApplicationKeyId": "key"},
+					"asReplicationDestination": {"sourceToDestinationKeyMapping": {"src": "dst"}}
+				}}
+			}`,
+			want: &Bucket{
+				CORSRules: []CORSRule{{
+					Name:              "downloads",
+					AllowedOrigins:    []string{"https://example.com"},
+					AllowedOperations: []string{"b2_download_file_by_name"},
+					MaxAgeSeconds:     3600,
+				}},
+				DefaultEncryption: "SSE-B2",
+				Replication: &Replication{
+					SourceKeyID: "key",
+					Rules:       []ReplicationRule{{Name: "all", DestinationBucketID: "dest", IncludeExistingFiles: true, Enabled: true, Priority: 1}},
+					KeyMapping:  map[string]string{"src": "dst"},
+				},
+			},
+		},
+		{
+			body: `{
+				"corsRules": [],
+				"defaultServerSideEncryption": {"isClientAuthorizedToRead": true, "value": {"algorithm": null, "mode": null}},
+				"replicationConfiguration": {"isClientAuthorizedToRead": true, "value": {}}
+			}`,
+			want: &Bucket{
+				DefaultEncryption: "none",
+				Replication:       &Replication{},
+			},
+		},
+		{
+			body: `{
+				"defaultServerSideEncryption": {"isClientAuthorizedToRead": false},
+				"replicationConfiguration": {"isClientAuthorizedToRead": false}
+			}`,
+			want: &Bucket{},
+		},
+	}
+
+	for _, e := range table {
+		r := &b2types.CreateBucketResponse{}
+		if err := json.Unmarshal([]byte(e.body), r); err != nil {
+			t.Fatal(err)
+		}
+		got := &Bucket{}
+		got.readSettings(r)
+		if !reflect.DeepEqual(got, e.want) {
+			t.Errorf("readSettings(%s): got %+v, want %+v", e.body, got, e.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2017, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build gofuzz
+// +build gofuzz
+
+package base
+
+import "fmt"
+
+// hook for go-fuzz: https://github.com/dvyukov/go-fuzz
+func Fuzz(data []byte) int {
+	orig := string(data)
+	escaped := escape(orig)
+
+	unescaped, err := unescape(escaped)
+	if err != nil {
+		return 0
+	}
+
+	if unescaped != orig {
+		panic(fmt.Sprintf("unescaped: \"%#v\", != orig: \"%#v\"", unescaped, orig))
+	}
+
+	return 1
+}
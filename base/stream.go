@@ -0,0 +1,106 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/burner-account/blazer/internal/b2types"
+)
+
+// A responseDecoder decodes a response body as it is read, instead of having
+// makeRequest decode all of it into a struct at once.
+type responseDecoder interface {
+	decodeResponse(io.Reader) error
+}
+
+// fileNameStream decodes a b2_list_file_names response, passing each file to
+// f as soon as it is read.
+type fileNameStream struct {
+	f    func(*b2types.GetFileInfoResponse) error
+	next string
+}
+
+func (s *fileNameStream) decodeResponse(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case "files":
+			if err := decodeArray(dec, func() error {
+				fi := &b2types.GetFileInfoResponse{}
+				if err := dec.Decode(fi); err != nil {
+					return err
+				}
+				return s.f(fi)
+			}); err != nil {
+				return err
+			}
+		case "nextFileName":
+			var next *string
+			if err := dec.Decode(&next); err != nil {
+				return err
+			}
+			if next != nil {
+				s.next = *next
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// decodeArray reads a JSON array, or null, calling f to decode each element.
+func decodeArray(dec *json.Decoder, f func() error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("unexpected %v in response; want an array", tok)
+	}
+	for dec.More() {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, d json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != d {
+		return fmt.Errorf("unexpected %v in response; want %v", tok, d)
+	}
+	return nil
+}
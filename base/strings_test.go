@@ -1,7 +1,6 @@
 package base
 
 import (
-	"fmt"
 	"testing"
 )
 
@@ -33,20 +32,3 @@ func TestEncodeDecode(t *testing.T) {
 		}
 	}
 }
-
-// hook for go-fuzz: https://github.com/dvyukov/go-fuzz
-func Fuzz(data []byte) int {
-	orig := string(data)
-	escaped := escape(orig)
-
-	unescaped, err := unescape(escaped)
-	if err != nil {
-		return 0
-	}
-
-	if unescaped != orig {
-		panic(fmt.Sprintf("unescaped: \"%#v\", != orig: \"%#v\"", unescaped, orig))
-	}
-
-	return 1
-}
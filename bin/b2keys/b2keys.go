@@ -2,10 +2,13 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/subcommands"
@@ -24,22 +27,95 @@ func main() {
 	os.Exit(int(subcommands.Execute(ctx)))
 }
 
+// templates are named sets of capabilities for common kinds of key.
+var templates = map[string][]b2.Capability{
+	"readonly":  {b2.CapListBuckets, b2.CapListFiles, b2.CapReadFiles},
+	"writeonly": {b2.CapListBuckets, b2.CapWriteFiles},
+	"readwrite": {b2.CapListBuckets, b2.CapListFiles, b2.CapReadFiles, b2.CapWriteFiles, b2.CapDeleteFiles},
+	"admin": {
+		b2.CapListKeys, b2.CapWriteKeys, b2.CapDeleteKeys,
+		b2.CapListBuckets, b2.CapWriteBuckets, b2.CapDeleteBuckets,
+		b2.CapListFiles, b2.CapReadFiles, b2.CapShareFiles, b2.CapWriteFiles, b2.CapDeleteFiles,
+	},
+}
+
+func templateNames() string {
+	var names []string
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
 type create struct {
-	d      *time.Duration
-	bucket *string
-	pfx    *string
+	d        *time.Duration
+	bucket   *string
+	pfx      *string
+	template *string
+	yes      *bool
 }
 
 func (c *create) Name() string     { return "create" }
 func (c *create) Synopsis() string { return "create a new application key" }
 func (c *create) Usage() string {
-	return "b2keys create [-bucket bucket] [-duration duration] [-prefix pfx] name capability [capability ...]"
+	return "b2keys create [-bucket bucket] [-duration duration] [-prefix pfx] [-template template] [-y] name [capability ...]"
 }
 
 func (c *create) SetFlags(fs *flag.FlagSet) {
 	c.d = fs.Duration("duration", 0, "the lifetime of the new key")
 	c.bucket = fs.String("bucket", "", "limit the key to the given bucket")
 	c.pfx = fs.String("prefix", "", "limit the key to the objects starting with prefix")
+	c.template = fs.String("template", "", "start from a named set of capabilities: "+templateNames())
+	c.yes = fs.Bool("y", false, "create the key without asking for confirmation; there is no prompt unless stdin is a terminal")
+}
+
+// scope describes what a key will be able to do, for confirmation.
+func (c *create) scope(name string, caps []string) string {
+	s := fmt.Sprintf("key %q\n  capabilities: %s\n", name, strings.Join(caps, ", "))
+	if *c.bucket != "" {
+		s += fmt.Sprintf("  bucket: %s\n", *c.bucket)
+	} else {
+		s += "  bucket: all buckets\n"
+	}
+	if *c.pfx != "" {
+		s += fmt.Sprintf("  prefix: %s\n", *c.pfx)
+	}
+	if *c.d > 0 {
+		s += fmt.Sprintf("  expires: %s (in %v)\n", time.Now().Add(*c.d).Format(time.RFC1123), *c.d)
+	} else {
+		s += "  expires: never\n"
+	}
+	return s
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isTerminal reports whether f is a terminal, so that scripts that run b2keys
+// without one are not stopped by prompts.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func confirm(prompt string) bool {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
 }
 
 func (c *create) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -51,12 +127,48 @@ func (c *create) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{})
 	}
 
 	args := f.Args()
-	if len(args) < 2 {
+	if len(args) < 1 || (len(args) < 2 && *c.template == "") {
 		fmt.Fprintf(os.Stderr, "%s\n", c.Usage())
 		return subcommands.ExitUsageError
 	}
 	name := args[0]
-	caps := args[1:]
+	var caps []string
+	if *c.template != "" {
+		tcaps, ok := templates[*c.template]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown template %q; choose one of %s\n", *c.template, templateNames())
+			return subcommands.ExitUsageError
+		}
+		if *c.template == "admin" && *c.bucket != "" {
+			fmt.Fprintf(os.Stderr, "the admin template cannot be limited to a bucket\n")
+			return subcommands.ExitUsageError
+		}
+		for _, cp := range tcaps {
+			caps = append(caps, string(cp))
+		}
+	}
+	for _, cp := range args[1:] {
+		if !contains(caps, cp) {
+			caps = append(caps, cp)
+		}
+	}
+	if err := b2.ValidateCapabilities(caps...); err != nil {
+		// B2 may know capabilities that this program does not, so only warn.
+		var all []string
+		for _, cp := range b2.AllCapabilities() {
+			all = append(all, string(cp))
+		}
+		fmt.Fprintf(os.Stderr, "warning: %v; known capabilities are %s\n", err, strings.Join(all, ", "))
+	}
+	if *c.pfx != "" && *c.bucket == "" {
+		fmt.Fprintf(os.Stderr, "-prefix requires -bucket\n")
+		return subcommands.ExitUsageError
+	}
+
+	fmt.Fprintf(os.Stderr, "creating %s", c.scope(name, caps))
+	if !*c.yes && isTerminal(os.Stdin) && !confirm("continue? [y/N] ") {
+		return subcommands.ExitFailure
+	}
 
 	var opts []b2.KeyOption
 	if *c.d > 0 {
@@ -0,0 +1,249 @@
+// blazer is a command-line client for Backblaze B2.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/burner-account/blazer/b2"
+	"github.com/google/subcommands"
+)
+
+const (
+	apiID  = "B2_ACCOUNT_ID"
+	apiKey = "B2_SECRET_KEY"
+
+	// If set, objects are written with these credentials instead, and so
+	// are relayed through this program rather than copied by B2.
+	destID  = "B2_DEST_ACCOUNT_ID"
+	destKey = "B2_DEST_SECRET_KEY"
+)
+
+// maxServerCopy is the largest object B2 will copy in a single b2_copy_file
+// call.
+const maxServerCopy = 5e9
+
+func main() {
+	subcommands.Register(&copier{name: "cp", synopsis: "copy objects between buckets"}, "")
+	subcommands.Register(&copier{name: "mv", synopsis: "move objects between buckets, deleting every version of the source", move: true}, "")
+	subcommands.Register(&watch{}, "")
+	subcommands.Register(&resticServer{}, "")
+	subcommands.Register(&warm{}, "")
+	flag.Parse()
+	ctx := context.Background()
+	os.Exit(int(subcommands.Execute(ctx)))
+}
+
+// newClient returns a client authorized with the credentials in the
+// environment.  If it cannot, it reports why and returns the status with which
+// the command should exit.
+func newClient(ctx context.Context, opts ...b2.ClientOption) (*b2.Client, subcommands.ExitStatus) {
+	id := os.Getenv(apiID)
+	key := os.Getenv(apiKey)
+	if id == "" || key == "" {
+		fmt.Fprintf(os.Stderr, "both %s and %s must be set in the environment\n", apiID, apiKey)
+		return nil, subcommands.ExitUsageError
+	}
+	client, err := b2.NewClient(ctx, id, key, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return nil, subcommands.ExitFailure
+	}
+	return client, subcommands.ExitSuccess
+}
+
+// openBucket is like newClient, but also returns the named bucket.
+func openBucket(ctx context.Context, name string, opts ...b2.ClientOption) (*b2.Client, *b2.Bucket, subcommands.ExitStatus) {
+	client, st := newClient(ctx, opts...)
+	if st != subcommands.ExitSuccess {
+		return nil, nil, st
+	}
+	bucket, err := client.Bucket(ctx, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return nil, nil, subcommands.ExitFailure
+	}
+	return client, bucket, subcommands.ExitSuccess
+}
+
+// location is a parsed b2://bucket/name URL.
+type location struct {
+	bucket string
+	name   string
+}
+
+func parseLocation(s string) (location, error) {
+	rest := strings.TrimPrefix(s, "b2://")
+	if rest == s {
+		return location{}, fmt.Errorf("%s: not a b2:// URL", s)
+	}
+	parts := strings.SplitN(rest, "/", 2)
+	if parts[0] == "" {
+		return location{}, fmt.Errorf("%s: no bucket", s)
+	}
+	l := location{bucket: parts[0]}
+	if len(parts) == 2 {
+		l.name = parts[1]
+	}
+	return l, nil
+}
+
+type copier struct {
+	name     string
+	synopsis string
+	move     bool
+
+	recursive *bool
+}
+
+func (c *copier) Name() string     { return c.name }
+func (c *copier) Synopsis() string { return c.synopsis }
+func (c *copier) Usage() string {
+	return fmt.Sprintf("blazer %s [-recursive] b2://bucket/src b2://bucket/dst\n", c.name)
+}
+
+func (c *copier) SetFlags(fs *flag.FlagSet) {
+	c.recursive = fs.Bool("recursive", false, "treat the source as a prefix, and "+c.name+" every object beneath it")
+}
+
+func (c *copier) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	args := f.Args()
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "%s", c.Usage())
+		return subcommands.ExitUsageError
+	}
+	src, err := parseLocation(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return subcommands.ExitUsageError
+	}
+	dst, err := parseLocation(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return subcommands.ExitUsageError
+	}
+	if src.name == "" && !*c.recursive {
+		fmt.Fprintf(os.Stderr, "%s: no object name; did you mean -recursive?\n", args[0])
+		return subcommands.ExitUsageError
+	}
+
+	client, st := newClient(ctx, b2.UserAgent("blazer"))
+	if st != subcommands.ExitSuccess {
+		return st
+	}
+	dclient := client
+	if did, dkey := os.Getenv(destID), os.Getenv(destKey); did != "" || dkey != "" {
+		if did == "" || dkey == "" {
+			fmt.Fprintf(os.Stderr, "%s and %s must be set together\n", destID, destKey)
+			return subcommands.ExitUsageError
+		}
+		dclient, err = b2.NewClient(ctx, did, dkey, b2.UserAgent("blazer"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+
+	sbucket, err := client.Bucket(ctx, src.bucket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return subcommands.ExitFailure
+	}
+	dbucket, err := dclient.Bucket(ctx, dst.bucket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return subcommands.ExitFailure
+	}
+	t := &transfer{
+		src:    sbucket,
+		dst:    dbucket,
+		server: dclient == client,
+		move:   c.move,
+	}
+
+	if !*c.recursive {
+		name := dst.name
+		if name == "" || strings.HasSuffix(name, "/") {
+			name += path.Base(src.name)
+		}
+		if err := t.one(ctx, sbucket.Object(src.name), name); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return subcommands.ExitFailure
+		}
+		return subcommands.ExitSuccess
+	}
+
+	iter := sbucket.List(ctx, b2.ListPrefix(src.name))
+	for iter.Next() {
+		obj := iter.Object()
+		name := dst.name + strings.TrimPrefix(obj.Name(), src.name)
+		if err := t.one(ctx, obj, name); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+	if err := iter.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+type transfer struct {
+	src, dst *b2.Bucket
+	server   bool // whether B2 can make the copy itself
+	move     bool
+}
+
+// one copies obj to name in the destination bucket, and then, if this is a
+// move, deletes every version of obj, so that an older one does not take its
+// place.
+func (t *transfer) one(ctx context.Context, obj *b2.Object, name string) error {
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return err
+	}
+	to := t.dst.Object(name)
+	how := "copied"
+	if t.server && attrs.Size <= maxServerCopy {
+		err = obj.CopyTo(ctx, to)
+	} else {
+		how = "relayed"
+		err = relay(ctx, obj, to, attrs)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", obj.Name(), err)
+	}
+	fmt.Printf("%s b2://%s/%s -> b2://%s/%s\n", how, t.src.Name(), obj.Name(), t.dst.Name(), name)
+	if !t.move {
+		return nil
+	}
+	if err := obj.DeleteAllVersions(ctx); err != nil {
+		return fmt.Errorf("%s: copied, but not removed: %v", obj.Name(), err)
+	}
+	return nil
+}
+
+// relay streams the object's contents through this program.
+func relay(ctx context.Context, obj, to *b2.Object, attrs *b2.Attrs) error {
+	r := obj.NewReader(ctx)
+	defer r.Close()
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := to.NewWriter(wctx, b2.WithAttrsOption(&b2.Attrs{
+		ContentType: attrs.ContentType,
+		Info:        attrs.Info,
+	}))
+	if _, err := io.Copy(w, r); err != nil {
+		// Abandon the upload, rather than commit a truncated object.
+		cancel()
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
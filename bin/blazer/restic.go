@@ -0,0 +1,78 @@
+// The restic command serves a bucket over restic's REST backend protocol.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/burner-account/blazer/b2"
+	"github.com/burner-account/blazer/x/restic"
+	"github.com/burner-account/blazer/x/transport"
+	"github.com/google/subcommands"
+)
+
+type resticServer struct {
+	listen      *string
+	appendOnly  *bool
+	maxRequests *int
+	failRate    *float64
+}
+
+func (r *resticServer) Name() string     { return "restic" }
+func (r *resticServer) Synopsis() string { return "serve a restic repository over the REST protocol" }
+func (r *resticServer) Usage() string {
+	return "blazer restic [-listen addr] [-append-only] [-max-requests n] [-fail-rate r] b2://bucket/prefix/\n"
+}
+
+func (r *resticServer) SetFlags(fs *flag.FlagSet) {
+	r.listen = fs.String("listen", "localhost:8000", "the address to serve on")
+	r.appendOnly = fs.Bool("append-only", false, "refuse to delete or overwrite anything but locks")
+	r.maxRequests = fs.Int("max-requests", 0, "the most uploads, downloads, and other B2 calls each to have in flight; 0 for no limit")
+	r.failRate = fs.Float64("fail-rate", 0, "for testing, the fraction of B2 calls to fail with a 503")
+}
+
+func (r *resticServer) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "%s", r.Usage())
+		return subcommands.ExitUsageError
+	}
+	loc, err := parseLocation(f.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return subcommands.ExitUsageError
+	}
+	opts := []b2.ClientOption{
+		b2.UserAgent("blazer-restic"),
+		b2.MaxConcurrentRequests(*r.maxRequests, *r.maxRequests, *r.maxRequests),
+	}
+	if *r.failRate > 0 {
+		opts = append(opts, b2.Transport(transport.WithFailures(nil, transport.FailureRate(*r.failRate), transport.Response(503))))
+	}
+	client, bucket, st := openBucket(ctx, loc.bucket, opts...)
+	if st != subcommands.ExitSuccess {
+		return st
+	}
+
+	prefix := loc.name
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/debug/blazer", client)
+	mux.Handle("/", &restic.Handler{
+		Bucket:     bucket,
+		Prefix:     prefix,
+		AppendOnly: *r.appendOnly,
+	})
+	fmt.Fprintf(os.Stderr, "serving b2://%s/%s on %s; client status at /debug/blazer\n", loc.bucket, prefix, *r.listen)
+	if err := http.ListenAndServe(*r.listen, mux); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
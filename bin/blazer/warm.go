@@ -0,0 +1,197 @@
+// The warm command downloads objects into a local cache, within a byte budget.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/burner-account/blazer/b2"
+	"github.com/google/subcommands"
+)
+
+type warm struct {
+	dir     *string
+	workers *int
+	budget  *int64
+	names   *string
+}
+
+func (w *warm) Name() string     { return "warm" }
+func (w *warm) Synopsis() string { return "download objects ahead of need, within a byte budget" }
+func (w *warm) Usage() string {
+	return "blazer warm [-dir dir] [-workers n] [-budget bytes] [-names file] b2://bucket/prefix\n"
+}
+
+func (w *warm) SetFlags(fs *flag.FlagSet) {
+	w.dir = fs.String("dir", ".", "the directory to download into")
+	w.workers = fs.Int("workers", 4, "how many objects to download at once")
+	w.budget = fs.Int64("budget", 0, "the most bytes to download; 0 for no limit")
+	w.names = fs.String("names", "", "a file listing the objects to download, one per line and relative to the prefix, instead of every object beneath it; - for stdin")
+}
+
+// errBudget is returned for objects that would exceed the byte budget.
+var errBudget = errors.New("over budget")
+
+// warmer downloads objects into a directory.
+type warmer struct {
+	dir    string
+	budget int64 // if positive, the bytes remaining
+	spent  int64
+}
+
+// reserve claims size bytes of the budget.
+func (wm *warmer) reserve(size int64) bool {
+	if wm.budget <= 0 {
+		atomic.AddInt64(&wm.spent, size)
+		return true
+	}
+	if atomic.AddInt64(&wm.spent, size) > wm.budget {
+		atomic.AddInt64(&wm.spent, -size)
+		return false
+	}
+	return true
+}
+
+// release returns size bytes, reserved for a download that failed, to the
+// budget.
+func (wm *warmer) release(size int64) {
+	atomic.AddInt64(&wm.spent, -size)
+}
+
+// fetch downloads obj, unless a file of the same size is already there.
+func (wm *warmer) fetch(ctx context.Context, obj *b2.Object, attrs *b2.Attrs) (rerr error) {
+	if !filepath.IsLocal(obj.Name()) {
+		return fmt.Errorf("%s: not a safe local path", obj.Name())
+	}
+	path := filepath.Join(wm.dir, filepath.FromSlash(obj.Name()))
+	if fi, err := os.Stat(path); err == nil && fi.Size() == attrs.Size {
+		return nil
+	}
+	if !wm.reserve(attrs.Size) {
+		return fmt.Errorf("%s: %w", obj.Name(), errBudget)
+	}
+	defer func() {
+		if rerr != nil {
+			wm.release(attrs.Size)
+		}
+	}()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".warm-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	r := obj.NewReader(ctx)
+	defer r.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %v", obj.Name(), err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	fmt.Printf("%s (%d bytes)\n", obj.Name(), attrs.Size)
+	return nil
+}
+
+func (w *warm) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "%s", w.Usage())
+		return subcommands.ExitUsageError
+	}
+	loc, err := parseLocation(f.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return subcommands.ExitUsageError
+	}
+	_, bucket, st := openBucket(ctx, loc.bucket, b2.UserAgent("blazer"))
+	if st != subcommands.ExitSuccess {
+		return st
+	}
+
+	wm := &warmer{dir: *w.dir, budget: *w.budget}
+	// Objects over budget are skipped rather than ending the run, so that
+	// smaller objects can still fit.
+	fetch := func(obj *b2.Object, attrs *b2.Attrs) error {
+		err := wm.fetch(ctx, obj, attrs)
+		if errors.Is(err, errBudget) {
+			fmt.Fprintf(os.Stderr, "skipped %v\n", err)
+			return nil
+		}
+		return err
+	}
+	if *w.names == "" {
+		err = bucket.Walk(ctx, loc.name, fetch, b2.WalkWorkers(*w.workers), b2.WalkContinueOnError())
+	} else {
+		err = warmNames(ctx, bucket, loc.name, *w.names, *w.workers, fetch)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return subcommands.ExitFailure
+	}
+	fmt.Fprintf(os.Stderr, "downloaded %d bytes\n", atomic.LoadInt64(&wm.spent))
+	return subcommands.ExitSuccess
+}
+
+// warmNames calls fetch for every object named, after prefix, in the given
+// file, with up to workers calls at once.
+func warmNames(ctx context.Context, bucket *b2.Bucket, prefix, file string, workers int, fetch b2.WalkFunc) error {
+	in := os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	sc := bufio.NewScanner(in)
+	for sc.Scan() {
+		if sc.Text() == "" {
+			continue
+		}
+		name := prefix + sc.Text()
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			obj := bucket.Object(name)
+			attrs, err := obj.Attrs(ctx)
+			if err == nil {
+				err = fetch(obj, attrs)
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %v", name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
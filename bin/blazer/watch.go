@@ -0,0 +1,156 @@
+// The watch command prints changes to a bucket as they happen.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/burner-account/blazer/b2"
+	"github.com/burner-account/blazer/x/notify"
+	"github.com/google/subcommands"
+)
+
+type watch struct {
+	interval *time.Duration
+	initial  *bool
+}
+
+func (w *watch) Name() string     { return "watch" }
+func (w *watch) Synopsis() string { return "print changes to a bucket as they happen" }
+func (w *watch) Usage() string {
+	return "blazer watch [-interval duration] [-initial] b2://bucket/prefix\n"
+}
+
+func (w *watch) SetFlags(fs *flag.FlagSet) {
+	w.interval = fs.Duration("interval", 30*time.Second, "how often to poll the bucket")
+	w.initial = fs.Bool("initial", false, "report the versions that already exist as new")
+}
+
+// watchEvent is printed, one per line, for each change.  Its fields are named
+// as in B2's webhook notifications, so that the same tools can consume both.
+type watchEvent struct {
+	Type      string `json:"eventType"`
+	Timestamp int64  `json:"eventTimestamp"`
+	Bucket    string `json:"bucketName"`
+	Name      string `json:"objectName"`
+	Size      int64  `json:"objectSize"`
+	VersionID string `json:"objectVersionId"`
+	Replaced  bool   `json:"replaced,omitempty"` // an older version of the object exists
+}
+
+type watchVersion struct {
+	name  string
+	attrs *b2.Attrs
+}
+
+func (w *watch) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "%s", w.Usage())
+		return subcommands.ExitUsageError
+	}
+	loc, err := parseLocation(f.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return subcommands.ExitUsageError
+	}
+	_, bucket, st := openBucket(ctx, loc.bucket, b2.UserAgent("blazer"))
+	if st != subcommands.ExitSuccess {
+		return st
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	var seen map[string]watchVersion
+	for {
+		cur, err := versions(ctx, bucket, loc.name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return subcommands.ExitFailure
+		}
+		if seen != nil || *w.initial {
+			for _, e := range diff(bucket.Name(), seen, cur) {
+				if err := enc.Encode(e); err != nil {
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+					return subcommands.ExitFailure
+				}
+			}
+		}
+		seen = cur
+
+		select {
+		case <-time.After(*w.interval):
+		case <-ctx.Done():
+			return subcommands.ExitSuccess
+		}
+	}
+}
+
+// versions returns every version of every object beneath prefix, including
+// hide markers, by ID.
+func versions(ctx context.Context, bucket *b2.Bucket, prefix string) (map[string]watchVersion, error) {
+	m := make(map[string]watchVersion)
+	iter := bucket.List(ctx, b2.ListPrefix(prefix), b2.ListHidden())
+	for iter.Next() {
+		obj := iter.Object()
+		attrs, err := obj.Attrs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		m[obj.ID()] = watchVersion{name: obj.Name(), attrs: attrs}
+	}
+	return m, iter.Err()
+}
+
+// diff reports the versions in cur that are not in old, and those in old that
+// have been deleted, oldest first.
+func diff(bucket string, old, cur map[string]watchVersion) []watchEvent {
+	had := make(map[string]bool)
+	for _, v := range old {
+		had[v.name] = true
+	}
+	var events []watchEvent
+	for id, v := range cur {
+		if _, ok := old[id]; ok {
+			continue
+		}
+		e := watchEvent{
+			Type:      notify.ObjectCreatedUpload,
+			Timestamp: v.attrs.UploadTimestamp.UnixNano() / 1e6,
+			Bucket:    bucket,
+			Name:      v.name,
+			Size:      v.attrs.Size,
+			VersionID: id,
+			Replaced:  had[v.name],
+		}
+		if v.attrs.Status == b2.Hider {
+			e.Type = notify.HideMarkerCreatedHide
+			e.Size = 0
+		}
+		events = append(events, e)
+	}
+	now := time.Now().UnixNano() / 1e6
+	for id, v := range old {
+		if _, ok := cur[id]; ok {
+			continue
+		}
+		events = append(events, watchEvent{
+			Type:      notify.ObjectDeletedDelete,
+			Timestamp: now,
+			Bucket:    bucket,
+			Name:      v.name,
+			VersionID: id,
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Timestamp != events[j].Timestamp {
+			return events[i].Timestamp < events[j].Timestamp
+		}
+		return events[i].Name < events[j].Name
+	})
+	return events
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 
@@ -14,15 +15,19 @@ type superManager struct {
 	bonfire.FS
 }
 
+var s3Addr = flag.String("s3", "", "if set, also serve the S3 protocol on this address")
+
 func main() {
+	flag.Parse()
 	ctx := context.Background()
 	mux := http.NewServeMux()
 
 	fs := bonfire.FS("/tmp/b2")
 	bm := &bonfire.LocalBucket{Port: 8822}
+	tl := &bonfire.TokenLog{AccountManager: bonfire.Localhost(8822)}
 
 	if err := pyre.RegisterServerOnMux(ctx, &pyre.Server{
-		Account:   bonfire.Localhost(8822),
+		Account:   tl,
 		LargeFile: fs,
 		Bucket:    bm,
 	}, mux); err != nil {
@@ -37,7 +42,14 @@ func main() {
 
 	pyre.RegisterLargeFileManagerOnMux(fs, mux)
 	pyre.RegisterSimpleFileManagerOnMux(fs, mux)
+	pyre.RegisterCopyManagerOnMux(fs, mux)
 	pyre.RegisterDownloadManagerOnMux(sm, mux)
+	mux.Handle("/bonfire/state", &bonfire.Admin{FS: fs, Buckets: bm, Tokens: tl})
+	if *s3Addr != "" {
+		go func() {
+			fmt.Println(http.ListenAndServe(*s3Addr, &bonfire.S3{FS: fs, Buckets: bm}))
+		}()
+	}
 	fmt.Println("ok")
 	fmt.Println(http.ListenAndServe("localhost:8822", mux))
 }
@@ -0,0 +1,213 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bonfire
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/burner-account/blazer/internal/pyre"
+)
+
+// TokenLog wraps an AccountManager and records the most recent tokens it
+// issues.
+type TokenLog struct {
+	pyre.AccountManager
+
+	// Size is the number of tokens kept.  If zero, the last 100 are kept.
+	Size int
+
+	mu     sync.Mutex
+	tokens []IssuedToken // a ring
+	next   int           // where the next token goes
+	full   bool          // whether the ring has wrapped
+}
+
+// An IssuedToken is an authorization token handed out by the server.
+type IssuedToken struct {
+	Account string
+	Token   string
+	Issued  time.Time
+}
+
+func (t *TokenLog) Authorize(acct, key string) (string, error) {
+	tok, err := t.AccountManager.Authorize(acct, key)
+	if err != nil {
+		return "", err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tokens == nil {
+		n := t.Size
+		if n <= 0 {
+			n = 100
+		}
+		t.tokens = make([]IssuedToken, n)
+	}
+	t.tokens[t.next] = IssuedToken{Account: acct, Token: tok, Issued: time.Now()}
+	t.next++
+	if t.next == len(t.tokens) {
+		t.next = 0
+		t.full = true
+	}
+	return tok, nil
+}
+
+// Tokens returns the most recently issued tokens, oldest first.
+func (t *TokenLog) Tokens() []IssuedToken {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.full {
+		return append([]IssuedToken(nil), t.tokens[:t.next]...)
+	}
+	return append(append([]IssuedToken(nil), t.tokens[t.next:]...), t.tokens[:t.next]...)
+}
+
+// State is a snapshot of what a bonfire server holds.
+type State struct {
+	Buckets    []BucketState
+	LargeFiles []LargeFileState // unfinished large files
+	Tokens     []IssuedToken    // only if the Admin has a TokenLog
+}
+
+// BucketState describes a bucket and its files.
+type BucketState struct {
+	ID       string
+	Name     string
+	Versions map[string][]string // file IDs by file name, newest first
+}
+
+// LargeFileState describes a large file that has been started but not
+// finished.
+type LargeFileState struct {
+	ID       string
+	BucketID string
+	Name     string
+	Parts    []int // the part numbers uploaded so far
+}
+
+// Admin exposes the internal state of a bonfire server, so that tests can
+// check what the server holds rather than only what the B2 API reports.  It
+// serves the State as JSON on GET.
+type Admin struct {
+	FS      FS
+	Buckets *LocalBucket
+	Tokens  *TokenLog // optional
+}
+
+// State returns the server's current state.
+func (a *Admin) State() (*State, error) {
+	st := &State{}
+	a.Buckets.mux.Lock()
+	for name, id := range a.Buckets.nti {
+		if _, ok := a.Buckets.b[id]; ok {
+			st.Buckets = append(st.Buckets, BucketState{ID: id, Name: name})
+		}
+	}
+	a.Buckets.mux.Unlock()
+	sort.Slice(st.Buckets, func(i, j int) bool { return st.Buckets[i].Name < st.Buckets[j].Name })
+
+	for i := range st.Buckets {
+		b := &st.Buckets[i]
+		vers, err := a.FS.versions(b.ID)
+		if err != nil {
+			return nil, err
+		}
+		b.Versions = make(map[string][]string)
+		for name, fis := range vers {
+			for _, fi := range fis {
+				b.Versions[name] = append(b.Versions[name], fi.Name())
+			}
+		}
+	}
+
+	lfs, err := a.largeFiles()
+	if err != nil {
+		return nil, err
+	}
+	st.LargeFiles = lfs
+	if a.Tokens != nil {
+		st.Tokens = a.Tokens.Tokens()
+	}
+	return st, nil
+}
+
+// largeFiles finds the directories that FS.Start has made and FS.Finish has
+// not yet removed.
+func (a *Admin) largeFiles() ([]LargeFileState, error) {
+	dirs, err := ioutil.ReadDir(string(a.FS))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lfs []LargeFileState
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		dir := filepath.Join(string(a.FS), d.Name())
+		f, err := os.Open(filepath.Join(dir, "info"))
+		if os.IsNotExist(err) {
+			continue // a bucket
+		}
+		if err != nil {
+			return nil, err
+		}
+		var info fi
+		err = json.NewDecoder(f).Decode(&info)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		lf := LargeFileState{ID: d.Name(), BucketID: info.Bucket, Name: info.Name}
+		parts, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parts {
+			if n, err := strconv.Atoi(p.Name()); err == nil {
+				lf.Parts = append(lf.Parts, n)
+			}
+		}
+		sort.Ints(lf.Parts)
+		lfs = append(lfs, lf)
+	}
+	return lfs, nil
+}
+
+func (a *Admin) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	st, err := a.State()
+	if err != nil {
+		http.Error(rw, err.Error(), 500)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(rw)
+	enc.SetIndent("", "  ")
+	enc.Encode(st)
+}
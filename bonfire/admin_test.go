@@ -0,0 +1,100 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bonfire
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAdminState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bonfire")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs := FS(dir)
+	bm := &LocalBucket{}
+	if err := bm.AddBucket("bid", "fixtures", nil); err != nil {
+		t.Fatal(err)
+	}
+	tl := &TokenLog{AccountManager: Localhost(8822)}
+	if _, err := tl.Authorize("acct", "key"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"v1", "v2"} {
+		w, err := fs.Writer("bid", "a/b", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+		time.Sleep(10 * time.Millisecond) // order versions by mtime
+	}
+	if err := fs.Start("bid", "big", "lf1", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{2, 1} {
+		w, err := fs.PartWriter("lf1", n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+	}
+
+	a := &Admin{FS: fs, Buckets: bm, Tokens: tl}
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest("GET", "/bonfire/state", nil))
+	if rec.Code != 200 {
+		t.Fatalf("got status %d", rec.Code)
+	}
+	st := &State{}
+	if err := json.NewDecoder(rec.Body).Decode(st); err != nil {
+		t.Fatal(err)
+	}
+
+	wantBuckets := []BucketState{{ID: "bid", Name: "fixtures", Versions: map[string][]string{"a/b": {"v2", "v1"}}}}
+	if !reflect.DeepEqual(st.Buckets, wantBuckets) {
+		t.Errorf("Buckets: got %+v, want %+v", st.Buckets, wantBuckets)
+	}
+	wantLFs := []LargeFileState{{ID: "lf1", BucketID: "bid", Name: "big", Parts: []int{1, 2}}}
+	if !reflect.DeepEqual(st.LargeFiles, wantLFs) {
+		t.Errorf("LargeFiles: got %+v, want %+v", st.LargeFiles, wantLFs)
+	}
+	if len(st.Tokens) != 1 || st.Tokens[0].Account != "acct" || st.Tokens[0].Token != "ok" {
+		t.Errorf("Tokens: got %+v", st.Tokens)
+	}
+}
+
+func TestTokenLogSize(t *testing.T) {
+	tl := &TokenLog{AccountManager: Localhost(8822), Size: 2}
+	for _, acct := range []string{"a", "b", "c"} {
+		if _, err := tl.Authorize(acct, "key"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for _, tok := range tl.Tokens() {
+		got = append(got, tok.Account)
+	}
+	if want := []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokens: got accounts %v, want %v", got, want)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/burner-account/blazer/internal/pyre"
@@ -160,6 +161,77 @@ func (f FS) ObjectByName(bucket, name string) (pyre.DownloadableObject, error) {
 	}, nil
 }
 
+// versions returns every version of every finished file in the bucket, newest
+// first.
+func (f FS) versions(bucketID string) (map[string][]os.FileInfo, error) {
+	root := filepath.Join(string(f), bucketID)
+	vers := make(map[string][]os.FileInfo)
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		vers[name] = append(vers[name], info)
+		return nil
+	})
+	for _, fis := range vers {
+		sort.Slice(fis, func(i, j int) bool { return fis[i].ModTime().After(fis[j].ModTime()) })
+	}
+	return vers, err
+}
+
+var errFound = errors.New("found")
+
+// ObjectByID finds a finished file by ID.  Files are stored by name, so this
+// has to search the whole tree.
+func (f FS) ObjectByID(fileID string) (string, string, pyre.DownloadableObject, error) {
+	var path string
+	err := filepath.Walk(string(f), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == fileID {
+			return filepath.SkipDir // an unfinished large file
+		}
+		if !info.IsDir() && info.Name() == fileID {
+			path = p
+			return errFound
+		}
+		return nil
+	})
+	if err != errFound {
+		if err == nil {
+			err = fmt.Errorf("%s: no such file", fileID)
+		}
+		return "", "", nil, err
+	}
+	rel, err := filepath.Rel(string(f), filepath.Dir(path))
+	if err != nil {
+		return "", "", nil, err
+	}
+	parts := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+	if len(parts) != 2 {
+		return "", "", nil, fmt.Errorf("%s: not a file", fileID)
+	}
+	o, err := os.Open(path)
+	if err != nil {
+		return "", "", nil, err
+	}
+	st, err := o.Stat()
+	if err != nil {
+		o.Close()
+		return "", "", nil, err
+	}
+	return parts[0], parts[1], do{o: o, size: st.Size()}, nil
+}
+
 type do struct {
 	size int64
 	o    *os.File
@@ -0,0 +1,302 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bonfire
+
+import (
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// S3 serves a subset of the Amazon S3 protocol from the same buckets and
+// files as the B2 API, so that S3 clients can be tested against the same
+// fixtures as B2 clients.  Only path-style requests are understood, and
+// request signatures are not checked.
+//
+// The supported operations are ListBuckets, ListObjectsV2, GetObject (with a
+// single Range), HeadObject, PutObject, and DeleteObject.
+type S3 struct {
+	FS      FS
+	Buckets *LocalBucket
+}
+
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func s3Fail(rw http.ResponseWriter, status int, code, msg string) {
+	rw.Header().Set("Content-Type", "application/xml")
+	rw.WriteHeader(status)
+	xml.NewEncoder(rw).Encode(s3Error{Code: code, Message: msg})
+}
+
+func s3Write(rw http.ResponseWriter, v interface{}) {
+	rw.Header().Set("Content-Type", "application/xml")
+	io.WriteString(rw, xml.Header)
+	if err := xml.NewEncoder(rw).Encode(v); err != nil {
+		fmt.Println("s3:", err)
+	}
+}
+
+func (s *S3) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			s3Fail(rw, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+			return
+		}
+		s.listBuckets(rw)
+		return
+	}
+	parts := strings.SplitN(path, "/", 2)
+	bucket := parts[0]
+	id, err := s.Buckets.GetBucketID(bucket)
+	if err != nil {
+		s3Fail(rw, http.StatusNotFound, "NoSuchBucket", bucket)
+		return
+	}
+	if len(parts) == 1 || parts[1] == "" {
+		if r.Method != http.MethodGet {
+			s3Fail(rw, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+			return
+		}
+		s.listObjects(rw, r, bucket, id)
+		return
+	}
+	key := parts[1]
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.getObject(rw, r, id, key)
+	case http.MethodPut:
+		s.putObject(rw, r, id, key)
+	case http.MethodDelete:
+		s.deleteObject(rw, id, key)
+	default:
+		s3Fail(rw, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+type s3Bucket struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type s3ListBucketsResult struct {
+	XMLName xml.Name   `xml:"ListAllMyBucketsResult"`
+	Buckets []s3Bucket `xml:"Buckets>Bucket"`
+}
+
+func (s *S3) listBuckets(rw http.ResponseWriter) {
+	s.Buckets.mux.Lock()
+	var names []string
+	for name, id := range s.Buckets.nti {
+		if _, ok := s.Buckets.b[id]; ok {
+			names = append(names, name)
+		}
+	}
+	s.Buckets.mux.Unlock()
+	sort.Strings(names)
+	res := s3ListBucketsResult{}
+	for _, name := range names {
+		res.Buckets = append(res.Buckets, s3Bucket{Name: name, CreationDate: time.Time{}.Format(time.RFC3339)})
+	}
+	s3Write(rw, res)
+}
+
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	Size         int64  `xml:"Size"`
+}
+
+type s3Prefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type s3ListObjectsResult struct {
+	XMLName               xml.Name   `xml:"ListBucketResult"`
+	Name                  string     `xml:"Name"`
+	Prefix                string     `xml:"Prefix"`
+	Delimiter             string     `xml:"Delimiter,omitempty"`
+	MaxKeys               int        `xml:"MaxKeys"`
+	KeyCount              int        `xml:"KeyCount"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken,omitempty"`
+	Contents              []s3Object `xml:"Contents"`
+	CommonPrefixes        []s3Prefix `xml:"CommonPrefixes"`
+}
+
+// objects returns the names of the finished files in the bucket, with their
+// newest version.
+func (s *S3) objects(bucketID string) (map[string]os.FileInfo, error) {
+	vers, err := s.FS.versions(bucketID)
+	if err != nil {
+		return nil, err
+	}
+	objs := make(map[string]os.FileInfo)
+	for name, fis := range vers {
+		objs[name] = fis[0]
+	}
+	return objs, nil
+}
+
+func (s *S3) listObjects(rw http.ResponseWriter, r *http.Request, bucket, id string) {
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	delim := q.Get("delimiter")
+	start := q.Get("continuation-token")
+	if start == "" {
+		start = q.Get("start-after")
+	}
+	max := 1000
+	if m := q.Get("max-keys"); m != "" {
+		n, err := strconv.Atoi(m)
+		if err != nil || n < 0 {
+			s3Fail(rw, http.StatusBadRequest, "InvalidArgument", "max-keys")
+			return
+		}
+		max = n
+	}
+	objs, err := s.objects(id)
+	if err != nil {
+		s3Fail(rw, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	var names []string
+	for name := range objs {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	res := s3ListObjectsResult{
+		Name:      bucket,
+		Prefix:    prefix,
+		Delimiter: delim,
+		MaxKeys:   max,
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if name <= start {
+			continue
+		}
+		entry := name
+		if delim != "" {
+			if i := strings.Index(name[len(prefix):], delim); i >= 0 {
+				entry = name[:len(prefix)+i+len(delim)]
+				if seen[entry] || entry <= start {
+					continue
+				}
+			}
+		}
+		if res.KeyCount == max {
+			res.IsTruncated = true
+			break
+		}
+		res.KeyCount++
+		res.NextContinuationToken = entry
+		if entry != name {
+			seen[entry] = true
+			res.CommonPrefixes = append(res.CommonPrefixes, s3Prefix{Prefix: entry})
+			continue
+		}
+		fi := objs[name]
+		res.Contents = append(res.Contents, s3Object{
+			Key:          name,
+			LastModified: fi.ModTime().UTC().Format(time.RFC3339),
+			Size:         fi.Size(),
+		})
+	}
+	if !res.IsTruncated {
+		res.NextContinuationToken = ""
+	}
+	s3Write(rw, res)
+}
+
+func (s *S3) getObject(rw http.ResponseWriter, r *http.Request, id, key string) {
+	objs, err := s.objects(id)
+	if err != nil {
+		s3Fail(rw, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	fi, ok := objs[key]
+	if !ok {
+		s3Fail(rw, http.StatusNotFound, "NoSuchKey", key)
+		return
+	}
+	f, err := os.Open(filepath.Join(string(s.FS), id, filepath.FromSlash(key), fi.Name()))
+	if err != nil {
+		s3Fail(rw, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	defer f.Close()
+	rw.Header().Set("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
+	rw.Header().Set("x-amz-version-id", fi.Name())
+	http.ServeContent(rw, r, "", fi.ModTime(), f)
+}
+
+// deleteObject removes every version of key, but not the objects whose names
+// merely begin with key and "/".
+func (s *S3) deleteObject(rw http.ResponseWriter, id, key string) {
+	dir := filepath.Join(string(s.FS), id, filepath.FromSlash(key))
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		s3Fail(rw, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	for _, fi := range fis {
+		if fi.IsDir() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, fi.Name())); err != nil {
+			s3Fail(rw, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (s *S3) putObject(rw http.ResponseWriter, r *http.Request, id, key string) {
+	w, err := s.FS.Writer(id, key, uuid.New().String())
+	if err != nil {
+		s3Fail(rw, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	h := md5.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), r.Body); err != nil {
+		w.Close()
+		s3Fail(rw, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	if err := w.Close(); err != nil {
+		s3Fail(rw, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	rw.Header().Set("ETag", fmt.Sprintf("%q", fmt.Sprintf("%x", h.Sum(nil))))
+	rw.WriteHeader(http.StatusOK)
+}
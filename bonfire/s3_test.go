@@ -0,0 +1,137 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bonfire
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestS3(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bonfire")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bm := &LocalBucket{}
+	if err := bm.AddBucket("bid", "fixtures", nil); err != nil {
+		t.Fatal(err)
+	}
+	fs := FS(dir)
+	// A file written through the B2 API.
+	w, err := fs.Writer("bid", "b2/native", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("from b2"))
+	w.Close()
+
+	srv := httptest.NewServer(&S3{FS: fs, Buckets: bm})
+	defer srv.Close()
+
+	do := func(method, path, body string, hdr ...string) (int, string) {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i+1 < len(hdr); i += 2 {
+			req.Header.Set(hdr[i], hdr[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(b)
+	}
+
+	if code, _ := do("PUT", "/fixtures/s3/a.txt", "from s3"); code != 200 {
+		t.Fatalf("PUT: got %d", code)
+	}
+	if code, _ := do("PUT", "/fixtures/s3/b.txt", "bbb"); code != 200 {
+		t.Fatalf("PUT: got %d", code)
+	}
+
+	table := []struct {
+		method, path string
+		hdr          []string
+		code         int
+		body         string
+	}{
+		{method: "GET", path: "/fixtures/b2/native", code: 200, body: "from b2"},
+		{method: "GET", path: "/fixtures/s3/a.txt", code: 200, body: "from s3"},
+		{method: "GET", path: "/fixtures/s3/a.txt", hdr: []string{"Range", "bytes=5-6"}, code: 206, body: "s3"},
+		{method: "GET", path: "/fixtures/nope", code: 404},
+		{method: "GET", path: "/nobucket/a", code: 404},
+	}
+	for _, e := range table {
+		code, body := do(e.method, e.path, "", e.hdr...)
+		if code != e.code || (e.body != "" && body != e.body) {
+			t.Errorf("%s %s: got %d %q, want %d %q", e.method, e.path, code, body, e.code, e.body)
+		}
+	}
+
+	list := func(query string) s3ListObjectsResult {
+		code, body := do("GET", "/fixtures?"+query, "")
+		if code != 200 {
+			t.Fatalf("list %s: got %d", query, code)
+		}
+		var res s3ListObjectsResult
+		if err := xml.Unmarshal([]byte(body), &res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	keys := func(res s3ListObjectsResult) string {
+		var l []string
+		for _, p := range res.CommonPrefixes {
+			l = append(l, p.Prefix)
+		}
+		for _, o := range res.Contents {
+			l = append(l, o.Key)
+		}
+		return strings.Join(l, ",")
+	}
+	if got, want := keys(list("list-type=2")), "b2/native,s3/a.txt,s3/b.txt"; got != want {
+		t.Errorf("list: got %q, want %q", got, want)
+	}
+	if got, want := keys(list("list-type=2&delimiter=/")), "b2/,s3/"; got != want {
+		t.Errorf("list with delimiter: got %q, want %q", got, want)
+	}
+	res := list("list-type=2&prefix=s3/&max-keys=1")
+	if got, want := keys(res), "s3/a.txt"; got != want || !res.IsTruncated {
+		t.Errorf("first page: got %q (truncated %v), want %q", got, res.IsTruncated, want)
+	}
+	if got, want := keys(list("list-type=2&prefix=s3/&continuation-token="+res.NextContinuationToken)), "s3/b.txt"; got != want {
+		t.Errorf("second page: got %q, want %q", got, want)
+	}
+
+	if code, _ := do("DELETE", "/fixtures/s3/a.txt", ""); code != 204 {
+		t.Errorf("DELETE: got %d", code)
+	}
+	if code, _ := do("GET", "/fixtures/s3/a.txt", ""); code != 404 {
+		t.Errorf("GET after DELETE: got %d", code)
+	}
+}
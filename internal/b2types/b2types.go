@@ -20,8 +20,66 @@ package b2types
 
 const (
 	V1api = "/b2api/v1/"
+	V2api = "/b2api/v2/"
+	V3api = "/b2api/v3/"
 )
 
+// Capability is a permission that an application key can grant.
+type Capability string
+
+const (
+	ListKeys                 Capability = "listKeys"
+	WriteKeys                Capability = "writeKeys"
+	DeleteKeys               Capability = "deleteKeys"
+	ListBuckets              Capability = "listBuckets"
+	ListAllBucketNames       Capability = "listAllBucketNames"
+	ReadBuckets              Capability = "readBuckets"
+	WriteBuckets             Capability = "writeBuckets"
+	DeleteBuckets            Capability = "deleteBuckets"
+	ReadBucketRetentions     Capability = "readBucketRetentions"
+	WriteBucketRetentions    Capability = "writeBucketRetentions"
+	ReadBucketEncryption     Capability = "readBucketEncryption"
+	WriteBucketEncryption    Capability = "writeBucketEncryption"
+	ReadBucketReplications   Capability = "readBucketReplications"
+	WriteBucketReplications  Capability = "writeBucketReplications"
+	ReadBucketNotifications  Capability = "readBucketNotifications"
+	WriteBucketNotifications Capability = "writeBucketNotifications"
+	ListFiles                Capability = "listFiles"
+	ReadFiles                Capability = "readFiles"
+	ShareFiles               Capability = "shareFiles"
+	WriteFiles               Capability = "writeFiles"
+	DeleteFiles              Capability = "deleteFiles"
+	ReadFileLegalHolds       Capability = "readFileLegalHolds"
+	WriteFileLegalHolds      Capability = "writeFileLegalHolds"
+	ReadFileRetentions       Capability = "readFileRetentions"
+	WriteFileRetentions      Capability = "writeFileRetentions"
+	BypassGovernance         Capability = "bypassGovernance"
+)
+
+// Capabilities lists every capability that B2 understands.
+var Capabilities = []Capability{
+	ListKeys, WriteKeys, DeleteKeys,
+	ListBuckets, ListAllBucketNames, ReadBuckets, WriteBuckets, DeleteBuckets,
+	ReadBucketRetentions, WriteBucketRetentions,
+	ReadBucketEncryption, WriteBucketEncryption,
+	ReadBucketReplications, WriteBucketReplications,
+	ReadBucketNotifications, WriteBucketNotifications,
+	ListFiles, ReadFiles, ShareFiles, WriteFiles, DeleteFiles,
+	ReadFileLegalHolds, WriteFileLegalHolds,
+	ReadFileRetentions, WriteFileRetentions,
+	BypassGovernance,
+}
+
+// Valid reports whether c is one of the known capabilities.
+func (c Capability) Valid() bool {
+	for _, k := range Capabilities {
+		if c == k {
+			return true
+		}
+	}
+	return false
+}
+
 type ErrorMessage struct {
 	Status int    `json:"status"`
 	Code   string `json:"code"`
@@ -33,6 +91,7 @@ type AuthorizeAccountResponse struct {
 	AuthToken      string    `json:"authorizationToken"`
 	URI            string    `json:"apiUrl"`
 	DownloadURI    string    `json:"downloadUrl"`
+	S3URI          string    `json:"s3ApiUrl"`
 	MinPartSize    int       `json:"minimumPartSize"`
 	PartSize       int       `json:"recommendedPartSize"`
 	AbsMinPartSize int       `json:"absoluteMinimumPartSize"`
@@ -42,13 +101,128 @@ type AuthorizeAccountResponse struct {
 type Allowance struct {
 	Capabilities []string `json:"capabilities"`
 	Bucket       string   `json:"bucketId"`
+	BucketName   string   `json:"bucketName"`
 	Prefix       string   `json:"namePrefix"`
 }
 
+// AuthorizeAccountV3Response is the v3 form of b2_authorize_account's reply,
+// which moves the endpoints and allowance into apiInfo.
+type AuthorizeAccountV3Response struct {
+	AccountID string  `json:"accountId"`
+	AuthToken string  `json:"authorizationToken"`
+	Expires   *int64  `json:"applicationKeyExpirationTimestamp"` // null if the key does not expire
+	APIInfo   APIInfo `json:"apiInfo"`
+}
+
+type APIInfo struct {
+	Storage StorageAPIInfo `json:"storageApi"`
+}
+
+type StorageAPIInfo struct {
+	InfoType       string   `json:"infoType"`
+	URI            string   `json:"apiUrl"`
+	DownloadURI    string   `json:"downloadUrl"`
+	S3URI          string   `json:"s3ApiUrl"`
+	PartSize       int      `json:"recommendedPartSize"`
+	AbsMinPartSize int      `json:"absoluteMinimumPartSize"`
+	Capabilities   []string `json:"capabilities"`
+
+	// These are null if the key is not restricted to a bucket or prefix.
+	Bucket     *string `json:"bucketId"`
+	BucketName *string `json:"bucketName"`
+	Prefix     *string `json:"namePrefix"`
+}
+
 type LifecycleRule struct {
-	DaysHiddenUntilDeleted int    `json:"daysFromHidingToDeleting,omitempty"`
-	DaysNewUntilHidden     int    `json:"daysFromUploadingToHiding,omitempty"`
-	Prefix                 string `json:"fileNamePrefix"`
+	DaysHiddenUntilDeleted   int    `json:"daysFromHidingToDeleting,omitempty"`
+	DaysNewUntilHidden       int    `json:"daysFromUploadingToHiding,omitempty"`
+	DaysStartedUntilCanceled int    `json:"daysFromStartingToCancelingUnfinishedLargeFiles,omitempty"`
+	Prefix                   string `json:"fileNamePrefix"`
+}
+
+type CORSRule struct {
+	Name              string   `json:"corsRuleName"`
+	AllowedOrigins    []string `json:"allowedOrigins"`
+	AllowedOperations []string `json:"allowedOperations"`
+	AllowedHeaders    []string `json:"allowedHeaders,omitempty"`
+	ExposeHeaders     []string `json:"exposeHeaders,omitempty"`
+	MaxAgeSeconds     int      `json:"maxAgeSeconds"`
+}
+
+// RetentionPeriod is a duration in days or years.
+type RetentionPeriod struct {
+	Duration int    `json:"duration"`
+	Unit     string `json:"unit"` // "days" or "years"
+}
+
+// DefaultRetention is the retention applied to new files in a bucket with
+// file lock enabled.  A null mode means none.
+type DefaultRetention struct {
+	Mode   *string          `json:"mode"` // "governance" or "compliance"
+	Period *RetentionPeriod `json:"period,omitempty"`
+}
+
+type FileLockConfiguration struct {
+	IsClientAuthorizedToRead bool                 `json:"isClientAuthorizedToRead"`
+	Value                    *FileLockConfigValue `json:"value"`
+}
+
+type FileLockConfigValue struct {
+	Enabled          bool             `json:"isFileLockEnabled"`
+	DefaultRetention DefaultRetention `json:"defaultRetention"`
+}
+
+// ServerSideEncryption describes how a file is, or is to be, encrypted.  The
+// customer key fields are only used with SSE-C.
+type ServerSideEncryption struct {
+	Mode           string `json:"mode,omitempty"`      // "SSE-B2" or "SSE-C"
+	Algorithm      string `json:"algorithm,omitempty"` // "AES256"
+	CustomerKey    string `json:"customerKey,omitempty"`
+	CustomerKeyMD5 string `json:"customerKeyMd5,omitempty"`
+}
+
+type DefaultServerSideEncryption struct {
+	IsClientAuthorizedToRead bool                  `json:"isClientAuthorizedToRead"`
+	Value                    *ServerSideEncryption `json:"value"`
+}
+
+// DefaultEncryptionSetting sets a bucket's default encryption.  Unlike
+// ServerSideEncryption, its mode is always sent: a null mode turns default
+// encryption off.
+type DefaultEncryptionSetting struct {
+	Mode      *string `json:"mode"`
+	Algorithm string  `json:"algorithm,omitempty"`
+}
+
+type ReplicationRule struct {
+	Name                 string `json:"replicationRuleName"`
+	DestinationBucketID  string `json:"destinationBucketId"`
+	Prefix               string `json:"fileNamePrefix"`
+	IncludeExistingFiles bool   `json:"includeExistingFiles"`
+	Enabled              bool   `json:"isEnabled"`
+	Priority             int    `json:"priority"`
+}
+
+type ReplicationSource struct {
+	Rules       []ReplicationRule `json:"replicationRules"`
+	SourceKeyID string            `json:"sourceApplicationKeyId"`
+}
+
+type ReplicationDestination struct {
+	// Maps each source key ID to the destination key ID it writes with.
+	KeyMapping map[string]string `json:"sourceToDestinationKeyMapping"`
+}
+
+type ReplicationConfiguration struct {
+	AsSource      *ReplicationSource      `json:"asReplicationSource,omitempty"`
+	AsDestination *ReplicationDestination `json:"asReplicationDestination,omitempty"`
+}
+
+// ReplicationConfigurationResponse is the form in which buckets report their
+// replication configuration.
+type ReplicationConfigurationResponse struct {
+	IsClientAuthorizedToRead bool                      `json:"isClientAuthorizedToRead"`
+	Value                    *ReplicationConfiguration `json:"value"`
 }
 
 type CreateBucketRequest struct {
@@ -57,15 +231,27 @@ type CreateBucketRequest struct {
 	Type           string            `json:"bucketType"`
 	Info           map[string]string `json:"bucketInfo"`
 	LifecycleRules []LifecycleRule   `json:"lifecycleRules"`
+
+	CORSRules         []CORSRule                `json:"corsRules,omitempty"`
+	FileLockEnabled   bool                      `json:"fileLockEnabled,omitempty"`
+	Replication       *ReplicationConfiguration `json:"replicationConfiguration,omitempty"`
+	DefaultEncryption *DefaultEncryptionSetting `json:"defaultServerSideEncryption,omitempty"`
 }
 
 type CreateBucketResponse struct {
+	AccountID      string            `json:"accountId"`
 	BucketID       string            `json:"bucketId"`
 	Name           string            `json:"bucketName"`
 	Type           string            `json:"bucketType"`
 	Info           map[string]string `json:"bucketInfo"`
 	LifecycleRules []LifecycleRule   `json:"lifecycleRules"`
 	Revision       int               `json:"revision"`
+
+	CORSRules         []CORSRule                        `json:"corsRules"`
+	FileLock          *FileLockConfiguration            `json:"fileLockConfiguration"`
+	DefaultEncryption *DefaultServerSideEncryption      `json:"defaultServerSideEncryption"`
+	Replication       *ReplicationConfigurationResponse `json:"replicationConfiguration"`
+	Options           []string                          `json:"options"`
 }
 
 type DeleteBucketRequest struct {
@@ -74,22 +260,32 @@ type DeleteBucketRequest struct {
 }
 
 type ListBucketsRequest struct {
-	AccountID string `json:"accountId"`
-	Bucket    string `json:"bucketId,omitempty"`
-	Name      string `json:"bucketName,omitempty"`
+	AccountID string   `json:"accountId"`
+	Bucket    string   `json:"bucketId,omitempty"`
+	Name      string   `json:"bucketName,omitempty"`
+	Types     []string `json:"bucketTypes,omitempty"`
 }
 
 type ListBucketsResponse struct {
 	Buckets []CreateBucketResponse `json:"buckets"`
 }
 
+// UpdateBucketRequest leaves out the fields that are nil.  The info and rules
+// are pointers so that an empty map or list, which removes every entry, is
+// still sent.
 type UpdateBucketRequest struct {
-	AccountID      string            `json:"accountId"`
-	BucketID       string            `json:"bucketId"`
-	Type           string            `json:"bucketType,omitempty"`
-	Info           map[string]string `json:"bucketInfo,omitempty"`
-	LifecycleRules []LifecycleRule   `json:"lifecycleRules,omitempty"`
-	IfRevisionIs   int               `json:"ifRevisionIs,omitempty"`
+	AccountID      string             `json:"accountId"`
+	BucketID       string             `json:"bucketId"`
+	Type           string             `json:"bucketType,omitempty"`
+	Info           *map[string]string `json:"bucketInfo,omitempty"`
+	LifecycleRules *[]LifecycleRule   `json:"lifecycleRules,omitempty"`
+	IfRevisionIs   int                `json:"ifRevisionIs,omitempty"`
+
+	CORSRules         *[]CORSRule               `json:"corsRules,omitempty"`
+	DefaultRetention  *DefaultRetention         `json:"defaultRetention,omitempty"`
+	DefaultEncryption *DefaultEncryptionSetting `json:"defaultServerSideEncryption,omitempty"`
+	FileLockEnabled   bool                      `json:"fileLockEnabled,omitempty"`
+	Replication       *ReplicationConfiguration `json:"replicationConfiguration,omitempty"`
 }
 
 type UpdateBucketResponse CreateBucketResponse
@@ -105,6 +301,43 @@ type GetUploadURLResponse struct {
 
 type UploadFileResponse GetFileInfoResponse
 
+type CopyFileRequest struct {
+	SourceID          string            `json:"sourceFileId"`
+	DestBucketID      string            `json:"destinationBucketId,omitempty"`
+	Name              string            `json:"fileName"`
+	Range             string            `json:"range,omitempty"`
+	MetadataDirective string            `json:"metadataDirective,omitempty"`
+	ContentType       string            `json:"contentType,omitempty"`
+	Info              map[string]string `json:"fileInfo,omitempty"`
+
+	Retention        *FileRetention        `json:"fileRetention,omitempty"`
+	LegalHold        string                `json:"legalHold,omitempty"`
+	SourceEncryption *ServerSideEncryption `json:"sourceServerSideEncryption,omitempty"`
+	DestEncryption   *ServerSideEncryption `json:"destinationServerSideEncryption,omitempty"`
+}
+
+type CopyFileResponse GetFileInfoResponse
+
+type CopyPartRequest struct {
+	SourceID    string `json:"sourceFileId"`
+	LargeFileID string `json:"largeFileId"`
+	PartNumber  int    `json:"partNumber"`
+	Range       string `json:"range,omitempty"`
+
+	SourceEncryption *ServerSideEncryption `json:"sourceServerSideEncryption,omitempty"`
+	DestEncryption   *ServerSideEncryption `json:"destinationServerSideEncryption,omitempty"`
+}
+
+type CopyPartResponse struct {
+	FileID     string                `json:"fileId"`
+	PartNumber int                   `json:"partNumber"`
+	Size       int64                 `json:"contentLength"`
+	SHA1       string                `json:"contentSha1"`
+	MD5        string                `json:"contentMd5,omitempty"`
+	Encryption *ServerSideEncryption `json:"serverSideEncryption,omitempty"`
+	Timestamp  int64                 `json:"uploadTimestamp,omitempty"`
+}
+
 type DeleteFileVersionRequest struct {
 	Name   string `json:"fileName"`
 	FileID string `json:"fileId"`
@@ -115,6 +348,10 @@ type StartLargeFileRequest struct {
 	Name        string            `json:"fileName"`
 	ContentType string            `json:"contentType"`
 	Info        map[string]string `json:"fileInfo,omitempty"`
+
+	Retention  *FileRetention        `json:"fileRetention,omitempty"`
+	LegalHold  string                `json:"legalHold,omitempty"`
+	Encryption *ServerSideEncryption `json:"serverSideEncryption,omitempty"`
 }
 
 type StartLargeFileResponse struct {
@@ -217,8 +454,54 @@ type GetFileInfoResponse struct {
 	Info        map[string]string `json:"fileInfo,omitempty"`
 	Action      string            `json:"action,omitempty"`
 	Timestamp   int64             `json:"uploadTimestamp,omitempty"`
+
+	Retention         *FileRetentionSetting `json:"fileRetention,omitempty"`
+	LegalHold         *LegalHoldSetting     `json:"legalHold,omitempty"`
+	Encryption        *ServerSideEncryption `json:"serverSideEncryption,omitempty"`
+	ReplicationStatus string                `json:"replicationStatus,omitempty"`
+}
+
+// FileRetention is the retention of a file version.  A null mode means none.
+type FileRetention struct {
+	Mode        *string `json:"mode"` // "governance" or "compliance"
+	RetainUntil *int64  `json:"retainUntilTimestamp"`
+}
+
+// FileRetentionSetting is a file's retention as reported by B2, which omits
+// the value if the caller may not read it.
+type FileRetentionSetting struct {
+	IsClientAuthorizedToRead bool           `json:"isClientAuthorizedToRead"`
+	Value                    *FileRetention `json:"value"`
+}
+
+// LegalHoldSetting is a file's legal hold as reported by B2: "on", "off", or
+// null.
+type LegalHoldSetting struct {
+	IsClientAuthorizedToRead bool    `json:"isClientAuthorizedToRead"`
+	Value                    *string `json:"value"`
+}
+
+type UpdateFileRetentionRequest struct {
+	Name             string        `json:"fileName"`
+	FileID           string        `json:"fileId"`
+	Retention        FileRetention `json:"fileRetention"`
+	BypassGovernance bool          `json:"bypassGovernance,omitempty"`
+}
+
+type UpdateFileRetentionResponse struct {
+	Name      string        `json:"fileName"`
+	FileID    string        `json:"fileId"`
+	Retention FileRetention `json:"fileRetention"`
+}
+
+type UpdateFileLegalHoldRequest struct {
+	Name      string `json:"fileName"`
+	FileID    string `json:"fileId"`
+	LegalHold string `json:"legalHold"` // "on" or "off"
 }
 
+type UpdateFileLegalHoldResponse UpdateFileLegalHoldRequest
+
 type GetDownloadAuthorizationRequest struct {
 	BucketID           string `json:"bucketId"`
 	Prefix             string `json:"fileNamePrefix"`
@@ -243,6 +526,10 @@ type ListUnfinishedLargeFilesResponse struct {
 	Continuation string                `json:"nextFileId"`
 }
 
+type DownloadFileByIDRequest struct {
+	FileID string `json:"fileId"`
+}
+
 type CreateKeyRequest struct {
 	AccountID    string   `json:"accountId"`
 	Capabilities []string `json:"capabilities"`
@@ -261,6 +548,7 @@ type Key struct {
 	Expires      int64    `json:"expirationTimestamp"`
 	BucketID     string   `json:"bucketId"`
 	Prefix       string   `json:"namePrefix"`
+	Options      []string `json:"options,omitempty"`
 }
 
 type CreateKeyResponse Key
@@ -281,3 +569,38 @@ type ListKeysResponse struct {
 	Keys []Key  `json:"keys"`
 	Next string `json:"nextApplicationKeyId"`
 }
+
+type GetBucketNotificationRulesRequest struct {
+	BucketID string `json:"bucketId"`
+}
+
+type NotificationRule struct {
+	Name             string             `json:"name"`
+	EventTypes       []string           `json:"eventTypes"`
+	Enabled          bool               `json:"isEnabled"`
+	Prefix           string             `json:"objectNamePrefix"`
+	Target           NotificationTarget `json:"targetConfiguration"`
+	Suspended        bool               `json:"isSuspended,omitempty"`
+	SuspensionReason string             `json:"suspensionReason,omitempty"`
+}
+
+type NotificationTarget struct {
+	Type          string         `json:"targetType"` // "webhook"
+	URL           string         `json:"url"`
+	CustomHeaders []CustomHeader `json:"customHeaders,omitempty"`
+	SigningSecret string         `json:"hmacSha256SigningSecret,omitempty"`
+}
+
+type CustomHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type GetBucketNotificationRulesResponse struct {
+	BucketID string             `json:"bucketId"`
+	Rules    []NotificationRule `json:"eventNotificationRules"`
+}
+
+type SetBucketNotificationRulesRequest GetBucketNotificationRulesResponse
+
+type SetBucketNotificationRulesResponse GetBucketNotificationRulesResponse
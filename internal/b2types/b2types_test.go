@@ -0,0 +1,337 @@
+// Copyright 2016, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b2types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// The samples are taken from the examples in B2's API documentation, with
+// identifiers shortened.  Each should decode into its type and encode back to
+// the same JSON, so that no field is misnamed or lost on the way through.
+func TestRoundTrip(t *testing.T) {
+	table := []struct {
+		name string
+		v    interface{}
+		json string
+	}{
+		{
+			name: "b2_authorize_account v3 response",
+			v:    &AuthorizeAccountV3Response{},
+			json: `{
+				"accountId": "30f20426f0b1",
+				"apiInfo": {
+					"storageApi": {
+						"absoluteMinimumPartSize": 5000000,
+						"apiUrl": "https://api000.backblazeb2.com",
+						"bucketId": null,
+						"bucketName": null,
+						"capabilities": ["listKeys", "writeKeys", "deleteKeys", "listBuckets", "readFiles"],
+						"downloadUrl": "https://f000.backblazeb2.com",
+						"infoType": "storageApi",
+						"namePrefix": null,
+						"recommendedPartSize": 100000000,
+						"s3ApiUrl": "https://s3.us-west-000.backblazeb2.com"
+					}
+				},
+				"applicationKeyExpirationTimestamp": null,
+				"authorizationToken": "4_0022623512fc8f80000000001_0186e431_d18d02_acct_tH7VW03boebOXayIc43-sxptpfA="
+			}`,
+		},
+		{
+			name: "b2_authorize_account v3 response for a restricted key",
+			v:    &AuthorizeAccountV3Response{},
+			json: `{
+				"accountId": "30f20426f0b1",
+				"apiInfo": {
+					"storageApi": {
+						"absoluteMinimumPartSize": 5000000,
+						"apiUrl": "https://api000.backblazeb2.com",
+						"bucketId": "4a48fe8875c6214145260818",
+						"bucketName": "Kitten-Videos",
+						"capabilities": ["listFiles", "readFiles"],
+						"downloadUrl": "https://f000.backblazeb2.com",
+						"infoType": "storageApi",
+						"namePrefix": "images/",
+						"recommendedPartSize": 100000000,
+						"s3ApiUrl": "https://s3.us-west-000.backblazeb2.com"
+					}
+				},
+				"applicationKeyExpirationTimestamp": 1605225412000,
+				"authorizationToken": "4_0022623512fc8f80000000001_0186e431_d18d02_acct_tH7VW03boebOXayIc43-sxptpfA="
+			}`,
+		},
+		{
+			name: "b2_list_buckets response",
+			v:    &ListBucketsResponse{},
+			json: `{
+				"buckets": [{
+					"accountId": "30f20426f0b1",
+					"bucketId": "4a48fe8875c6214145260818",
+					"bucketInfo": {},
+					"bucketName": "Kitten-Videos",
+					"bucketType": "allPrivate",
+					"corsRules": [{
+						"corsRuleName": "downloadFromAnyOrigin",
+						"allowedOrigins": ["https"],
+						"allowedHeaders": ["range"],
+						"allowedOperations": ["b2_download_file_by_id", "b2_download_file_by_name"],
+						"exposeHeaders": ["x-bz-content-sha1"],
+						"maxAgeSeconds": 3600
+					}],
+					"defaultServerSideEncryption": {
+						"isClientAuthorizedToRead": true,
+						"value": {"algorithm": "AES256", "mode": "SSE-B2"}
+					},
+					"fileLockConfiguration": {
+						"isClientAuthorizedToRead": true,
+						"value": {
+							"defaultRetention": {"mode": "governance", "period": {"duration": 7, "unit": "days"}},
+							"isFileLockEnabled": true
+						}
+					},
+					"lifecycleRules": [{
+						"daysFromHidingToDeleting": 30,
+						"daysFromUploadingToHiding": 365,
+						"fileNamePrefix": "backup/"
+					}],
+					"options": ["s3"],
+					"replicationConfiguration": {
+						"isClientAuthorizedToRead": true,
+						"value": {
+							"asReplicationSource": {
+								"replicationRules": [{
+									"destinationBucketId": "c5f35d53a90a7ea284fb0719",
+									"fileNamePrefix": "",
+									"includeExistingFiles": true,
+									"isEnabled": true,
+									"priority": 1,
+									"replicationRuleName": "replication-us-east"
+								}],
+								"sourceApplicationKeyId": "00512f95cf4dcf0000000004q"
+							},
+							"asReplicationDestination": {
+								"sourceToDestinationKeyMapping": {"00512f95cf4dcf0000000004q": "00530e9b58f9b3c0000000003y"}
+							}
+						}
+					},
+					"revision": 1
+				}, {
+					"accountId": "30f20426f0b1",
+					"bucketId": "5b232e8875c6214145260818",
+					"bucketInfo": {},
+					"bucketName": "Puppy-Videos",
+					"bucketType": "allPublic",
+					"corsRules": [],
+					"defaultServerSideEncryption": {"isClientAuthorizedToRead": false, "value": null},
+					"fileLockConfiguration": {"isClientAuthorizedToRead": false, "value": null},
+					"lifecycleRules": [],
+					"options": [],
+					"replicationConfiguration": {"isClientAuthorizedToRead": false, "value": null},
+					"revision": 1
+				}]
+			}`,
+		},
+		{
+			name: "b2_update_bucket request",
+			v:    &UpdateBucketRequest{},
+			json: `{
+				"accountId": "30f20426f0b1",
+				"bucketId": "4a48fe8875c6214145260818",
+				"bucketType": "allPublic",
+				"bucketInfo": {},
+				"lifecycleRules": [],
+				"corsRules": [],
+				"defaultRetention": {"mode": null},
+				"defaultServerSideEncryption": {"mode": null},
+				"replicationConfiguration": {},
+				"ifRevisionIs": 3
+			}`,
+		},
+		{
+			name: "b2_get_file_info response",
+			v:    &GetFileInfoResponse{},
+			json: `{
+				"accountId": "30f20426f0b1",
+				"action": "upload",
+				"bucketId": "4a48fe8875c6214145260818",
+				"contentLength": 7,
+				"contentMd5": "d41d8cd98f00b204e9800998ecf8427e",
+				"contentSha1": "dc724af18fbdd4e59189f5fe768a5f8311527050",
+				"contentType": "text/plain",
+				"fileId": "4_zf1f51fb913357c4f74ed0c1b_f1000000000000000_d20160430_m174800_c000_v0001000_t0012",
+				"fileInfo": {"src_last_modified_millis": "1587051429000"},
+				"fileName": "typing_test.txt",
+				"fileRetention": {
+					"isClientAuthorizedToRead": true,
+					"value": {"mode": "governance", "retainUntilTimestamp": 1628942493000}
+				},
+				"legalHold": {"isClientAuthorizedToRead": true, "value": "on"},
+				"replicationStatus": "pending",
+				"serverSideEncryption": {"algorithm": "AES256", "mode": "SSE-B2"},
+				"uploadTimestamp": 1587051429000
+			}`,
+		},
+		{
+			name: "b2_copy_file request",
+			v:    &CopyFileRequest{},
+			json: `{
+				"sourceFileId": "4_z4c2b957661da9c825f260e1b_f1000000000000000_d20160430_m174800_c000_v0001000_t0012",
+				"destinationBucketId": "5b232e8875c6214145260818",
+				"fileName": "new-name.txt",
+				"range": "bytes=100-199",
+				"metadataDirective": "REPLACE",
+				"contentType": "text/plain",
+				"fileInfo": {"src_last_modified_millis": "1587051429000"},
+				"fileRetention": {"mode": "compliance", "retainUntilTimestamp": 1628942493000},
+				"legalHold": "on",
+				"sourceServerSideEncryption": {
+					"mode": "SSE-C",
+					"algorithm": "AES256",
+					"customerKey": "Ts8RkUpqPYyD1v8DoRzW3PdfIkDXtR/Bl6JUv+ydsaA=",
+					"customerKeyMd5": "kFLEQVfS6EI/qlwAcaDVQA=="
+				},
+				"destinationServerSideEncryption": {"mode": "SSE-B2", "algorithm": "AES256"}
+			}`,
+		},
+		{
+			name: "b2_update_file_retention request",
+			v:    &UpdateFileRetentionRequest{},
+			json: `{
+				"fileName": "typing_test.txt",
+				"fileId": "4_zf1f51fb913357c4f74ed0c1b_f1000000000000000_d20160430_m174800_c000_v0001000_t0012",
+				"fileRetention": {"mode": "governance", "retainUntilTimestamp": 1628942493000},
+				"bypassGovernance": true
+			}`,
+		},
+		{
+			name: "b2_update_file_retention response",
+			v:    &UpdateFileRetentionResponse{},
+			json: `{
+				"fileId": "4_zf1f51fb913357c4f74ed0c1b_f1000000000000000_d20160430_m174800_c000_v0001000_t0012",
+				"fileName": "typing_test.txt",
+				"fileRetention": {"mode": null, "retainUntilTimestamp": null}
+			}`,
+		},
+		{
+			name: "b2_update_file_legal_hold request",
+			v:    &UpdateFileLegalHoldRequest{},
+			json: `{
+				"fileName": "typing_test.txt",
+				"fileId": "4_zf1f51fb913357c4f74ed0c1b_f1000000000000000_d20160430_m174800_c000_v0001000_t0012",
+				"legalHold": "on"
+			}`,
+		},
+		{
+			name: "b2_update_file_legal_hold response",
+			v:    &UpdateFileLegalHoldResponse{},
+			json: `{
+				"fileId": "4_zf1f51fb913357c4f74ed0c1b_f1000000000000000_d20160430_m174800_c000_v0001000_t0012",
+				"fileName": "typing_test.txt",
+				"legalHold": "off"
+			}`,
+		},
+		{
+			name: "b2_copy_part response",
+			v:    &CopyPartResponse{},
+			json: `{
+				"fileId": "4_ze73ede9c9c8412db49f60715_f200b4e93fbae6252_d20150824_m224353_c900_v8881000_t0001",
+				"partNumber": 1,
+				"contentLength": 5000000,
+				"contentSha1": "4a7f20fa5a7d1e5f2bc8dc2f8cd7e4d5d1a6dc2f",
+				"contentMd5": "3bddd7bd6d8bf4c0e39b1f4e2a9cd6e4",
+				"serverSideEncryption": {"algorithm": "AES256", "mode": "SSE-B2"},
+				"uploadTimestamp": 1462212184000
+			}`,
+		},
+		{
+			name: "b2_create_key response",
+			v:    &CreateKeyResponse{},
+			json: `{
+				"accountId": "30f20426f0b1",
+				"applicationKey": "K0014pbwo1zxcIVMnqSNTfWHReU/O3s",
+				"applicationKeyId": "00530e9b58f9b3c0000000003y",
+				"bucketId": "4a48fe8875c6214145260818",
+				"capabilities": ["listFiles", "readFiles"],
+				"expirationTimestamp": 1605225412000,
+				"keyName": "my-key",
+				"namePrefix": "images/",
+				"options": ["s3"]
+			}`,
+		},
+		{
+			name: "b2_set_bucket_notification_rules request",
+			v:    &SetBucketNotificationRulesRequest{},
+			json: `{
+				"bucketId": "4a48fe8875c6214145260818",
+				"eventNotificationRules": [{
+					"eventTypes": ["b2:ObjectCreated:*", "b2:ObjectDeleted:*"],
+					"isEnabled": true,
+					"name": "myRule",
+					"objectNamePrefix": "",
+					"targetConfiguration": {
+						"customHeaders": [{"name": "X-My-Custom-Header", "value": "myCustomHeaderValue"}],
+						"hmacSha256SigningSecret": "3j8M9e3CAznX8y9K4Rx6iKsCkDPbcbx6",
+						"targetType": "webhook",
+						"url": "https://www.example.com/myWebhook"
+					}
+				}]
+			}`,
+		},
+		{
+			name: "b2_get_bucket_notification_rules response",
+			v:    &GetBucketNotificationRulesResponse{},
+			json: `{
+				"bucketId": "4a48fe8875c6214145260818",
+				"eventNotificationRules": [{
+					"eventTypes": ["b2:ObjectCreated:*"],
+					"isEnabled": true,
+					"isSuspended": true,
+					"name": "myRule",
+					"objectNamePrefix": "images/",
+					"suspensionReason": "webhook failed",
+					"targetConfiguration": {
+						"targetType": "webhook",
+						"url": "https://www.example.com/myWebhook"
+					}
+				}]
+			}`,
+		},
+	}
+
+	for _, e := range table {
+		if err := json.Unmarshal([]byte(e.json), e.v); err != nil {
+			t.Errorf("%s: decoding: %v", e.name, err)
+			continue
+		}
+		buf, err := json.Marshal(e.v)
+		if err != nil {
+			t.Errorf("%s: encoding: %v", e.name, err)
+			continue
+		}
+		var want, got interface{}
+		if err := json.Unmarshal([]byte(e.json), &want); err != nil {
+			t.Fatalf("%s: bad sample: %v", e.name, err)
+		}
+		if err := json.Unmarshal(buf, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: round trip gave\n%s\nwant\n%s", e.name, buf, e.json)
+		}
+	}
+}
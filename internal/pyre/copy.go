@@ -0,0 +1,189 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pyre
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/burner-account/blazer/internal/b2types"
+	"github.com/google/uuid"
+)
+
+const (
+	copyFilePath = "/b2api/v1/b2_copy_file"
+	copyPartPath = "/b2api/v1/b2_copy_part"
+)
+
+// CopyManager provides the storage for server-side copies.
+type CopyManager interface {
+	// ObjectByID returns the object with the given file ID, and the bucket
+	// and name it was stored under.
+	ObjectByID(fileID string) (bucketID, name string, obj DownloadableObject, err error)
+	SimpleFileManager
+	LargeFileManager
+}
+
+type copyServer struct {
+	cm CopyManager
+}
+
+// openSource returns a reader for the requested range of the source file.
+func (cs *copyServer) openSource(id, rang string) (string, io.Reader, io.Closer, error) {
+	bucket, _, obj, err := cs.cm.ObjectByID(id)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	dr, err := parseRange(rang)
+	if err != nil {
+		obj.Close()
+		return "", nil, nil, err
+	}
+	off, n := dr.off, dr.n
+	if off == 0 && n == 0 {
+		n = obj.Size()
+	}
+	if off > obj.Size() || off+n > obj.Size() {
+		obj.Close()
+		return "", nil, nil, fmt.Errorf("range %q is outside of the %d byte source", rang, obj.Size())
+	}
+	return bucket, io.NewSectionReader(obj.Reader(), off, n), obj, nil
+}
+
+// copyTo copies r into w, returning the size and SHA-1 of what was copied.
+func copyTo(w io.WriteCloser, r io.Reader) (int64, string, error) {
+	sha := sha1.New()
+	n, err := io.Copy(io.MultiWriter(w, sha), r)
+	if err != nil {
+		w.Close()
+		return 0, "", err
+	}
+	if err := w.Close(); err != nil {
+		return 0, "", err
+	}
+	return n, fmt.Sprintf("%x", sha.Sum(nil)), nil
+}
+
+func (cs *copyServer) copyFile(req *b2types.CopyFileRequest) (*b2types.CopyFileResponse, error) {
+	switch req.MetadataDirective {
+	case "", "COPY":
+		if req.ContentType != "" || req.Info != nil {
+			return nil, errors.New("contentType and fileInfo must not be set with the COPY metadata directive")
+		}
+	case "REPLACE":
+		if req.ContentType == "" {
+			return nil, errors.New("contentType is required with the REPLACE metadata directive")
+		}
+	default:
+		return nil, fmt.Errorf("unknown metadata directive %q", req.MetadataDirective)
+	}
+	bucket, r, c, err := cs.openSource(req.SourceID, req.Range)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if req.DestBucketID != "" {
+		bucket = req.DestBucketID
+	}
+	id := uuid.New().String()
+	w, err := cs.cm.Writer(bucket, req.Name, id)
+	if err != nil {
+		return nil, err
+	}
+	size, sha, err := copyTo(w, r)
+	if err != nil {
+		return nil, err
+	}
+	// Metadata is not stored, so there is nothing to carry over for COPY.
+	return &b2types.CopyFileResponse{
+		FileID:      id,
+		Name:        req.Name,
+		BucketID:    bucket,
+		Size:        size,
+		SHA1:        sha,
+		ContentType: req.ContentType,
+		Info:        req.Info,
+		Action:      "upload",
+		Timestamp:   time.Now().UnixNano() / 1e6,
+	}, nil
+}
+
+func (cs *copyServer) copyPart(req *b2types.CopyPartRequest) (*b2types.CopyPartResponse, error) {
+	if req.PartNumber < 1 || req.PartNumber > 10000 {
+		return nil, fmt.Errorf("bad part number %d", req.PartNumber)
+	}
+	_, r, c, err := cs.openSource(req.SourceID, req.Range)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	w, err := cs.cm.PartWriter(req.LargeFileID, req.PartNumber)
+	if err != nil {
+		return nil, err
+	}
+	size, sha, err := copyTo(w, r)
+	if err != nil {
+		return nil, err
+	}
+	return &b2types.CopyPartResponse{
+		FileID:     req.LargeFileID,
+		PartNumber: req.PartNumber,
+		Size:       size,
+		SHA1:       sha,
+		Timestamp:  time.Now().UnixNano() / 1e6,
+	}, nil
+}
+
+func (cs *copyServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var resp interface{}
+	var err error
+	switch r.URL.Path {
+	case copyFilePath:
+		req := &b2types.CopyFileRequest{}
+		if err = json.NewDecoder(r.Body).Decode(req); err == nil {
+			resp, err = cs.copyFile(req)
+		}
+	case copyPartPath:
+		req := &b2types.CopyPartRequest{}
+		if err = json.NewDecoder(r.Body).Decode(req); err == nil {
+			resp, err = cs.copyPart(req)
+		}
+	default:
+		http.NotFound(rw, r)
+		return
+	}
+	if err != nil {
+		rw.WriteHeader(400)
+		json.NewEncoder(rw).Encode(apiErr{Status: 400, Code: "bad_request", Message: err.Error()})
+		return
+	}
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		fmt.Println("oh no")
+	}
+}
+
+// RegisterCopyManagerOnMux serves b2_copy_file and b2_copy_part.  Copies of
+// whole files and of byte ranges are supported.  Since pyre does not keep
+// file metadata, the COPY directive produces a file with none.
+func RegisterCopyManagerOnMux(c CopyManager, mux *http.ServeMux) {
+	cs := &copyServer{cm: c}
+	mux.Handle(copyFilePath, cs)
+	mux.Handle(copyPartPath, cs)
+}
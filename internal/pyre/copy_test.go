@@ -0,0 +1,178 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pyre
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/burner-account/blazer/internal/b2types"
+)
+
+type memObject struct{ b []byte }
+
+func (m memObject) Size() int64         { return int64(len(m.b)) }
+func (m memObject) Reader() io.ReaderAt { return bytes.NewReader(m.b) }
+func (m memObject) Close() error        { return nil }
+
+type memWriter struct {
+	bytes.Buffer
+	done func([]byte)
+}
+
+func (m *memWriter) Close() error { m.done(m.Bytes()); return nil }
+
+type testCopyManager struct {
+	mu    sync.Mutex
+	files map[string][]byte // bucket/name/id
+	parts map[string][]byte // id/part
+}
+
+func (t *testCopyManager) ObjectByID(id string) (string, string, DownloadableObject, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, v := range t.files {
+		parts := strings.Split(k, "/")
+		if parts[2] == id {
+			return parts[0], parts[1], memObject{v}, nil
+		}
+	}
+	return "", "", nil, errors.New("not found")
+}
+
+func (t *testCopyManager) Writer(bucket, name, id string) (io.WriteCloser, error) {
+	return &memWriter{done: func(b []byte) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.files[bucket+"/"+name+"/"+id] = b
+	}}, nil
+}
+
+func (t *testCopyManager) PartWriter(id string, part int) (io.WriteCloser, error) {
+	return &memWriter{done: func(b []byte) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.parts[fmt.Sprintf("%s/%d", id, part)] = b
+	}}, nil
+}
+
+func TestCopy(t *testing.T) {
+	cm := &testCopyManager{
+		files: map[string][]byte{"bucket/src/1234": []byte("0123456789")},
+		parts: make(map[string][]byte),
+	}
+	mux := http.NewServeMux()
+	RegisterCopyManagerOnMux(cm, mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	post := func(path string, req, resp interface{}) int {
+		b, err := json.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := http.Post(srv.URL+path, "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Body.Close()
+		if r.StatusCode == 200 {
+			if err := json.NewDecoder(r.Body).Decode(resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return r.StatusCode
+	}
+
+	fileTable := []struct {
+		req    b2types.CopyFileRequest
+		status int
+		bucket string
+		want   string
+	}{
+		{
+			req:    b2types.CopyFileRequest{SourceID: "1234", Name: "whole"},
+			status: 200,
+			bucket: "bucket",
+			want:   "0123456789",
+		},
+		{
+			req:    b2types.CopyFileRequest{SourceID: "1234", Name: "range", Range: "bytes=2-4", DestBucketID: "other"},
+			status: 200,
+			bucket: "other",
+			want:   "234",
+		},
+		{
+			req:    b2types.CopyFileRequest{SourceID: "1234", Name: "meta", MetadataDirective: "REPLACE", ContentType: "text/plain", Info: map[string]string{"a": "b"}},
+			status: 200,
+			bucket: "bucket",
+			want:   "0123456789",
+		},
+		{
+			req:    b2types.CopyFileRequest{SourceID: "1234", Name: "bad", MetadataDirective: "REPLACE"},
+			status: 400,
+		},
+		{
+			req:    b2types.CopyFileRequest{SourceID: "1234", Name: "bad", ContentType: "text/plain"},
+			status: 400,
+		},
+		{
+			req:    b2types.CopyFileRequest{SourceID: "1234", Name: "bad", Range: "bytes=5-20"},
+			status: 400,
+		},
+		{
+			req:    b2types.CopyFileRequest{SourceID: "nope", Name: "bad"},
+			status: 400,
+		},
+	}
+	for _, e := range fileTable {
+		resp := &b2types.CopyFileResponse{}
+		if got := post(copyFilePath, e.req, resp); got != e.status {
+			t.Errorf("copy_file %+v: got status %d, want %d", e.req, got, e.status)
+			continue
+		}
+		if e.status != 200 {
+			continue
+		}
+		got := string(cm.files[e.bucket+"/"+e.req.Name+"/"+resp.FileID])
+		if got != e.want || resp.Size != int64(len(e.want)) {
+			t.Errorf("copy_file %+v: got %q (%d bytes), want %q", e.req, got, resp.Size, e.want)
+		}
+		if resp.ContentType != e.req.ContentType {
+			t.Errorf("copy_file %+v: got content type %q", e.req, resp.ContentType)
+		}
+	}
+
+	resp := &b2types.CopyPartResponse{}
+	req := b2types.CopyPartRequest{SourceID: "1234", LargeFileID: "large", PartNumber: 2, Range: "bytes=5-9"}
+	if got := post(copyPartPath, req, resp); got != 200 {
+		t.Fatalf("copy_part: got status %d", got)
+	}
+	if got := string(cm.parts["large/2"]); got != "56789" {
+		t.Errorf("copy_part: got %q, want %q", got, "56789")
+	}
+	if want := fmt.Sprintf("%x", sha1.Sum([]byte("56789"))); resp.SHA1 != want {
+		t.Errorf("copy_part: got sha1 %s, want %s", resp.SHA1, want)
+	}
+}
@@ -43,7 +43,12 @@ type downloadRequest struct {
 }
 
 func parseDownloadHeaders(r *http.Request) (*downloadRequest, error) {
-	rang := r.Header.Get("Range")
+	return parseRange(r.Header.Get("Range"))
+}
+
+// parseRange parses a byte range of the form "bytes=100-199".  An empty range
+// means the whole object.
+func parseRange(rang string) (*downloadRequest, error) {
 	if rang == "" {
 		return &downloadRequest{}, nil
 	}
@@ -0,0 +1,98 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A Clock tells the time and waits.  It is satisfied by b2.Clock.
+type Clock interface {
+	Now() time.Time
+	After(time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// A Limiter allows at most a given number of events in any trailing span of
+// time.  Events are counted in a Window, so an event stops counting against
+// the limit up to one resolution after the span has passed.
+type Limiter struct {
+	mu    sync.Mutex
+	w     *Window
+	max   int
+	res   time.Duration
+	clock Clock
+}
+
+// NewLimiter returns a Limiter that allows n events over the given duration,
+// counted at the given resolution, which should divide size evenly.  The
+// limiter keeps time with c, or with the system clock if c is nil.
+func NewLimiter(n int, size, resolution time.Duration, c Clock) *Limiter {
+	if c == nil {
+		c = systemClock{}
+	}
+	return &Limiter{
+		w:     New(size, resolution, sum),
+		max:   n,
+		res:   resolution,
+		clock: c,
+	}
+}
+
+func sum(i, j interface{}) interface{} {
+	a, _ := i.(int)
+	b, _ := j.(int)
+	return a + b
+}
+
+// Allow records an event and returns true if the limit has not been reached.
+// Otherwise it records nothing and returns false.
+func (l *Limiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	if n, _ := l.w.ReduceAt(now).(int); n >= l.max {
+		return false
+	}
+	l.w.InsertAt(now, 1)
+	return true
+}
+
+// Wait blocks until an event can be recorded without exceeding the limit, and
+// records it.  It returns an error only if ctx is done first.
+func (l *Limiter) Wait(ctx context.Context) error {
+	for !l.Allow() {
+		select {
+		case <-l.clock.After(l.res):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Count returns the number of events in the window.
+func (l *Limiter) Count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n, _ := l.w.ReduceAt(l.clock.Now()).(int)
+	return n
+}
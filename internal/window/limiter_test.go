@@ -0,0 +1,63 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// stoppedClock reads a fixed time, and never fires.
+type stoppedClock struct {
+	now time.Time
+}
+
+func (c *stoppedClock) Now() time.Time                       { return c.now }
+func (c *stoppedClock) After(time.Duration) <-chan time.Time { return nil }
+
+func TestLimiter(t *testing.T) {
+	clock := &stoppedClock{now: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter(3, time.Minute, time.Second, clock)
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow() {
+			t.Fatalf("Allow %d: got false, want true", i)
+		}
+		clock.now = clock.now.Add(10 * time.Second)
+	}
+	if l.Allow() {
+		t.Error("Allow over the limit: got true, want false")
+	}
+	if got := l.Count(); got != 3 {
+		t.Errorf("Count: got %d, want 3", got)
+	}
+
+	// The first event leaves the window a minute after it was recorded.
+	clock.now = clock.now.Add(31 * time.Second)
+	if !l.Allow() {
+		t.Error("Allow after the first event expired: got false, want true")
+	}
+	if l.Allow() {
+		t.Error("Allow over the limit again: got true, want false")
+	}
+
+	// The clock never fires, so Wait can only give up.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Wait over the limit: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -93,11 +93,11 @@ func TestWindows(t *testing.T) {
 	for _, e := range table {
 		w := New(e.size, e.dur, e.reduce)
 		for _, inc := range e.incs {
-			w.insertAt(inc.t, inc.e)
+			w.InsertAt(inc.t, inc.e)
 		}
-		ct := w.reducedAt(e.look)
+		ct := w.ReduceAt(e.look)
 		if ct != e.want {
-			t.Errorf("reducedAt(%v) got %v, want %v", e.look, ct, e.want)
+			t.Errorf("ReduceAt(%v) got %v, want %v", e.look, ct, e.want)
 		}
 	}
 }
@@ -0,0 +1,140 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consistent
+
+import (
+	"context"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// A Cache serves reads of group objects from memory.  Every version of a group
+// object is stored under a name of its own and never modified, so a cached
+// version is valid for as long as the group's table still points to it; the
+// Cache only needs to reread the table, which is one API call, when its copy
+// is older than the staleness window, and only needs to download an object
+// when the table names a version it does not have.
+//
+// Values read through a Cache may therefore be out of date by up to the
+// window, plus the time taken to read the table.  Callers that need the
+// current value, or that will update it, should use Operate or NewReader.
+type Cache struct {
+	g        *Group
+	maxStale time.Duration
+
+	mu        sync.Mutex
+	locs      map[string]string // the group's table, as of validated
+	validated time.Time
+	data      map[string]cached // by object name
+}
+
+type cached struct {
+	suffix string
+	data   []byte
+}
+
+// A Value is the contents of a group object as read through a Cache.
+type Value struct {
+	// Data holds the object's contents.  It is nil if the object is not in
+	// the group, and must not be modified.
+	Data []byte
+
+	// Key identifies the version read, as Reader.Key does.
+	Key string
+
+	// Stale bounds how out of date the value can be: the group's table was
+	// read no more than Stale ago, and Data was current at the time.
+	Stale time.Duration
+}
+
+// NewCache returns a Cache for the group that rereads the group's table at
+// most once every maxStale.
+func (g *Group) NewCache(maxStale time.Duration) *Cache {
+	return &Cache{
+		g:        g,
+		maxStale: maxStale,
+		data:     make(map[string]cached),
+	}
+}
+
+// Get returns the named object, making API calls only if the cached table is
+// older than the staleness window or the object has changed.
+func (c *Cache) Get(ctx context.Context, name string) (*Value, error) {
+	full := c.g.prefix + name
+	locs, validated, err := c.table(ctx)
+	if err != nil {
+		return nil, err
+	}
+	suffix, ok := locs[full]
+	if !ok {
+		return &Value{Stale: time.Since(validated)}, nil
+	}
+	c.mu.Lock()
+	e, ok := c.data[full]
+	c.mu.Unlock()
+	if !ok || e.suffix != suffix {
+		r := c.g.object(full, suffix).NewReader(ctx)
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		e = cached{suffix: suffix, data: b}
+		c.mu.Lock()
+		c.data[full] = e
+		c.mu.Unlock()
+	}
+	return &Value{
+		Data:  e.data,
+		Key:   suffix,
+		Stale: time.Since(validated),
+	}, nil
+}
+
+// Invalidate makes the next Get reread the group's table.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validated = time.Time{}
+}
+
+// table returns the group's table, rereading it if the cached copy is too old.
+func (c *Cache) table(ctx context.Context) (map[string]string, time.Time, error) {
+	c.mu.Lock()
+	if !c.validated.IsZero() && time.Since(c.validated) < c.maxStale {
+		defer c.mu.Unlock()
+		return c.locs, c.validated, nil
+	}
+	c.mu.Unlock()
+
+	start := time.Now()
+	ci, err := c.g.info(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if start.After(c.validated) {
+		c.locs = ci.Locations
+		c.validated = start
+		for name, e := range c.data {
+			if ci.Locations[name] != e.suffix {
+				delete(c.data, name) // replaced or removed
+			}
+		}
+	}
+	return c.locs, c.validated, nil
+}
@@ -27,6 +27,7 @@ import (
 	"io"
 	"io/ioutil"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/burner-account/blazer/b2"
@@ -39,22 +40,68 @@ var (
 	errNotInGroup     = errors.New("not in group")
 )
 
-// NewGroup creates a new consistent Group for the given bucket.
-func NewGroup(bucket *b2.Bucket, name string) *Group {
-	return &Group{
-		name: name,
-		b:    bucket,
+// NewGroup creates a new consistent Group for the given bucket.  The group's
+// table of objects is kept in the bucket's info, and its objects in the bucket
+// itself unless ObjectsIn says otherwise.
+func NewGroup(bucket *b2.Bucket, name string, opts ...GroupOption) *Group {
+	g := &Group{
+		name:   name,
+		b:      bucket,
+		m:      &groupMetrics{},
+		routes: &routes{},
+	}
+	for _, opt := range opts {
+		opt(g)
 	}
+	return g
 }
 
 // Group represents a collection of B2 objects that can be modified in a
 // consistent way.  Objects in the same group contend with each other for
 // updates, but there can only be so many (maximum of 10; fewer if there are
 // other bucket attributes set) groups in a given bucket.
+//
+// Every operation reads the bucket's attributes.  Busy groups can cut down on
+// API calls by using a client created with b2.BucketAttrsTTL; stale reads are
+// caught when the group is saved, and the operation is retried.
 type Group struct {
-	name string
-	b    *b2.Bucket
-	ba   *b2.BucketAttrs
+	name   string
+	b      *b2.Bucket
+	prefix string // for namespaces, prepended to object names
+	m      *groupMetrics
+	routes *routes // where objects are kept, if not in b
+}
+
+// Namespace returns a view of the group that holds only objects whose names
+// begin with name and "/", and which addresses them without that prefix.  This
+// lets one group hold several logical collections, such as "jobs" and
+// "locks", without their names colliding.  Namespaces may be nested.
+//
+// A namespace shares its group's table of objects, and so contends with it for
+// updates; see Shard for a namespace that does not.
+func (g *Group) Namespace(name string) *Group {
+	return &Group{
+		name:   g.name,
+		b:      g.b,
+		prefix: g.prefix + name + "/",
+		m:      g.m,
+		routes: g.routes,
+	}
+}
+
+// Shard returns a namespace whose table of objects is kept separately from
+// its group's, in a bucket info entry of its own.  Updates to a shard do not
+// contend with updates to the rest of the group, and its objects do not count
+// toward the size of the group's table, but each shard uses one of the
+// bucket's ten info entries.  The objects in a shard are not visible through
+// the group or its other namespaces.
+func (g *Group) Shard(name string) *Group {
+	return &Group{
+		name:   g.name + "/" + g.prefix + name,
+		b:      g.b,
+		m:      g.m,
+		routes: g.routes,
+	}
 }
 
 // Mutex returns a new mutex on the given group.  Only one caller can hold the
@@ -78,8 +125,14 @@ func (g *Group) Mutex(ctx context.Context, name string) *Mutex {
 // The io.Reader that f returns is guaranteed to be read until at least the
 // first error.  Callers must ensure that this is sufficient for the reader to
 // clean up after itself.
-func (g *Group) OperateStream(ctx context.Context, name string, f func(io.Reader) (io.Reader, error)) error {
-	for {
+func (g *Group) OperateStream(ctx context.Context, name string, f func(io.Reader) (io.Reader, error)) (rerr error) {
+	start := time.Now()
+	attempt := 1
+	defer func() {
+		g.m.report(g, Event{Name: g.prefix + name, Kind: Done, Attempt: attempt, Elapsed: time.Since(start), Err: rerr})
+	}()
+	for ; ; attempt++ {
+		began := time.Now()
 		r, err := g.NewReader(ctx, name)
 		if err != nil && err != errNotInGroup {
 			return err
@@ -99,6 +152,8 @@ func (g *Group) OperateStream(ctx context.Context, name string, f func(io.Reader
 		}
 		if err := w.Close(); err != nil {
 			if err == errUpdateConflict {
+				g.m.blocked(time.Since(began))
+				g.m.report(g, Event{Name: g.prefix + name, Kind: Conflict, Attempt: attempt, Elapsed: time.Since(start)})
 				continue
 			}
 			return err
@@ -133,7 +188,8 @@ func (g *Group) Operate(ctx context.Context, name string, f func([]byte) ([]byte
 // struct, t, or a pointer to it, that is the same type.  t will not be
 // altered.  If there is no existing file, f will be called with an pointer to
 // an empty struct of type t.  Otherwise, it will be called with a pointer to a
-// struct filled out with the given JSON.
+// struct filled out with the given JSON.  To store values in another format,
+// use OperateCodec.
 func (g *Group) OperateJSON(ctx context.Context, name string, t interface{}, f func(interface{}) (interface{}, error)) error {
 	jsonType := reflect.TypeOf(t)
 	for jsonType.Kind() == reflect.Ptr {
@@ -154,6 +210,45 @@ func (g *Group) OperateJSON(ctx context.Context, name string, t interface{}, f f
 	})
 }
 
+// A Codec converts values to and from the bytes stored in B2.  It allows
+// values to be stored as protocol buffers, CBOR, or encrypted payloads, rather
+// than as JSON.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// JSON is a Codec that uses encoding/json.
+var JSON Codec = jsonCodec{}
+
+// OperateCodec is like OperateJSON, but values are stored as encoded by c.  If
+// there is no existing file, or it is empty, f is called with a pointer to an
+// empty struct of type t, without calling c.Unmarshal.
+func (g *Group) OperateCodec(ctx context.Context, name string, c Codec, t interface{}, f func(interface{}) (interface{}, error)) error {
+	typ := reflect.TypeOf(t)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return g.Operate(ctx, name, func(b []byte) ([]byte, error) {
+		in := reflect.New(typ).Interface()
+		if len(b) > 0 {
+			if err := c.Unmarshal(b, in); err != nil {
+				return nil, err
+			}
+		}
+		out, err := f(in)
+		if err != nil {
+			return nil, err
+		}
+		return c.Marshal(out)
+	})
+}
+
 // closeAfterReading closes the underlying reader on the first non-nil error
 type closeAfterReading struct {
 	rc io.ReadCloser
@@ -188,28 +283,30 @@ func (w Writer) Close() error {
 		return err
 	}
 	// TODO: maybe see if you can cut down on calls to info()
-	for {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
 		ci, err := w.g.info(w.ctx)
 		if err != nil {
 			// Replacement failed; delete the new version.
-			w.g.b.Object(w.name + "/" + w.suffix).Delete(w.ctx)
+			w.g.object(w.name, w.suffix).Delete(w.ctx)
 			return err
 		}
 		old, ok := ci.Locations[w.name]
 		if ok && old != w.key {
-			w.g.b.Object(w.name + "/" + w.suffix).Delete(w.ctx)
+			w.g.object(w.name, w.suffix).Delete(w.ctx)
 			return errUpdateConflict
 		}
 		ci.Locations[w.name] = w.suffix
 		if err := w.g.save(w.ctx, ci); err != nil {
 			if err == errUpdateConflict {
+				w.g.m.report(w.g, Event{Name: w.name, Kind: SaveConflict, Attempt: attempt, Elapsed: time.Since(start)})
 				continue
 			}
-			w.g.b.Object(w.name + "/" + w.suffix).Delete(w.ctx)
+			w.g.object(w.name, w.suffix).Delete(w.ctx)
 			return err
 		}
 		// Replacement successful; delete the old version.
-		w.g.b.Object(w.name + "/" + w.key).Delete(w.ctx)
+		w.g.object(w.name, w.key).Delete(w.ctx)
 		return nil
 	}
 }
@@ -244,9 +341,10 @@ func (g *Group) NewWriter(ctx context.Context, key, name string) (Writer, error)
 	if err != nil {
 		return Writer{}, err
 	}
+	name = g.prefix + name
 	return Writer{
 		ctx:    ctx,
-		wc:     g.b.Object(name + "/" + suffix).NewWriter(ctx),
+		wc:     g.object(name, suffix).NewWriter(ctx),
 		name:   name,
 		suffix: suffix,
 		key:    key,
@@ -261,12 +359,13 @@ func (g *Group) NewReader(ctx context.Context, name string) (Reader, error) {
 	if err != nil {
 		return Reader{}, err
 	}
+	name = g.prefix + name
 	suffix, ok := ci.Locations[name]
 	if !ok {
 		return Reader{}, errNotInGroup
 	}
 	return Reader{
-		r:   g.b.Object(name + "/" + suffix).NewReader(ctx),
+		r:   g.object(name, suffix).NewReader(ctx),
 		Key: suffix,
 	}, nil
 }
@@ -276,12 +375,11 @@ func (g *Group) info(ctx context.Context) (*consistentInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-	g.ba = attrs
-	imap := attrs.Info
-	if imap == nil {
-		return nil, nil
-	}
-	enc, ok := imap[metaKey+"-"+g.name]
+	return g.decode(attrs)
+}
+
+func (g *Group) decode(attrs *b2.BucketAttrs) (*consistentInfo, error) {
+	enc, ok := attrs.Info[metaKey+"-"+g.name]
 	if !ok {
 		return &consistentInfo{
 			Version:   1,
@@ -303,6 +401,7 @@ func (g *Group) info(ctx context.Context) (*consistentInfo, error) {
 }
 
 func (g *Group) save(ctx context.Context, ci *consistentInfo) error {
+	g.m.saved()
 	ci.Serial++
 	b, err := json.Marshal(ci)
 	if err != nil {
@@ -310,42 +409,162 @@ func (g *Group) save(ctx context.Context, ci *consistentInfo) error {
 	}
 	s := base64.StdEncoding.EncodeToString(b)
 
-	for {
-		oldAI, err := g.info(ctx)
+	return g.b.UpdateWith(ctx, func(attrs *b2.BucketAttrs) error {
+		oldAI, err := g.decode(attrs)
 		if err != nil {
 			return err
 		}
 		if oldAI.Serial != ci.Serial-1 {
 			return errUpdateConflict
 		}
-		if g.ba.Info == nil {
-			g.ba.Info = make(map[string]string)
+		if attrs.Info == nil {
+			attrs.Info = make(map[string]string)
 		}
-		g.ba.Info[metaKey+"-"+g.name] = s
-		err = g.b.Update(ctx, g.ba)
-		if err == nil {
+		attrs.Info[metaKey+"-"+g.name] = s
+		return nil
+	})
+}
+
+// List returns a list of all the group objects.  For a namespace, only the
+// objects in the namespace (including those in nested namespaces) are listed,
+// without the namespace prefix.
+func (g *Group) List(ctx context.Context) ([]string, error) {
+	ci, err := g.info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return g.names(ci.Locations), nil
+}
+
+func (g *Group) names(locs map[string]string) []string {
+	var l []string
+	for name := range locs {
+		if strings.HasPrefix(name, g.prefix) {
+			l = append(l, strings.TrimPrefix(name, g.prefix))
+		}
+	}
+	return l
+}
+
+// Clear removes every object from the group, or from the namespace, and then
+// deletes their data.  Other callers that have read an object that is removed
+// will be unable to update it.
+func (g *Group) Clear(ctx context.Context) error {
+	var removed map[string]string
+	for {
+		ci, err := g.info(ctx)
+		if err != nil {
+			return err
+		}
+		removed = make(map[string]string)
+		for name, suffix := range ci.Locations {
+			if strings.HasPrefix(name, g.prefix) {
+				removed[name] = suffix
+				delete(ci.Locations, name)
+			}
+		}
+		if len(removed) == 0 {
 			return nil
 		}
-		if !b2.IsUpdateConflict(err) {
+		if err := g.save(ctx, ci); err != nil {
+			if err == errUpdateConflict {
+				continue
+			}
 			return err
 		}
-		// Bucket update conflict; try again.
+		break
+	}
+	var rerr error
+	for name, suffix := range removed {
+		if err := g.object(name, suffix).Delete(ctx); err != nil && !b2.IsNotExist(err) && rerr == nil {
+			rerr = err
+		}
 	}
+	return rerr
 }
 
-// List returns a list of all the group objects.
-func (g *Group) List(ctx context.Context) ([]string, error) {
+// A Snapshot is a read-only view of every object in a group as of a single
+// point in time.  Readers created from a snapshot see the versions that were
+// current when it was taken, even if the objects have been updated since,
+// which allows several objects to be read consistently with one another.
+//
+// Updating an object deletes the version it replaces, however, so reads from
+// an old snapshot can fail with a *StaleError.  Callers should take a new
+// snapshot and try again.
+type Snapshot struct {
+	// Serial identifies the state of the group that the snapshot captures.
+	// Two snapshots with the same serial are identical.
+	Serial int
+
+	g    *Group
+	locs map[string]string
+}
+
+// Snapshot captures the current state of the group.
+func (g *Group) Snapshot(ctx context.Context) (*Snapshot, error) {
 	ci, err := g.info(ctx)
 	if err != nil {
 		return nil, err
 	}
-	var l []string
-	for name := range ci.Locations {
-		l = append(l, name)
+	return &Snapshot{
+		Serial: ci.Serial,
+		g:      g,
+		locs:   ci.Locations,
+	}, nil
+}
+
+// List returns the names of the objects in the snapshot.
+func (s *Snapshot) List() []string {
+	return s.g.names(s.locs)
+}
+
+// NewReader returns a Reader for the version of the named object that was
+// current when the snapshot was taken.  Its Key may be passed to
+// Group.NewWriter, which will succeed only if the object has not since been
+// updated.
+func (s *Snapshot) NewReader(ctx context.Context, name string) (Reader, error) {
+	full := s.g.prefix + name
+	suffix, ok := s.locs[full]
+	if !ok {
+		return Reader{}, errNotInGroup
+	}
+	return Reader{
+		r: staleReader{
+			r:    s.g.object(full, suffix).NewReader(ctx),
+			name: name,
+			s:    s,
+		},
+		Key: suffix,
+	}, nil
+}
+
+// A StaleError is returned when reading from a Snapshot whose version of an
+// object has been replaced and deleted.
+type StaleError struct {
+	Name   string // The object that was replaced.
+	Serial int    // The serial of the snapshot.
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("%s: replaced since snapshot %d", e.Name, e.Serial)
+}
+
+type staleReader struct {
+	r    io.ReadCloser
+	name string
+	s    *Snapshot
+}
+
+func (sr staleReader) Read(p []byte) (int, error) {
+	n, err := sr.r.Read(p)
+	if b2.IsNotExist(err) {
+		err = &StaleError{Name: sr.name, Serial: sr.s.Serial}
 	}
-	return l, nil
+	return n, err
 }
 
+func (sr staleReader) Close() error { return sr.r.Close() }
+
 // A Mutex is a sync.Locker that is backed by data in B2.
 type Mutex struct {
 	g    *Group
@@ -357,6 +576,7 @@ type Mutex struct {
 // polling at 1 second intervals, until it can acquire the lock.
 func (m *Mutex) Lock() {
 	cont := errors.New("continue")
+	var waiting time.Time
 	for {
 		err := m.g.Operate(m.ctx, m.name, func(b []byte) ([]byte, error) {
 			if len(b) != 0 {
@@ -365,11 +585,17 @@ func (m *Mutex) Lock() {
 			return []byte{1}, nil
 		})
 		if err == nil {
+			if !waiting.IsZero() {
+				m.g.m.blocked(time.Since(waiting))
+			}
 			return
 		}
 		if err != cont {
 			panic(err)
 		}
+		if waiting.IsZero() {
+			waiting = time.Now()
+		}
 		time.Sleep(time.Second)
 	}
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -71,11 +73,125 @@ func TestOperationLive(t *testing.T) {
 	}
 }
 
+func TestOperationStatsLive(t *testing.T) {
+	ctx := context.Background()
+	bucket, done := startLiveTest(ctx, t)
+	defer done()
+
+	var mu sync.Mutex
+	events := make(map[EventKind]int)
+	g := NewGroup(bucket, "tester", OnEvent(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events[e.Kind]++
+	}))
+	ns := g.Namespace("counters")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				if err := ns.Operate(ctx, "n", func(b []byte) ([]byte, error) {
+					return append(b, 'x'), nil
+				}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	st := g.Stats()
+	if st.Operations != 12 {
+		t.Errorf("Operations: got %d, want 12", st.Operations)
+	}
+	if st.Conflicts != events[Conflict] || st.SaveConflicts != events[SaveConflict] || st.Operations != events[Done] {
+		t.Errorf("stats %+v do not match events %v", st, events)
+	}
+	if st.Saves < st.Operations+st.SaveConflicts {
+		t.Errorf("Saves: got %d, want at least %d", st.Saves, st.Operations+st.SaveConflicts)
+	}
+	t.Logf("stats: %+v", st)
+}
+
+func TestCacheLive(t *testing.T) {
+	ctx := context.Background()
+	bucket, done := startLiveTest(ctx, t)
+	defer done()
+
+	g := NewGroup(bucket, "tester")
+	name := "cached"
+	set := func(v string) {
+		if err := g.Operate(ctx, name, func([]byte) ([]byte, error) { return []byte(v), nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set("one")
+
+	c := g.NewCache(time.Hour)
+	v, err := c.Get(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v.Data) != "one" {
+		t.Errorf("Get: got %q, want %q", v.Data, "one")
+	}
+
+	// Within the window, the old value is served.
+	set("two")
+	v, err = c.Get(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v.Data) != "one" || v.Stale > time.Hour {
+		t.Errorf("Get: got %q, stale by up to %v; want %q, within an hour", v.Data, v.Stale, "one")
+	}
+
+	c.Invalidate()
+	v, err = c.Get(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v.Data) != "two" {
+		t.Errorf("Get after Invalidate: got %q, want %q", v.Data, "two")
+	}
+
+	v, err = c.Get(ctx, "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Data != nil || v.Key != "" {
+		t.Errorf("Get(missing): got %q (key %q), want nothing", v.Data, v.Key)
+	}
+}
+
 type jsonThing struct {
 	Boop   int `json:"boop_field"`
 	Thread int `json:"thread_id"`
 }
 
+func TestObjectsIn(t *testing.T) {
+	table, pub, img := &b2.Bucket{}, &b2.Bucket{}, &b2.Bucket{}
+	g := NewGroup(table, "tester", ObjectsIn("public/", pub), ObjectsIn("public/img/", img))
+	for _, e := range []struct {
+		name string
+		want *b2.Bucket
+	}{
+		{name: "private", want: table},
+		{name: "public/index", want: pub},
+		{name: "public/img/logo", want: img},
+	} {
+		if got := g.bucket(e.name); got != e.want {
+			t.Errorf("bucket(%q): got the wrong bucket", e.name)
+		}
+	}
+	if ns := g.Namespace("public"); ns.bucket("public/img/logo") != img {
+		t.Error("Namespace did not keep the group's routes")
+	}
+}
+
 func TestOperationJSONLive(t *testing.T) {
 	ctx := context.Background()
 	bucket, done := startLiveTest(ctx, t)
@@ -125,6 +241,111 @@ func TestOperationJSONLive(t *testing.T) {
 	}
 }
 
+func TestSnapshotLive(t *testing.T) {
+	ctx := context.Background()
+	bucket, done := startLiveTest(ctx, t)
+	defer done()
+
+	g := NewGroup(bucket, "tester")
+	set := func(name, val string) {
+		if err := g.Operate(ctx, name, func([]byte) ([]byte, error) {
+			return []byte(val), nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set("a", "1")
+	set("b", "1")
+
+	snap, err := g.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set("a", "2")
+
+	r, err := snap.NewReader(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "1" {
+		t.Errorf("snapshot read of b: got %q, want %q", b, "1")
+	}
+
+	r, err = snap.NewReader(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ioutil.ReadAll(r)
+	r.Close()
+	if _, ok := err.(*StaleError); !ok {
+		t.Errorf("snapshot read of replaced object: got %v, want *StaleError", err)
+	}
+}
+
+func TestNamespacesLive(t *testing.T) {
+	ctx := context.Background()
+	bucket, done := startLiveTest(ctx, t)
+	defer done()
+
+	g := NewGroup(bucket, "tester")
+	jobs := g.Namespace("jobs")
+	locks := g.Namespace("locks")
+	shard := g.Shard("queue")
+	for _, e := range []struct {
+		g    *Group
+		name string
+	}{
+		{g, "top"},
+		{jobs, "a"},
+		{jobs.Namespace("done"), "b"},
+		{locks, "a"},
+		{shard, "a"},
+	} {
+		if err := e.g.Operate(ctx, e.name, func([]byte) ([]byte, error) {
+			return []byte(e.name), nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(g *Group) string {
+		l, err := g.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(l)
+		return strings.Join(l, ",")
+	}
+	for _, e := range []struct {
+		g    *Group
+		want string
+	}{
+		{g, "jobs/a,jobs/done/b,locks/a,top"},
+		{jobs, "a,done/b"},
+		{locks, "a"},
+		{shard, "a"},
+	} {
+		if got := list(e.g); got != e.want {
+			t.Errorf("List: got %q, want %q", got, e.want)
+		}
+	}
+
+	if err := jobs.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := list(g), "locks/a,top"; got != want {
+		t.Errorf("List after Clear: got %q, want %q", got, want)
+	}
+	if got, want := list(shard), "a"; got != want {
+		t.Errorf("shard List after Clear: got %q, want %q", got, want)
+	}
+}
+
 func TestMutex(t *testing.T) {
 	ctx := context.Background()
 	bucket, done := startLiveTest(ctx, t)
@@ -0,0 +1,116 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consistent
+
+import (
+	"sync"
+	"time"
+)
+
+// An EventKind says what an Event reports.
+type EventKind int
+
+const (
+	// Conflict means that an object was changed by another caller while an
+	// operation was under way, so the operation is starting over.
+	Conflict EventKind = iota
+
+	// SaveConflict means that the group's table was changed by another
+	// caller while a writer was saving it, and the save is being retried.
+	// The object itself was not changed, so the writer does not fail.
+	SaveConflict
+
+	// Done means that an operation has finished, successfully or not.
+	Done
+)
+
+// An Event describes a step in an operation on a group, for contention
+// metrics.
+type Event struct {
+	Group   string        // The group's name.
+	Name    string        // The object, including any namespace prefix.
+	Kind    EventKind     // What happened.
+	Attempt int           // The attempt that this event ends, starting at 1.
+	Elapsed time.Duration // The time since the operation began.
+	Err     error         // For Done, the operation's result.
+}
+
+// Stats are running totals for a group and all of its namespaces and shards.
+type Stats struct {
+	Operations    int           // Operations completed, successfully or not.
+	Conflicts     int           // Operations restarted because of a Conflict.
+	Saves         int           // Attempts to save the group's table.
+	SaveConflicts int           // Saves retried because of a SaveConflict.
+	Blocked       time.Duration // Time lost to conflicts and waiting on a Mutex.
+}
+
+// A GroupOption alters the default behavior of a Group.
+type GroupOption func(*Group)
+
+// OnEvent returns a GroupOption that calls f when an operation on the group,
+// such as Operate or Writer.Close, runs into a conflict or finishes.  As with
+// b2.OnRetry, f is called synchronously and should return quickly; a rising
+// rate of conflicts means the group is becoming a point of contention, and
+// might be split with Shard.
+func OnEvent(f func(Event)) GroupOption {
+	return func(g *Group) {
+		g.m.onEvent = f
+	}
+}
+
+// groupMetrics is shared by a group and the views derived from it.
+type groupMetrics struct {
+	onEvent func(Event)
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+func (m *groupMetrics) report(g *Group, e Event) {
+	m.mu.Lock()
+	switch e.Kind {
+	case Conflict:
+		m.stats.Conflicts++
+	case SaveConflict:
+		m.stats.SaveConflicts++
+	case Done:
+		m.stats.Operations++
+	}
+	m.mu.Unlock()
+	if m.onEvent != nil {
+		e.Group = g.name
+		m.onEvent(e)
+	}
+}
+
+func (m *groupMetrics) saved() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Saves++
+}
+
+func (m *groupMetrics) blocked(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Blocked += d
+}
+
+// Stats returns the totals for the group, which include those of every
+// namespace and shard derived from it.
+func (g *Group) Stats() Stats {
+	g.m.mu.Lock()
+	defer g.m.mu.Unlock()
+	return g.m.stats
+}
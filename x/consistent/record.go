@@ -0,0 +1,195 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consistent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/burner-account/blazer/b2"
+)
+
+// A Record is a value of type T, stored as JSON in a group object.  The stored
+// value is tagged with a schema version, so that values written by older
+// versions of a program can be recognized and migrated rather than decoded
+// into a struct that no longer matches.
+type Record[T any] struct {
+	g       *Group
+	name    string
+	schema  int
+	migrate func(from int, data json.RawMessage) (T, error)
+	codec   Codec // if nil, values are stored as JSON
+}
+
+// NewRecord returns a Record for the named object in the group.  schema is the
+// version of T's layout; it should be incremented whenever T changes in a way
+// that older stored values would not decode correctly.  When a stored value
+// has an older schema, migrate is called with that schema and the stored JSON,
+// and should return the equivalent T.  If migrate is nil, older values cannot
+// be read.  Values with a newer schema are never read; this prevents an old
+// program from overwriting data it does not understand.
+//
+// Record objects should only be written through a Record.
+func NewRecord[T any](g *Group, name string, schema int, migrate func(from int, data json.RawMessage) (T, error)) *Record[T] {
+	return &Record[T]{
+		g:       g,
+		name:    name,
+		schema:  schema,
+		migrate: migrate,
+	}
+}
+
+// SetCodec makes the record store its value with c instead of as JSON.  The
+// schema version is then kept in a short header ahead of the encoded value,
+// rather than in a JSON envelope, so the value is encoded only once; migrate
+// is passed the stored bytes as c encoded them.  Values stored with one codec
+// cannot be read with another, so SetCodec should be called before the
+// record is first used.
+func (r *Record[T]) SetCodec(c Codec) {
+	r.codec = c
+}
+
+// encodeRaw stores v with the record's codec, after a header of the schema
+// version and a newline.
+func (r *Record[T]) encodeRaw(v T) ([]byte, error) {
+	data, err := r.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(strconv.Itoa(r.schema)+"\n"), data...), nil
+}
+
+// parseRaw splits a value stored with a codec into its schema and data.
+func (r *Record[T]) parseRaw(b []byte) (*recordJSON, error) {
+	if len(b) == 0 {
+		return &recordJSON{}, nil
+	}
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return nil, fmt.Errorf("%s: no schema header", r.name)
+	}
+	schema, err := strconv.Atoi(string(b[:i]))
+	if err != nil {
+		return nil, fmt.Errorf("%s: bad schema header: %v", r.name, err)
+	}
+	return &recordJSON{Schema: schema, Data: b[i+1:]}, nil
+}
+
+type recordJSON struct {
+	Schema int             `json:"schema"`
+	Data   json.RawMessage `json:"data"`
+}
+
+func (r *Record[T]) decode(env *recordJSON) (T, error) {
+	var v T
+	switch {
+	case env.Data == nil:
+		// Nothing stored yet.
+		return v, nil
+	case env.Schema == r.schema:
+		var err error
+		if r.codec != nil {
+			err = r.codec.Unmarshal(env.Data, &v)
+		} else {
+			err = json.Unmarshal(env.Data, &v)
+		}
+		return v, err
+	case env.Schema > r.schema:
+		return v, fmt.Errorf("%s: stored schema %d is newer than %d", r.name, env.Schema, r.schema)
+	case r.migrate == nil:
+		return v, fmt.Errorf("%s: stored schema %d is older than %d, and there is no migration", r.name, env.Schema, r.schema)
+	}
+	return r.migrate(env.Schema, env.Data)
+}
+
+// Load returns the current value of the record, or the zero value of T if none
+// has been stored.  Values with an older schema are migrated, but are not
+// written back.
+func (r *Record[T]) Load(ctx context.Context) (T, error) {
+	var v T
+	rd, err := r.g.NewReader(ctx, r.name)
+	if err == errNotInGroup {
+		return v, nil
+	}
+	if err != nil {
+		return v, err
+	}
+	defer rd.Close()
+	if r.codec != nil {
+		b, err := ioutil.ReadAll(rd)
+		if err != nil && !b2.IsNotExist(err) {
+			return v, err
+		}
+		env, err := r.parseRaw(b)
+		if err != nil {
+			return v, err
+		}
+		return r.decode(env)
+	}
+	env := &recordJSON{}
+	if err := json.NewDecoder(rd).Decode(env); err != nil && err != io.EOF && !b2.IsNotExist(err) {
+		return v, err
+	}
+	return r.decode(env)
+}
+
+// Store replaces the value of the record with v.
+func (r *Record[T]) Store(ctx context.Context, v T) error {
+	return r.Operate(ctx, func(p *T) error {
+		*p = v
+		return nil
+	})
+}
+
+// Operate calls f with a pointer to the current value of the record, and
+// stores the result if f returns no error.  As with Group.Operate, f may be
+// called any number of times.
+func (r *Record[T]) Operate(ctx context.Context, f func(*T) error) error {
+	if r.codec != nil {
+		return r.g.Operate(ctx, r.name, func(b []byte) ([]byte, error) {
+			env, err := r.parseRaw(b)
+			if err != nil {
+				return nil, err
+			}
+			v, err := r.decode(env)
+			if err != nil {
+				return nil, err
+			}
+			if err := f(&v); err != nil {
+				return nil, err
+			}
+			return r.encodeRaw(v)
+		})
+	}
+	return r.g.OperateJSON(ctx, r.name, recordJSON{}, func(in interface{}) (interface{}, error) {
+		v, err := r.decode(in.(*recordJSON))
+		if err != nil {
+			return nil, err
+		}
+		if err := f(&v); err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return &recordJSON{Schema: r.schema, Data: data}, nil
+	})
+}
@@ -0,0 +1,145 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consistent
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type userV2 struct {
+	First string `json:"first"`
+	Last  string `json:"last"`
+}
+
+func TestRecordDecode(t *testing.T) {
+	migrate := func(from int, data json.RawMessage) (userV2, error) {
+		var v1 struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return userV2{}, err
+		}
+		return userV2{First: v1.Name}, nil
+	}
+
+	table := []struct {
+		env     string
+		migrate func(int, json.RawMessage) (userV2, error)
+		want    userV2
+		wantErr bool
+	}{
+		{
+			env: `{}`,
+		},
+		{
+			env:  `{"schema": 2, "data": {"first": "Ada", "last": "Lovelace"}}`,
+			want: userV2{First: "Ada", Last: "Lovelace"},
+		},
+		{
+			env:     `{"schema": 1, "data": {"name": "Ada"}}`,
+			migrate: migrate,
+			want:    userV2{First: "Ada"},
+		},
+		{
+			env:     `{"schema": 1, "data": {"name": "Ada"}}`,
+			wantErr: true,
+		},
+		{
+			env:     `{"schema": 3, "data": {"given": "Ada"}}`,
+			migrate: migrate,
+			wantErr: true,
+		},
+	}
+
+	for _, e := range table {
+		r := NewRecord(nil, "user", 2, e.migrate)
+		env := &recordJSON{}
+		if err := json.Unmarshal([]byte(e.env), env); err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.decode(env)
+		if (err != nil) != e.wantErr {
+			t.Errorf("decode(%s): got error %v, want error: %v", e.env, err, e.wantErr)
+			continue
+		}
+		if got != e.want {
+			t.Errorf("decode(%s): got %+v, want %+v", e.env, got, e.want)
+		}
+	}
+}
+
+// rot13 is a Codec that stores JSON with its letters rotated, so that the
+// stored bytes are not themselves valid JSON.
+type rot13 struct{}
+
+func (rot13) rot(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z':
+			c = 'a' + (c-'a'+13)%26
+		case c >= 'A' && c <= 'Z':
+			c = 'A' + (c-'A'+13)%26
+		}
+		out[i] = c
+	}
+	return out
+}
+
+func (r rot13) Marshal(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	return r.rot(b), err
+}
+
+func (r rot13) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(r.rot(data), v)
+}
+
+func TestRecordCodec(t *testing.T) {
+	r := NewRecord[userV2](nil, "user", 2, nil)
+	r.SetCodec(rot13{})
+	want := userV2{First: "Ada", Last: "Lovelace"}
+	b, err := r.encodeRaw(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "2\n{") {
+		t.Errorf("encodeRaw: got %q, want a schema header followed by the encoded value", b)
+	}
+	env, err := r.parseRaw(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.decode(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("decode: got %+v, want %+v", got, want)
+	}
+
+	env, err = r.parseRaw(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.decode(env); err != nil || got != (userV2{}) {
+		t.Errorf("decode of nothing: got %+v, %v; want zero value", got, err)
+	}
+	if _, err := r.parseRaw([]byte("{}")); err == nil {
+		t.Error("parseRaw without a header: got no error")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consistent
+
+import (
+	"strings"
+
+	"github.com/burner-account/blazer/b2"
+)
+
+// routes maps object name prefixes to the buckets that hold them.
+type routes struct {
+	r []route
+}
+
+type route struct {
+	prefix string
+	b      *b2.Bucket
+}
+
+// ObjectsIn returns a GroupOption that keeps the group's objects whose names
+// begin with prefix in bucket, rather than in the bucket that holds the
+// group's table.  This allows, for instance, the table to be kept in a small
+// private bucket while the objects are served from a public one.  Updates
+// remain atomic, since they are decided by the table alone.  If several
+// prefixes match a name, the longest wins; an empty prefix matches every
+// object.
+//
+// Prefixes apply to whole object names, including those of any namespace.
+// Every process that uses the group must give it the same routes, or it will
+// look for objects in the wrong place.
+func ObjectsIn(prefix string, bucket *b2.Bucket) GroupOption {
+	return func(g *Group) {
+		g.routes.r = append(g.routes.r, route{prefix: prefix, b: bucket})
+	}
+}
+
+// bucket returns the bucket that holds the named object.
+func (g *Group) bucket(name string) *b2.Bucket {
+	b := g.b
+	best := -1
+	for _, r := range g.routes.r {
+		if strings.HasPrefix(name, r.prefix) && len(r.prefix) > best {
+			b = r.b
+			best = len(r.prefix)
+		}
+	}
+	return b
+}
+
+// object returns the given version of the named object, whose name already
+// includes any namespace prefix.
+func (g *Group) object(name, suffix string) *b2.Object {
+	return g.bucket(name).Object(name + "/" + suffix)
+}
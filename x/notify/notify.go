@@ -0,0 +1,201 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify receives B2 event notifications.
+//
+// B2 can be configured to POST a notification to a webhook URL whenever
+// objects in a bucket are created, deleted, or hidden.  Handler verifies and
+// decodes these requests:
+//
+//	http.Handle("/b2-events", notify.Handler(secret, func(ctx context.Context, e *notify.Event) error {
+//		log.Printf("%s: %s/%s", e.Type, e.BucketName, e.ObjectName)
+//		return nil
+//	}))
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/burner-account/blazer/internal/blog"
+)
+
+// SignatureHeader is the header in which B2 sends the notification's
+// signature.
+const SignatureHeader = "X-Bz-Event-Notification-Signature"
+
+// Event types sent by B2.
+const (
+	ObjectCreatedUpload          = "b2:ObjectCreated:Upload"
+	ObjectCreatedMultipartUpload = "b2:ObjectCreated:MultipartUpload"
+	ObjectCreatedCopy            = "b2:ObjectCreated:Copy"
+	ObjectCreatedReplica         = "b2:ObjectCreated:Replica"
+	ObjectDeletedDelete          = "b2:ObjectDeleted:Delete"
+	ObjectDeletedLifecycleRule   = "b2:ObjectDeleted:LifecycleRule"
+	HideMarkerCreatedHide        = "b2:HideMarkerCreated:Hide"
+	HideMarkerCreatedLifecycle   = "b2:HideMarkerCreated:LifecycleRule"
+	TestEvent                    = "b2:TestEvent"
+)
+
+// maxBody bounds the size of a notification request.  B2 batches at most a
+// few dozen events per request, which is well within this.
+const maxBody = 1 << 20
+
+var (
+	errNoSignature  = errors.New("notify: request is not signed")
+	errBadSignature = errors.New("notify: signature does not match")
+)
+
+// Event is a single B2 event notification.
+type Event struct {
+	AccountID       string
+	BucketID        string
+	BucketName      string
+	ID              string    // Unique to the event; B2 may deliver an event more than once.
+	Timestamp       time.Time // When the event occurred.
+	Type            string    // One of the event type constants, such as ObjectCreatedUpload.
+	Version         int       // The version of the event's format.
+	MatchedRuleName string    // The notification rule that matched the event.
+	ObjectName      string
+	ObjectSize      int64  // Zero for deletions and hide markers.
+	ObjectVersionID string // The B2 file ID of the affected version.
+}
+
+type eventJSON struct {
+	AccountID       string `json:"accountId"`
+	BucketID        string `json:"bucketId"`
+	BucketName      string `json:"bucketName"`
+	EventID         string `json:"eventId"`
+	EventTimestamp  int64  `json:"eventTimestamp"`
+	EventType       string `json:"eventType"`
+	EventVersion    int    `json:"eventVersion"`
+	MatchedRuleName string `json:"matchedRuleName"`
+	ObjectName      string `json:"objectName"`
+	ObjectSize      int64  `json:"objectSize"`
+	ObjectVersionID string `json:"objectVersionId"`
+}
+
+type payloadJSON struct {
+	Events []eventJSON `json:"events"`
+}
+
+// Verify checks that sig, the value of the SignatureHeader, is a valid
+// signature of body for the notification rule's signing secret.
+func Verify(secret string, body []byte, sig string) error {
+	if sig == "" {
+		return errNoSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	want := mac.Sum(nil)
+	for _, s := range strings.Split(sig, ",") {
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, "v1=") {
+			continue
+		}
+		got, err := hex.DecodeString(strings.TrimPrefix(s, "v1="))
+		if err != nil {
+			continue
+		}
+		if hmac.Equal(got, want) {
+			return nil
+		}
+	}
+	return errBadSignature
+}
+
+// Parse decodes a notification payload.
+func Parse(body []byte) ([]*Event, error) {
+	var p payloadJSON
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("notify: bad payload: %v", err)
+	}
+	var events []*Event
+	for _, e := range p.Events {
+		events = append(events, &Event{
+			AccountID:       e.AccountID,
+			BucketID:        e.BucketID,
+			BucketName:      e.BucketName,
+			ID:              e.EventID,
+			Timestamp:       time.Unix(e.EventTimestamp/1e3, (e.EventTimestamp%1e3)*1e6),
+			Type:            e.EventType,
+			Version:         e.EventVersion,
+			MatchedRuleName: e.MatchedRuleName,
+			ObjectName:      e.ObjectName,
+			ObjectSize:      e.ObjectSize,
+			ObjectVersionID: e.ObjectVersionID,
+		})
+	}
+	return events, nil
+}
+
+// ParseRequest verifies the signature on a notification request and decodes
+// its events.  If secret is empty, the signature is not checked; this should
+// only be done if the webhook is otherwise authenticated.
+func ParseRequest(r *http.Request, secret string) ([]*Event, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBody))
+	if err != nil {
+		return nil, err
+	}
+	if secret != "" {
+		if err := Verify(secret, body, r.Header.Get(SignatureHeader)); err != nil {
+			return nil, err
+		}
+	}
+	return Parse(body)
+}
+
+// Handler returns an http.Handler that accepts B2 event notifications signed
+// with secret, and calls f once for each event, in order.  If f returns an
+// error, the remaining events are not processed and B2 is told to try the
+// whole request again later, so f should tolerate events it has already seen;
+// Event.ID can be used to recognize them.
+func Handler(secret string, f func(context.Context, *Event) error) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			http.Error(rw, "notifications must be POSTed", http.StatusMethodNotAllowed)
+			return
+		}
+		events, err := ParseRequest(req, secret)
+		switch err {
+		case nil:
+		case errNoSignature, errBadSignature:
+			blog.V(1).Infof("notify: rejecting request from %s: %v", req.RemoteAddr, err)
+			http.Error(rw, err.Error(), http.StatusUnauthorized)
+			return
+		default:
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, e := range events {
+			if err := f(req.Context(), e); err != nil {
+				blog.V(1).Infof("notify: event %s: %v", e.ID, err)
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,153 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const payload = `{
+  "events": [
+    {
+      "accountId": "e85c6a500333",
+      "bucketId": "aea8c5bc362ef55b86a90517",
+      "bucketName": "mybucket",
+      "eventId": "abc123",
+      "eventTimestamp": 1684793309123,
+      "eventType": "b2:ObjectCreated:Upload",
+      "eventVersion": 1,
+      "matchedRuleName": "new-objects",
+      "objectName": "photos/cat.jpg",
+      "objectSize": 12345,
+      "objectVersionId": "4_zaea8c5bc362ef55b86a90517_f1"
+    },
+    {
+      "eventId": "def456",
+      "eventTimestamp": 1684793310000,
+      "eventType": "b2:HideMarkerCreated:Hide",
+      "objectName": "photos/dog.jpg"
+    }
+  ]
+}`
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return fmt.Sprintf("v1=%x", mac.Sum(nil))
+}
+
+func TestHandler(t *testing.T) {
+	table := []struct {
+		method  string
+		sig     string
+		body    string
+		fail    bool
+		status  int
+		objects []string
+	}{
+		{
+			method:  "POST",
+			sig:     sign("secret", payload),
+			body:    payload,
+			status:  http.StatusOK,
+			objects: []string{"photos/cat.jpg", "photos/dog.jpg"},
+		},
+		{
+			method:  "POST",
+			sig:     "v1=00, " + sign("secret", payload),
+			body:    payload,
+			status:  http.StatusOK,
+			objects: []string{"photos/cat.jpg", "photos/dog.jpg"},
+		},
+		{
+			method: "POST",
+			sig:    sign("wrong", payload),
+			body:   payload,
+			status: http.StatusUnauthorized,
+		},
+		{
+			method: "POST",
+			body:   payload,
+			status: http.StatusUnauthorized,
+		},
+		{
+			method: "POST",
+			sig:    sign("secret", "{"),
+			body:   "{",
+			status: http.StatusBadRequest,
+		},
+		{
+			method: "GET",
+			status: http.StatusMethodNotAllowed,
+		},
+		{
+			method:  "POST",
+			sig:     sign("secret", payload),
+			body:    payload,
+			fail:    true,
+			status:  http.StatusInternalServerError,
+			objects: []string{"photos/cat.jpg"},
+		},
+	}
+
+	for _, e := range table {
+		var got []string
+		h := Handler("secret", func(_ context.Context, ev *Event) error {
+			got = append(got, ev.ObjectName)
+			if e.fail {
+				return errors.New("try again")
+			}
+			return nil
+		})
+		req := httptest.NewRequest(e.method, "/", strings.NewReader(e.body))
+		if e.sig != "" {
+			req.Header.Set(SignatureHeader, e.sig)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != e.status {
+			t.Errorf("%s %q: got status %d, want %d", e.method, e.sig, rec.Code, e.status)
+		}
+		if strings.Join(got, ",") != strings.Join(e.objects, ",") {
+			t.Errorf("%s %q: got events for %v, want %v", e.method, e.sig, got, e.objects)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	events, err := Parse([]byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	e := events[0]
+	if e.Type != ObjectCreatedUpload || e.ObjectSize != 12345 || e.BucketName != "mybucket" || e.ID != "abc123" {
+		t.Errorf("bad event: %+v", e)
+	}
+	if want := time.Unix(1684793309, 123e6); !e.Timestamp.Equal(want) {
+		t.Errorf("Timestamp: got %v, want %v", e.Timestamp, want)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package obscure stores objects in B2 under names that do not reveal the
+// names they are known by.
+//
+// A public bucket served through friendly URLs exposes the names of its
+// objects, and with them the layout of whatever the objects came from.  A
+// Bucket instead stores each object under the HMAC of its name, so that
+// "reports/2018/q3.pdf" is kept as a string of hex digits that says nothing
+// about where it sits, and a name can be turned into its stored name only by
+// someone with the key.
+//
+// To find the name an object is known by, and to list them, a Bucket keeps an
+// index in a consistent group, with one small group object for each stored
+// name, so that recording a name does not rewrite every other.  The index
+// holds every name in the clear, and so should be kept somewhere private:
+//
+//	private, err := client.Bucket(ctx, "private")
+//	public, err := client.Bucket(ctx, "public")
+//	b := obscure.New(public, key, consistent.NewGroup(private, "names"))
+//	w := b.NewWriter(ctx, "reports/2018/q3.pdf")
+package obscure
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/burner-account/blazer/b2"
+	"github.com/burner-account/blazer/x/consistent"
+)
+
+const (
+	// indexNamespace is the namespace of the group that holds the index.
+	// Within it, each stored name is a namespace of its own, holding the
+	// name it was stored for in entryName.
+	indexNamespace = "index"
+	entryName      = "name"
+)
+
+// A Bucket is a view of a b2.Bucket in which objects are addressed by their
+// names, but stored under names derived from them.
+type Bucket struct {
+	b   *b2.Bucket
+	key []byte
+	g   *consistent.Group
+}
+
+// New returns a Bucket that stores objects in bucket under names obscured with
+// key, and indexes them in g.  The same key must be used every time the bucket
+// is opened, or objects will not be found.
+func New(bucket *b2.Bucket, key []byte, g *consistent.Group) *Bucket {
+	return &Bucket{
+		b:   bucket,
+		key: append([]byte(nil), key...),
+		g:   g,
+	}
+}
+
+// StoredName returns the name under which the object with the given name is
+// stored: the hex-encoded HMAC-SHA256 of name.
+func (b *Bucket) StoredName(name string) string {
+	mac := hmac.New(sha256.New, b.key)
+	mac.Write([]byte(name))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Object returns the stored object for the given name.  It can be read, have
+// its URL taken, or be deleted like any other object, but objects written
+// through it, or deleted with it, are not recorded in the index; use
+// NewWriter and Delete for that.
+func (b *Bucket) Object(name string) *b2.Object {
+	return b.b.Object(b.StoredName(name))
+}
+
+// NewReader returns a reader for the object with the given name.
+func (b *Bucket) NewReader(ctx context.Context, name string) *b2.Reader {
+	return b.Object(name).NewReader(ctx)
+}
+
+// A Writer writes an object to its stored name, and records the name in the
+// index when it is closed.  Its b2.Writer fields may be set as usual.
+type Writer struct {
+	*b2.Writer
+
+	ctx  context.Context
+	b    *Bucket
+	name string
+}
+
+// NewWriter returns a writer for the object with the given name.
+func (b *Bucket) NewWriter(ctx context.Context, name string, opts ...b2.WriterOption) *Writer {
+	return &Writer{
+		Writer: b.Object(name).NewWriter(ctx, opts...),
+		ctx:    ctx,
+		b:      b,
+		name:   name,
+	}
+}
+
+// Close finishes the object and adds its name to the index.  If the object is
+// written but the index cannot be updated, Close returns an error, and the
+// object can be read by name but not listed; writing it again repairs this.
+func (w *Writer) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		return err
+	}
+	return w.b.entry(w.b.StoredName(w.name)).Operate(w.ctx, entryName, func([]byte) ([]byte, error) {
+		return []byte(w.name), nil
+	})
+}
+
+// Delete removes the object with the given name, and its entry in the index.
+// An entry whose object is already gone is removed without error.
+func (b *Bucket) Delete(ctx context.Context, name string) error {
+	if err := b.Object(name).Delete(ctx); err != nil && !b2.IsNotExist(err) {
+		return err
+	}
+	return b.entry(b.StoredName(name)).Clear(ctx)
+}
+
+// Name returns the name of the object stored as stored.  It returns an error
+// if stored is not in the index, or if the index entry was not made with this
+// bucket's key.
+func (b *Bucket) Name(ctx context.Context, stored string) (string, error) {
+	s, err := b.g.Namespace(indexNamespace).Snapshot(ctx)
+	if err != nil {
+		return "", err
+	}
+	var found bool
+	for _, e := range s.List() {
+		if e == stored+"/"+entryName {
+			found = true
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("obscure: %s is not in the index", stored)
+	}
+	name, err := readEntry(ctx, s, stored)
+	if err != nil {
+		return "", err
+	}
+	if b.StoredName(name) != stored {
+		return "", fmt.Errorf("obscure: index entry for %s does not match the key", stored)
+	}
+	return name, nil
+}
+
+// List returns, in order, the names in the index that begin with prefix.
+func (b *Bucket) List(ctx context.Context, prefix string) ([]string, error) {
+	s, err := b.g.Namespace(indexNamespace).Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var l []string
+	for _, e := range s.List() {
+		stored := strings.TrimSuffix(e, "/"+entryName)
+		if stored == e {
+			continue
+		}
+		name, err := readEntry(ctx, s, stored)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(name, prefix) {
+			l = append(l, name)
+		}
+	}
+	sort.Strings(l)
+	return l, nil
+}
+
+// entry returns the namespace that holds the index entry for stored.
+func (b *Bucket) entry(stored string) *consistent.Group {
+	return b.g.Namespace(indexNamespace).Namespace(stored)
+}
+
+// readEntry reads the name recorded for stored in a snapshot of the index.
+func readEntry(ctx context.Context, s *consistent.Snapshot, stored string) (string, error) {
+	r, err := s.NewReader(ctx, stored+"/"+entryName)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	name, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(name), nil
+}
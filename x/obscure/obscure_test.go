@@ -0,0 +1,53 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package obscure
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/burner-account/blazer/b2"
+)
+
+func TestStoredName(t *testing.T) {
+	key := []byte("secret")
+	b := New(&b2.Bucket{}, key, nil)
+	key[0] = 'S' // New must not keep the caller's slice
+
+	hexName := regexp.MustCompile("^[0-9a-f]{64}$")
+	a := b.StoredName("reports/2018/q3.pdf")
+	if !hexName.MatchString(a) {
+		t.Errorf("StoredName: got %q, want 64 hex digits", a)
+	}
+	if got := b.StoredName("reports/2018/q3.pdf"); got != a {
+		t.Errorf("StoredName is not stable: got %q, then %q", a, got)
+	}
+	if got := b.StoredName("reports/2018/q4.pdf"); got == a {
+		t.Errorf("different names stored as %q", got)
+	}
+	if got := New(&b2.Bucket{}, []byte("secret"), nil).StoredName("reports/2018/q3.pdf"); got != a {
+		t.Errorf("same key: got %q, want %q", got, a)
+	}
+	if got := New(&b2.Bucket{}, []byte("other"), nil).StoredName("reports/2018/q3.pdf"); got == a {
+		t.Errorf("different keys stored the name as %q", got)
+	}
+	// The HMAC-SHA256 of "" under "secret".
+	if got, want := b.StoredName(""), "f9e66e179b6747ae54108f82f8ade8b3c25d76fd30afde6c395822c530196169"; got != want {
+		t.Errorf("StoredName(\"\"): got %q, want %q", got, want)
+	}
+	if got := b.Object("reports/2018/q3.pdf").Name(); got != a {
+		t.Errorf("Object: got name %q, want %q", got, a)
+	}
+}
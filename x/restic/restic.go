@@ -0,0 +1,266 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package restic serves a restic repository stored in B2 over restic's REST
+// backend protocol.
+//
+// Restic can talk to B2 itself, but running it through a gateway built on
+// blazer gives it the client's connection pooling, request limits, retry
+// metrics, and, for testing, fault injection with x/transport:
+//
+//	client, err := b2.NewClient(ctx, id, key, b2.MaxConcurrentRequests(8, 8, 4))
+//	bucket, err := client.Bucket(ctx, "backups")
+//	http.Handle("/", &restic.Handler{Bucket: bucket, Prefix: "laptop/"})
+//
+// and then
+//
+//	restic -r rest:http://localhost:8000/ backup ~
+//
+// Objects are laid out as restic's own B2 backend lays them out, so the same
+// repository can also be opened with "restic -r b2:backups:laptop".
+package restic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/burner-account/blazer/b2"
+)
+
+// v2 is the media type with which restic asks for version 2 of the protocol,
+// in which listings include sizes.
+const v2 = "application/vnd.x.restic.rest.v2"
+
+var fileTypes = map[string]bool{
+	"data":      true,
+	"keys":      true,
+	"locks":     true,
+	"snapshots": true,
+	"index":     true,
+}
+
+// Handler serves one restic repository.
+type Handler struct {
+	// Bucket holds the repository.
+	Bucket *b2.Bucket
+
+	// Prefix is prepended to the name of every object in the repository.  It
+	// should be empty or end with a slash.
+	Prefix string
+
+	// AppendOnly, if set, refuses to delete or overwrite anything but locks,
+	// as the rest-server's --append-only flag does.
+	AppendOnly bool
+}
+
+// objectName returns the object for the given request path, which is either
+// "/config" or "/<type>/<name>".  Data files are kept beneath a directory
+// named for the first two characters of their names.
+func objectName(p string) (string, bool) {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "config":
+		return "config", true
+	case len(parts) != 2 || !fileTypes[parts[0]] || parts[1] == "" || strings.HasPrefix(parts[1], "."):
+		return "", false
+	case parts[0] == "data":
+		if len(parts[1]) < 2 {
+			return "", false
+		}
+		return "data/" + parts[1][:2] + "/" + parts[1], true
+	}
+	return parts[0] + "/" + parts[1], true
+}
+
+// parseRange parses a Range header of the form that restic sends,
+// "bytes=first-last" or "bytes=first-", against an object of the given size,
+// and returns the offset and length to read.
+func parseRange(h string, size int64) (int64, int64, error) {
+	spec := strings.TrimPrefix(h, "bytes=")
+	if spec == h || strings.Contains(spec, ",") {
+		return 0, 0, fmt.Errorf("unsupported range %q", h)
+	}
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return 0, 0, fmt.Errorf("unsupported range %q", h)
+	}
+	first, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || first < 0 || first >= size {
+		return 0, 0, fmt.Errorf("bad range %q for %d bytes", h, size)
+	}
+	last := size - 1
+	if parts[1] != "" {
+		l, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || l < first {
+			return 0, 0, fmt.Errorf("bad range %q", h)
+		}
+		if l < last {
+			last = l
+		}
+	}
+	return first, last - first + 1, nil
+}
+
+// ServeHTTP implements the restic REST protocol.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.Trim(r.URL.Path, "/")
+	switch {
+	case p == "":
+		h.serveRoot(w, r)
+	case fileTypes[p]:
+		if r.Method != "GET" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.list(w, r, p)
+	default:
+		name, ok := objectName(p)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		h.serveObject(w, r, name)
+	}
+}
+
+func (h *Handler) serveRoot(w http.ResponseWriter, r *http.Request) {
+	// B2 has no directories to create.
+	if r.Method == "POST" && r.URL.Query().Get("create") == "true" {
+		return
+	}
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
+
+type listEntry struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, typ string) {
+	ctx := r.Context()
+	iter := h.Bucket.List(ctx, b2.ListPrefix(h.Prefix+typ+"/"))
+	var names []string
+	entries := []listEntry{}
+	for iter.Next() {
+		obj := iter.Object()
+		names = append(names, path.Base(obj.Name()))
+		// The listing has the size already; v1 clients don't want it.
+		size, _ := obj.ListedSize()
+		entries = append(entries, listEntry{Name: path.Base(obj.Name()), Size: size})
+	}
+	if err := iter.Err(); err != nil {
+		httpError(w, err)
+		return
+	}
+	var v interface{} = names
+	if r.Header.Get("Accept") == v2 {
+		w.Header().Set("Content-Type", v2)
+		v = entries
+	} else {
+		w.Header().Set("Content-Type", "application/vnd.x.restic.rest.v1")
+		if names == nil {
+			v = []string{}
+		}
+	}
+	json.NewEncoder(w).Encode(v)
+}
+
+func (h *Handler) serveObject(w http.ResponseWriter, r *http.Request, name string) {
+	ctx := r.Context()
+	obj := h.Bucket.Object(h.Prefix + name)
+	switch r.Method {
+	case "HEAD", "GET":
+		attrs, err := obj.Attrs(ctx)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		off, length := int64(0), attrs.Size
+		status := http.StatusOK
+		if rh := r.Header.Get("Range"); rh != "" {
+			off, length, err = parseRange(rh, attrs.Size)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", off, off+length-1, attrs.Size))
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+		if r.Method == "HEAD" {
+			w.WriteHeader(status)
+			return
+		}
+		or := obj.NewRangeReader(ctx, off, length)
+		defer or.Close()
+		w.WriteHeader(status)
+		io.Copy(w, or)
+
+	case "POST":
+		if h.AppendOnly && !strings.HasPrefix(name, "locks/") {
+			if _, err := obj.Attrs(ctx); err == nil {
+				http.Error(w, "file exists", http.StatusForbidden)
+				return
+			}
+		}
+		wctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		ow := obj.NewWriter(wctx)
+		n, err := io.Copy(ow, r.Body)
+		if err == nil && r.ContentLength >= 0 && n != r.ContentLength {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			// A truncated pack or index would corrupt the repository, so
+			// abandon the upload rather than close it.
+			cancel()
+			ow.Close()
+			httpError(w, err)
+			return
+		}
+		if err := ow.Close(); err != nil {
+			httpError(w, err)
+			return
+		}
+
+	case "DELETE":
+		if h.AppendOnly && !strings.HasPrefix(name, "locks/") {
+			http.Error(w, "repository is append-only", http.StatusForbidden)
+			return
+		}
+		// Deleting only the newest version would bring back an older one.
+		if err := obj.DeleteAllVersions(ctx); err != nil {
+			httpError(w, err)
+			return
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	if b2.IsNotExist(err) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,66 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restic
+
+import "testing"
+
+func TestObjectName(t *testing.T) {
+	table := []struct {
+		path, want string
+		ok         bool
+	}{
+		{path: "/config", want: "config", ok: true},
+		{path: "/keys/abcdef", want: "keys/abcdef", ok: true},
+		{path: "/data/abcdef", want: "data/ab/abcdef", ok: true},
+		{path: "/data/a"},
+		{path: "/keys/"},
+		{path: "/keys/.."},
+		{path: "/other/abcdef"},
+		{path: "/keys/ab/cdef"},
+	}
+	for _, e := range table {
+		got, ok := objectName(e.path)
+		if got != e.want || ok != e.ok {
+			t.Errorf("objectName(%q): got %q, %v; want %q, %v", e.path, got, ok, e.want, e.ok)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	table := []struct {
+		h           string
+		size        int64
+		off, length int64
+		bad         bool
+	}{
+		{h: "bytes=0-99", size: 1000, off: 0, length: 100},
+		{h: "bytes=900-", size: 1000, off: 900, length: 100},
+		{h: "bytes=900-2000", size: 1000, off: 900, length: 100},
+		{h: "bytes=1000-", size: 1000, bad: true},
+		{h: "bytes=-100", size: 1000, bad: true},
+		{h: "bytes=0-1,5-6", size: 1000, bad: true},
+		{h: "lines=0-1", size: 1000, bad: true},
+	}
+	for _, e := range table {
+		off, length, err := parseRange(e.h, e.size)
+		if (err != nil) != e.bad {
+			t.Errorf("parseRange(%q, %d): got error %v", e.h, e.size, err)
+			continue
+		}
+		if !e.bad && (off != e.off || length != e.length) {
+			t.Errorf("parseRange(%q, %d): got %d+%d, want %d+%d", e.h, e.size, off, length, e.off, e.length)
+		}
+	}
+}
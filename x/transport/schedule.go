@@ -0,0 +1,118 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A Fault is a single failure in a Schedule.  If Status is set, the request is
+// not sent and a response with that status and Body is returned instead.
+// Otherwise, if Stall is set, the request is held for that long (or until its
+// context is done) before being sent.
+type Fault struct {
+	Status int    `json:"status,omitempty"`
+	Body   string `json:"body,omitempty"`
+	Stall  string `json:"stall,omitempty"` // e.g. "1.5s"; see time.ParseDuration
+}
+
+func (f Fault) stall() time.Duration {
+	d, _ := time.ParseDuration(f.Stall)
+	return d
+}
+
+// A FiredFault records a fault that was injected into a request.
+type FiredFault struct {
+	Ordinal int
+	Method  string // the B2 API method, if known
+	Path    string
+	Fault   Fault
+}
+
+// A Schedule is a deterministic list of faults, keyed by request ordinal.  The
+// first request that the transport sees is ordinal 1.  Only requests that
+// match any MatchPathSubstring options are counted.
+//
+// Schedules are usually read from JSON, such as
+//
+//	{"faults": {"2": {"status": 503}, "5": {"stall": "2s"}}}
+//
+// so that a sequence of failures seen in CI can be replayed exactly.
+type Schedule struct {
+	Faults map[int]Fault `json:"faults"`
+
+	mu    sync.Mutex
+	n     int
+	fired []FiredFault
+}
+
+// ParseSchedule reads a JSON-encoded Schedule from r.
+func ParseSchedule(r io.Reader) (*Schedule, error) {
+	s := &Schedule{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, err
+	}
+	for i, f := range s.Faults {
+		if i < 1 {
+			return nil, fmt.Errorf("schedule: invalid ordinal %d", i)
+		}
+		if f.Stall == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(f.Stall); err != nil {
+			return nil, fmt.Errorf("schedule: ordinal %d: %v", i, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *Schedule) next(req *http.Request) (Fault, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n++
+	f, ok := s.Faults[s.n]
+	if !ok {
+		return Fault{}, false
+	}
+	s.fired = append(s.fired, FiredFault{
+		Ordinal: s.n,
+		Method:  req.Header.Get("X-Blazer-Method"),
+		Path:    req.URL.Path,
+		Fault:   f,
+	})
+	return f, true
+}
+
+// Fired returns the faults that have been injected so far, in order.
+func (s *Schedule) Fired() []FiredFault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]FiredFault(nil), s.fired...)
+}
+
+// WithSchedule causes the RoundTripper to inject faults according to s,
+// instead of at random.  FailureRate, Response, Stall, Body, and Trigger are
+// ignored when a schedule is set.  s should not be shared between
+// RoundTrippers.
+func WithSchedule(s *Schedule) FailureOption {
+	return func(o *options) {
+		o.sched = s
+	}
+}
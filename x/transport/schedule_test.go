@@ -0,0 +1,85 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type okTripper struct{}
+
+func (okTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(bytes.NewBufferString("{}")),
+		Request:    req,
+	}, nil
+}
+
+func TestSchedule(t *testing.T) {
+	sched, err := ParseSchedule(strings.NewReader(`{"faults": {"2": {"status": 503, "body": "busy"}, "4": {"status": 401}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := WithFailures(okTripper{}, WithSchedule(sched), MatchPathSubstring("b2_upload"))
+
+	paths := []string{"/b2_upload_file", "/b2_list_buckets", "/b2_upload_file", "/b2_upload_part", "/b2_upload_part"}
+	var got []int
+	for _, p := range paths {
+		req, err := http.NewRequest("POST", "http://localhost"+p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, resp.StatusCode)
+	}
+	want := []int{200, 200, 503, 200, 401}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("request %d: got status %d, want %d", i, got[i], want[i])
+		}
+	}
+
+	fired := sched.Fired()
+	if len(fired) != 2 {
+		t.Fatalf("Fired(): got %d faults, want 2", len(fired))
+	}
+	if fired[0].Ordinal != 2 || fired[0].Path != "/b2_upload_file" {
+		t.Errorf("Fired()[0]: got %+v", fired[0])
+	}
+	if fired[1].Ordinal != 4 || fired[1].Path != "/b2_upload_part" {
+		t.Errorf("Fired()[1]: got %+v", fired[1])
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, s := range []string{
+		`{"faults": {"0": {"status": 500}}}`,
+		`{"faults": {"1": {"stall": "forever"}}}`,
+		`{"faults": {"x": {}}}`,
+	} {
+		if _, err := ParseSchedule(strings.NewReader(s)); err == nil {
+			t.Errorf("ParseSchedule(%s): expected an error", s)
+		}
+	}
+}
@@ -52,6 +52,7 @@ type options struct {
 	rt             http.RoundTripper
 	msg            string
 	trg            *triggerReaderGroup
+	sched          *Schedule
 }
 
 func (o *options) doRequest(req *http.Request) (*http.Response, error) {
@@ -65,40 +66,55 @@ func (o *options) doRequest(req *http.Request) (*http.Response, error) {
 	return resp, err
 }
 
-func (o *options) RoundTrip(req *http.Request) (*http.Response, error) {
-	// TODO: fix triggering conditions
-	if rand.Float64() > o.failureRate {
-		return o.doRequest(req)
-	}
-
-	var match bool
+func (o *options) matches(req *http.Request) bool {
 	if len(o.pathSubstrings) == 0 {
-		match = true
+		return true
 	}
 	for _, ss := range o.pathSubstrings {
 		if strings.Contains(req.URL.Path, ss) {
-			match = true
-			break
+			return true
+		}
+	}
+	return false
+}
+
+func (o *options) RoundTrip(req *http.Request) (*http.Response, error) {
+	if o.sched != nil {
+		if !o.matches(req) {
+			return o.doRequest(req)
+		}
+		f, ok := o.sched.next(req)
+		if !ok {
+			return o.doRequest(req)
 		}
+		return o.fail(req, f.Status, f.Body, f.stall())
 	}
-	if !match {
+
+	// TODO: fix triggering conditions
+	if rand.Float64() > o.failureRate {
+		return o.doRequest(req)
+	}
+	if !o.matches(req) {
 		return o.doRequest(req)
 	}
+	return o.fail(req, o.status, o.msg, o.stall)
+}
 
-	if o.status > 0 {
+func (o *options) fail(req *http.Request, status int, msg string, stall time.Duration) (*http.Response, error) {
+	if status > 0 {
 		resp := &http.Response{
-			Status:     fmt.Sprintf("%d %s", o.status, http.StatusText(o.status)),
-			StatusCode: o.status,
-			Body:       ioutil.NopCloser(strings.NewReader(o.msg)),
+			Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			StatusCode: status,
+			Body:       ioutil.NopCloser(strings.NewReader(msg)),
 			Request:    req,
 		}
 		return resp, nil
 	}
 
-	if o.stall > 0 {
+	if stall > 0 {
 		ctx := req.Context()
 		select {
-		case <-time.After(o.stall):
+		case <-time.After(stall):
 		case <-ctx.Done():
 		}
 	}
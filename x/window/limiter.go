@@ -0,0 +1,52 @@
+// Copyright 2018, the Blazer authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"context"
+	"time"
+
+	"github.com/burner-account/blazer/internal/window"
+)
+
+// A Limiter allows at most a given number of events in any trailing span of
+// time.  Events are counted in a Window, so an event stops counting against
+// the limit up to one resolution after the span has passed.
+type Limiter struct {
+	l *window.Limiter
+}
+
+// NewLimiter returns a Limiter that allows n events over the given duration,
+// counted at the given resolution, which should divide size evenly.
+func NewLimiter(n int, size, resolution time.Duration) *Limiter {
+	return &Limiter{l: window.NewLimiter(n, size, resolution, nil)}
+}
+
+// Allow records an event and returns true if the limit has not been reached.
+// Otherwise it records nothing and returns false.
+func (l *Limiter) Allow() bool {
+	return l.l.Allow()
+}
+
+// Wait blocks until an event can be recorded without exceeding the limit, and
+// records it.  It returns an error only if ctx is done first.
+func (l *Limiter) Wait(ctx context.Context) error {
+	return l.l.Wait(ctx)
+}
+
+// Count returns the number of events in the window.
+func (l *Limiter) Count() int {
+	return l.l.Count()
+}
@@ -18,21 +18,16 @@
 package window
 
 import (
-	"sync"
 	"time"
+
+	"github.com/burner-account/blazer/internal/window"
 )
 
 // A Window efficiently records events that have occurred over a span of time
 // extending from some fixed interval ago to now.  Events that pass beyond this
 // horizon are discarded.
 type Window struct {
-	mu      sync.Mutex
-	events  []interface{}
-	res     time.Duration
-	last    time.Time
-	reduce  Reducer
-	forever bool
-	e       interface{}
+	w *window.Window
 }
 
 // A Reducer should take two values from the window and combine them into a
@@ -52,111 +47,16 @@ type Reducer func(i, j interface{}) interface{}
 //
 // A size of 0 means "forever"; old events will never be removed.
 func New(size, resolution time.Duration, r Reducer) *Window {
-	if size > 0 {
-		return &Window{
-			res:    resolution,
-			events: make([]interface{}, size/resolution),
-			reduce: r,
-		}
-	}
-	return &Window{
-		forever: true,
-		reduce:  r,
-	}
-}
-
-func (w *Window) bucket(now time.Time) int {
-	nanos := now.UnixNano()
-	abs := nanos / int64(w.res)
-	return int(abs) % len(w.events)
-}
-
-// sweep keeps the window valid.  It needs to be called from every method that
-// views or updates the window, and the caller needs to hold the mutex.
-func (w *Window) sweep(now time.Time) {
-	if w.forever {
-		return
-	}
-	defer func() {
-		w.last = now
-	}()
-
-	// This compares now and w.last's monotonic clocks.
-	diff := now.Sub(w.last)
-	if diff < 0 {
-		// time went backwards somehow; zero events and return
-		for i := range w.events {
-			w.events[i] = nil
-		}
-		return
-	}
-	last := now.Add(-diff)
-
-	b := w.bucket(now)
-	p := w.bucket(last)
-
-	if b == p && diff <= w.res {
-		// We're in the same bucket as the previous sweep, so all buckets are
-		// valid.
-		return
-	}
-
-	if diff > w.res*time.Duration(len(w.events)) {
-		// We've gone longer than this window measures since the last sweep, just
-		// zero the thing and have done.
-		for i := range w.events {
-			w.events[i] = nil
-		}
-		return
-	}
-
-	// Expire all invalid buckets.  This means buckets not seen since the
-	// previous sweep and now, including the current bucket but not including the
-	// previous bucket.
-	old := int64(last.UnixNano()) / int64(w.res)
-	new := int64(now.UnixNano()) / int64(w.res)
-	for i := old + 1; i <= new; i++ {
-		b := int(i) % len(w.events)
-		w.events[b] = nil
-	}
+	return &Window{w: window.New(size, resolution, window.Reducer(r))}
 }
 
 // Insert adds the given event.
 func (w *Window) Insert(e interface{}) {
-	w.insertAt(time.Now(), e)
-}
-
-func (w *Window) insertAt(t time.Time, e interface{}) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-
-	if w.forever {
-		w.e = w.reduce(w.e, e)
-		return
-	}
-
-	w.sweep(t)
-	w.events[w.bucket(t)] = w.reduce(w.events[w.bucket(t)], e)
+	w.w.Insert(e)
 }
 
 // Reduce runs the window's reducer over the valid values and returns the
 // result.
 func (w *Window) Reduce() interface{} {
-	return w.reducedAt(time.Now())
-}
-
-func (w *Window) reducedAt(t time.Time) interface{} {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-
-	if w.forever {
-		return w.e
-	}
-
-	w.sweep(t)
-	var n interface{}
-	for i := range w.events {
-		n = w.reduce(n, w.events[i])
-	}
-	return n
+	return w.w.Reduce()
 }